@@ -0,0 +1,77 @@
+// Command docgen generates the parameter tables of the plugin docs from the
+// config structs of the plugins. It replaces the text between the markers
+// in docs/<kind>s/<type>.md.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/config"
+)
+
+const (
+	beginMarker = "<!-- BEGIN GENERATED PARAMETERS -->\n"
+	endMarker   = "<!-- END GENERATED PARAMETERS -->"
+)
+
+func main() {
+	dir := flag.String("docs", "./docs", "path to the docs directory")
+	check := flag.Bool("check", false, "only report docs that are out of date")
+	flag.Parse()
+
+	stale, err := update(*dir, !*check)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, path := range stale {
+		if *check {
+			fmt.Fprintf(os.Stderr, "%s is out of date, run make docs\n", path)
+		} else {
+			fmt.Printf("updated %s\n", path)
+		}
+	}
+	if *check && len(stale) > 0 {
+		os.Exit(1)
+	}
+}
+
+// update regenerates the parameter table of every plugin and returns the
+// files whose table changed. With write the files are rewritten.
+func update(dir string, write bool) ([]string, error) {
+	var stale []string
+	for _, doc := range config.PluginDocs() {
+		path := filepath.Join(dir, doc.Kind+"s", doc.Type+".md")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return stale, fmt.Errorf("no docs for the %s %s: %w", doc.Type, doc.Kind, err)
+		}
+
+		begin := bytes.Index(data, []byte(beginMarker))
+		end := bytes.Index(data, []byte(endMarker))
+		if begin == -1 || end < begin {
+			return stale, errors.New(path + " has no markers for the generated parameters")
+		}
+		begin += len(beginMarker)
+
+		table := []byte(doc.Schema.Markdown())
+		if bytes.Equal(data[begin:end], table) {
+			continue
+		}
+		stale = append(stale, path)
+		if !write {
+			continue
+		}
+
+		updated := append(append(append([]byte{}, data[:begin]...), table...), data[end:]...)
+		if err := os.WriteFile(path, updated, 0644); err != nil {
+			return stale, err
+		}
+	}
+	return stale, nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsUpToDate(t *testing.T) {
+	stale, err := update("../../docs", false)
+	require.NoError(t, err)
+	assert.Empty(t, stale, "run make docs to update them")
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/MuchTitan/go-log-forwarder/internal/admin"
 	"github.com/MuchTitan/go-log-forwarder/internal/config"
+	"github.com/MuchTitan/go-log-forwarder/internal/metrics"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,26 +21,81 @@ var opts = FlagOptions{}
 
 func init() {
 	opts.configPath = flag.String("cfg", "/app/cfg.yaml", "provided the path to your config file")
-	flag.Parse()
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "test":
+			os.Exit(test(os.Args[2:]))
+		}
+	}
+	flag.Parse()
+
 	engine, err := config.NewPluginEngine(*opts.configPath)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	logrus.Info("Starting log forwarder")
 
 	if err := engine.Start(); err != nil {
-		panic(err)
+		logrus.WithError(err).Error("Coundnt start log forwarder")
+		engine.Stop()
+		os.Exit(1)
+	}
+
+	var adminServer *admin.Server
+	if addr := engine.AdminAddr(); addr != "" {
+		adminServer = admin.NewServer(addr, engine)
+		if err := adminServer.Start(); err != nil {
+			logrus.WithError(err).Error("Coundnt start admin server")
+			engine.Stop()
+			os.Exit(1)
+		}
 	}
 
-	// Wait for shutdown signal
+	var metricsServer *metrics.Server
+	if addr := engine.MetricsAddr(); addr != "" {
+		metricsServer = metrics.NewServer(addr)
+		if err := metricsServer.Start(); err != nil {
+			logrus.WithError(err).Error("Coundnt start metrics server")
+			if adminServer != nil {
+				adminServer.Stop()
+			}
+			engine.Stop()
+			os.Exit(1)
+		}
+	}
+
+	// Wait for a shutdown signal or an input like stdin that read all of its
+	// data, SIGHUP reloads the config
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig != syscall.SIGHUP {
+				break wait
+			}
+			if err := engine.Reload(); err != nil {
+				logrus.WithError(err).Error("Config reload failed, keeping the running config")
+			}
+		case <-engine.ShutdownRequested():
+			break wait
+		}
+	}
 
 	logrus.Info("Stopping log forwarder")
+	if adminServer != nil {
+		adminServer.Stop()
+	}
+	if metricsServer != nil {
+		metricsServer.Stop()
+	}
 	engine.Stop()
 }
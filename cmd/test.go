@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/config"
+	"github.com/MuchTitan/go-log-forwarder/internal/engine"
+)
+
+// maxLineSize is the longest sample line the test subcommand reads
+const maxLineSize = 1 << 20
+
+// test runs the lines of stdin through the parsers and filters of a config
+// and prints what happened to every line. Inputs and outputs are not started.
+func test(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	configPath := flags.String("cfg", "/app/cfg.yaml", "provided the path to your config file")
+	tag := flags.String("tag", "", "the tag the sample lines are read with")
+	flags.Parse(args)
+
+	if *tag == "" {
+		fmt.Fprintln(os.Stderr, "the --tag flag is required")
+		return 2
+	}
+
+	e, err := config.NewTestEngine(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := runTest(e.Engine, *tag, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runTest(e *engine.Engine, tag string, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	hostname, _ := os.Hostname()
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		readTime := time.Now()
+		trace := e.Trace(internal.Event{
+			Timestamp: readTime,
+			RawData:   scanner.Text(),
+			Metadata: internal.Metadata{
+				Source:      "stdin",
+				Host:        hostname,
+				Tag:         tag,
+				LineNum:     lineNum,
+				InputSource: "test",
+			},
+		})
+		printTrace(out, lineNum, scanner.Text(), readTime, trace)
+	}
+	return scanner.Err()
+}
+
+func printTrace(out io.Writer, lineNum int, line string, readTime time.Time, trace engine.Trace) {
+	fmt.Fprintf(out, "line %d: %s\n", lineNum, line)
+	if trace.Chain != "" {
+		fmt.Fprintf(out, "  parser chain: %s\n", trace.Chain)
+	}
+	if len(trace.Parsers) == 0 {
+		fmt.Fprintln(out, "  parser: none matching the tag")
+	}
+	for _, result := range trace.Parsers {
+		status := "no match"
+		if result.Success {
+			status = "matched"
+		}
+		fmt.Fprintf(out, "  parser %s: %s\n", result.Parser, status)
+	}
+	for _, step := range trace.Filters {
+		if step.Err != nil {
+			fmt.Fprintf(out, "  filter %s: %s (%v)\n", step.Filter, step.Result, step.Err)
+			continue
+		}
+		fmt.Fprintf(out, "  filter %s: %s\n", step.Filter, step.Result)
+	}
+
+	if trace.Event == nil {
+		fmt.Fprintf(out, "  result: dropped by %s\n\n", trace.DroppedBy)
+		return
+	}
+
+	event := trace.Event
+	if event.Timestamp.Equal(readTime) {
+		fmt.Fprintln(out, "  timestamp: none extracted")
+	} else {
+		fmt.Fprintf(out, "  timestamp: %s\n", event.Timestamp.Format(time.RFC3339Nano))
+	}
+	if event.ParsedData == nil {
+		fmt.Fprintln(out, "  parsed data: none")
+	} else {
+		data, err := json.Marshal(event.ParsedData)
+		if err != nil {
+			data = []byte(fmt.Sprintf("cant convert parsed data to json: %v", err))
+		}
+		fmt.Fprintf(out, "  parsed data: %s\n", data)
+	}
+	fmt.Fprintf(out, "  result: kept with tag %s\n\n", event.Metadata.Tag)
+}
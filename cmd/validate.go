@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/config"
+)
+
+// validate checks a config file without starting the forwarder and returns
+// the exit status, which is non-zero if the config has problems
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := flags.String("cfg", "/app/cfg.yaml", "provided the path to your config file")
+	flags.Parse(args)
+
+	problems, err := config.Validate(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems))
+		return 1
+	}
+
+	fmt.Printf("%s: config is valid\n", *configPath)
+	return 0
+}
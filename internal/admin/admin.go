@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/engine"
+	"github.com/sirupsen/logrus"
+)
+
+const flushTimeout = time.Second * 30
+
+// Reloader applies a changed config to the running forwarder
+type Reloader interface {
+	Reload() error
+}
+
+// Engine is the running forwarder the admin server reports on and controls
+type Engine interface {
+	Reloader
+	Status() []engine.PluginStatus
+	Ready() error
+	PauseInput(name string) error
+	ResumeInput(name string) error
+	FlushOutput(ctx context.Context, name string) error
+}
+
+// Server is the admin HTTP endpoint of the forwarder
+type Server struct {
+	addr     string
+	engine   Engine
+	server   *http.Server
+	listener net.Listener
+}
+
+func NewServer(addr string, forwarder Engine) *Server {
+	s := &Server{
+		addr:   addr,
+		engine: forwarder,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	mux.HandleFunc("GET /plugins", s.handlePlugins)
+	mux.HandleFunc("GET /plugins/{type}", s.handlePlugins)
+	mux.HandleFunc("POST /inputs/{name}/pause", s.handlePause)
+	mux.HandleFunc("POST /inputs/{name}/resume", s.handleResume)
+	mux.HandleFunc("POST /outputs/{name}/flush", s.handleFlush)
+	mux.HandleFunc("POST /reload", s.handleReload)
+
+	s.server = &http.Server{
+		Handler:     mux,
+		ReadTimeout: time.Second * 30,
+	}
+	return s
+}
+
+// Start binds the listen address and serves requests in the background
+func (s *Server) Start() error {
+	var err error
+	s.listener, err = net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("couldn't start admin server: %w", err)
+	}
+
+	logrus.WithField("Addr", s.listener.Addr().String()).Info("Starting admin server")
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("error during admin server")
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Stop() error {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer shutdownCancel()
+	return s.server.Shutdown(shutdownCtx)
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.engine.Reload(); err != nil {
+		logrus.WithError(err).Error("Config reload failed, keeping the running config")
+		http.Error(w, fmt.Sprintf("Config reload failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "Config reloaded")
+}
+
+// handleHealth answers as long as the process is alive
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "OK")
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if err := s.engine.Ready(); err != nil {
+		http.Error(w, fmt.Sprintf("Not ready:\n%v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "Ready")
+}
+
+// handlePlugins lists the plugins, optionally only those of one type
+// (input, parser, filter or output)
+func (s *Server) handlePlugins(w http.ResponseWriter, r *http.Request) {
+	pluginType := r.PathValue("type")
+	statuses := []engine.PluginStatus{}
+	for _, status := range s.engine.Status() {
+		if pluginType == "" || status.Type == pluginType {
+			statuses = append(statuses, status)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		logrus.WithError(err).Warn("could not write plugin status")
+	}
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.handleAction(w, s.engine.PauseInput(name), "Input %s paused", name)
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.handleAction(w, s.engine.ResumeInput(name), "Input %s resumed", name)
+}
+
+func (s *Server) handleFlush(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), flushTimeout)
+	defer cancel()
+
+	name := r.PathValue("name")
+	s.handleAction(w, s.engine.FlushOutput(ctx, name), "Output %s flushed", name)
+}
+
+func (s *Server) handleAction(w http.ResponseWriter, err error, format string, name string) {
+	if errors.Is(err, engine.ErrUnknownPlugin) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("admin action failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, format, name)
+}
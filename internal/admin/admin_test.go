@@ -0,0 +1,169 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockEngine struct {
+	err      error
+	reloads  int
+	notReady error
+	paused   map[string]bool
+	flushed  []string
+}
+
+func (m *mockEngine) Reload() error {
+	m.reloads++
+	return m.err
+}
+
+func (m *mockEngine) Status() []engine.PluginStatus {
+	state := engine.StateRunning
+	if m.paused["tail"] {
+		state = engine.StatePaused
+	}
+	return []engine.PluginStatus{
+		{Name: "tail", Type: "input", State: state, Counters: map[string]uint64{"events": 3}},
+		{Name: "stdout", Type: "output", State: engine.StateFailed, LastError: "broken pipe"},
+	}
+}
+
+func (m *mockEngine) Ready() error {
+	return m.notReady
+}
+
+func (m *mockEngine) PauseInput(name string) error {
+	if name != "tail" {
+		return fmt.Errorf("%w: input %s", engine.ErrUnknownPlugin, name)
+	}
+	m.paused[name] = true
+	return nil
+}
+
+func (m *mockEngine) ResumeInput(name string) error {
+	if name != "tail" {
+		return fmt.Errorf("%w: input %s", engine.ErrUnknownPlugin, name)
+	}
+	m.paused[name] = false
+	return nil
+}
+
+func (m *mockEngine) FlushOutput(ctx context.Context, name string) error {
+	m.flushed = append(m.flushed, name)
+	return nil
+}
+
+func startTestServer(t *testing.T) (*mockEngine, string) {
+	forwarder := &mockEngine{paused: make(map[string]bool)}
+	s := NewServer("127.0.0.1:0", forwarder)
+	require.NoError(t, s.Start())
+	t.Cleanup(func() { s.Stop() })
+	return forwarder, "http://" + s.Addr()
+}
+
+func request(t *testing.T, method, url string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_Reload(t *testing.T) {
+	forwarder, baseURL := startTestServer(t)
+	url := baseURL + "/reload"
+
+	code, _ := request(t, http.MethodPost, url)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, forwarder.reloads)
+
+	forwarder.err = errors.New("unknown output type: foo")
+	code, body := request(t, http.MethodPost, url)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Contains(t, body, "unknown output type: foo")
+
+	code, _ = request(t, http.MethodGet, url)
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, 2, forwarder.reloads)
+}
+
+func TestServer_Health(t *testing.T) {
+	forwarder, baseURL := startTestServer(t)
+
+	code, _ := request(t, http.MethodGet, baseURL+"/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = request(t, http.MethodGet, baseURL+"/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	forwarder.notReady = errors.New("input tail is not running")
+	code, body := request(t, http.MethodGet, baseURL+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "input tail is not running")
+
+	// Liveness doesn't depend on readiness
+	code, _ = request(t, http.MethodGet, baseURL+"/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestServer_Plugins(t *testing.T) {
+	_, baseURL := startTestServer(t)
+
+	tests := []struct {
+		path  string
+		names []string
+	}{
+		{"/plugins", []string{"tail", "stdout"}},
+		{"/plugins/output", []string{"stdout"}},
+		{"/plugins/filter", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			code, body := request(t, http.MethodGet, baseURL+tt.path)
+			require.Equal(t, http.StatusOK, code)
+
+			var statuses []engine.PluginStatus
+			require.NoError(t, json.Unmarshal([]byte(body), &statuses))
+			names := []string{}
+			for _, status := range statuses {
+				names = append(names, status.Name)
+			}
+			assert.Equal(t, tt.names, names)
+		})
+	}
+
+	_, body := request(t, http.MethodGet, baseURL+"/plugins/output")
+	assert.Contains(t, body, `"lastError":"broken pipe"`)
+}
+
+func TestServer_Actions(t *testing.T) {
+	forwarder, baseURL := startTestServer(t)
+
+	code, _ := request(t, http.MethodPost, baseURL+"/inputs/tail/pause")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, forwarder.paused["tail"])
+
+	code, _ = request(t, http.MethodPost, baseURL+"/inputs/tail/resume")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, forwarder.paused["tail"])
+
+	code, _ = request(t, http.MethodPost, baseURL+"/inputs/missing/pause")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = request(t, http.MethodPost, baseURL+"/outputs/stdout/flush")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"stdout"}, forwarder.flushed)
+}
@@ -1,27 +1,25 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/engine"
 	"github.com/MuchTitan/go-log-forwarder/internal/filter"
-	filtergrep "github.com/MuchTitan/go-log-forwarder/internal/filter/grep"
 	"github.com/MuchTitan/go-log-forwarder/internal/input"
-	inputhttp "github.com/MuchTitan/go-log-forwarder/internal/input/http"
-	inputtail "github.com/MuchTitan/go-log-forwarder/internal/input/tail"
-	inputtcp "github.com/MuchTitan/go-log-forwarder/internal/input/tcp"
 	"github.com/MuchTitan/go-log-forwarder/internal/output"
-	outputcounter "github.com/MuchTitan/go-log-forwarder/internal/output/counter"
-	outputgelf "github.com/MuchTitan/go-log-forwarder/internal/output/gelf"
-	outputsplunk "github.com/MuchTitan/go-log-forwarder/internal/output/splunk"
-	outputstdout "github.com/MuchTitan/go-log-forwarder/internal/output/stdout"
 	"github.com/MuchTitan/go-log-forwarder/internal/parser"
-	parserjson "github.com/MuchTitan/go-log-forwarder/internal/parser/json"
-	parserregex "github.com/MuchTitan/go-log-forwarder/internal/parser/regex"
+	"github.com/MuchTitan/go-log-forwarder/internal/queue"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/sirupsen/logrus"
 
 	"gopkg.in/yaml.v3"
@@ -29,17 +27,29 @@ import (
 
 // Config represents the complete configuration
 type Config struct {
-	System  SystemConfig     `yaml:"System"`
-	Inputs  []map[string]any `yaml:"Inputs"`
-	Parsers []map[string]any `yaml:"Parsers"`
-	Filters []map[string]any `yaml:"Filters"`
-	Outputs []map[string]any `yaml:"Outputs"`
+	System       SystemConfig     `yaml:"System"`
+	Inputs       []map[string]any `yaml:"Inputs"`
+	Parsers      []map[string]any `yaml:"Parsers"`
+	ParserChains []map[string]any `yaml:"ParserChains"`
+	Filters      []map[string]any `yaml:"Filters"`
+	Outputs      []map[string]any `yaml:"Outputs"`
 }
 
 // SystemConfig holds system-wide configuration
 type SystemConfig struct {
-	LogLevel string `yaml:"logLevel"`
-	LogFile  string `yaml:"logFile"`
+	LogLevel           string        `yaml:"logLevel" enum:"TRACE,DEBUG,INFO,WARNING,ERROR"`
+	LogFile            string        `yaml:"logFile"`
+	StorageDir         string        `yaml:"StorageDir"`
+	DeadLetterFile     string        `yaml:"DeadLetterFile"`
+	FlushInterval      time.Duration `yaml:"FlushInterval"`
+	BatchSize          int           `yaml:"BatchSize" min:"0"`
+	BatchBytes         int           `yaml:"BatchBytes" min:"0"`
+	PipelineBufferSize *int          `yaml:"PipelineBufferSize" min:"0"`
+	AdminAddr          string        `yaml:"AdminAddr"`
+	MetricsAddr        string        `yaml:"MetricsAddr"`
+	FailFast           bool          `yaml:"FailFast"`
+	// Percentage of an output queue that may be filled before /readyz fails
+	ReadyQueuePercent int `yaml:"ReadyQueuePercent" min:"0" max:"100"`
 }
 
 func (c *SystemConfig) GetLogLevel() logrus.Level {
@@ -58,40 +68,127 @@ func (c *SystemConfig) GetLogLevel() logrus.Level {
 	}
 }
 
+// EngineSettings converts the System section into the engine settings
+func (c *SystemConfig) EngineSettings() (engine.Settings, error) {
+	settings := engine.DefaultSettings()
+	settings.FailFast = c.FailFast
+
+	if c.PipelineBufferSize != nil {
+		if *c.PipelineBufferSize < 0 {
+			return settings, errors.New("PipelineBufferSize can't be negative")
+		}
+		settings.PipelineBufferSize = *c.PipelineBufferSize
+	}
+
+	if c.BatchSize < 0 || c.BatchBytes < 0 {
+		return settings, errors.New("BatchSize and BatchBytes can't be negative")
+	}
+	if c.BatchSize > 0 {
+		settings.Batch.Size = c.BatchSize
+	}
+	settings.Batch.Bytes = c.BatchBytes
+
+	if c.FlushInterval < 0 {
+		return settings, fmt.Errorf("invalid FlushInterval '%s'", c.FlushInterval)
+	}
+	if c.FlushInterval > 0 {
+		settings.Batch.FlushInterval = c.FlushInterval
+	}
+
+	if c.ReadyQueuePercent < 0 || c.ReadyQueuePercent > 100 {
+		return settings, errors.New("ReadyQueuePercent has to be between 0 and 100")
+	}
+	if c.ReadyQueuePercent > 0 {
+		settings.ReadyQueueThreshold = float64(c.ReadyQueuePercent) / 100
+	}
+
+	return settings, nil
+}
+
 // Engine is extended to include configuration
 type PluginEngine struct {
 	*engine.Engine
-	config Config
+	config      Config
+	configPath  string
+	plugins     engine.Plugins // In the order of the config
+	deadLetters map[string]*engine.DeadLetter
+	reloadMu    sync.Mutex
 }
 
 // NewPluginEngine creates a new engine with configuration
 func NewPluginEngine(configPath string) (*PluginEngine, error) {
-	engine := &PluginEngine{
-		Engine: engine.NewEngine(),
+	pe := &PluginEngine{configPath: configPath}
+
+	if err := validateConfigFile(configPath); err != nil {
+		return nil, err
+	}
+
+	if err := pe.loadConfig(configPath); err != nil {
+		return nil, err
+	}
+
+	settings, err := pe.config.System.EngineSettings()
+	if err != nil {
+		return nil, fmt.Errorf("invalid System config: %w", err)
 	}
+	pe.Engine = engine.NewEngine(settings)
 
-	if err := engine.loadConfig(configPath); err != nil {
+	if err := pe.initializePlugins(); err != nil {
 		return nil, err
 	}
 
-	if err := engine.initializePlugins(); err != nil {
+	if err := pe.setupQueue(); err != nil {
 		return nil, err
 	}
 
-	return engine, nil
+	return pe, nil
 }
 
-func (e *PluginEngine) loadConfig(path string) error {
-	data, err := os.ReadFile(path)
+// NewTestEngine creates an engine with only the parsers, parser chains and
+// filters of the config, to run sample events through them with Trace.
+// Inputs, outputs, the queue and the log file are left out.
+func NewTestEngine(configPath string) (*PluginEngine, error) {
+	pe := &PluginEngine{configPath: configPath}
+
+	if err := validateConfigFile(configPath); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if pe.config, err = readConfig(configPath); err != nil {
+		return nil, err
+	}
+	pe.config.Inputs = nil
+	pe.config.Outputs = nil
+	logrus.SetLevel(pe.config.System.GetLogLevel())
+
+	settings, err := pe.config.System.EngineSettings()
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("invalid System config: %w", err)
 	}
+	pe.Engine = engine.NewEngine(settings)
 
-	// Replace environment variables
-	expandedData := os.ExpandEnv(string(data))
+	if err := pe.initializePlugins(); err != nil {
+		return nil, err
+	}
 
-	if err := yaml.Unmarshal([]byte(expandedData), &e.config); err != nil {
-		return fmt.Errorf("failed to parse config: %w", err)
+	return pe, nil
+}
+
+// AdminAddr is the listen address of the admin server, empty if disabled
+func (e *PluginEngine) AdminAddr() string {
+	return e.config.System.AdminAddr
+}
+
+// MetricsAddr is the listen address of the metrics server, empty if disabled
+func (e *PluginEngine) MetricsAddr() string {
+	return e.config.System.MetricsAddr
+}
+
+func (e *PluginEngine) loadConfig(path string) error {
+	var err error
+	if e.config, err = readConfig(path); err != nil {
+		return err
 	}
 
 	// Setup logging
@@ -102,6 +199,24 @@ func (e *PluginEngine) loadConfig(path string) error {
 	return nil
 }
 
+func readConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Replace environment variables
+	expandedData := os.ExpandEnv(string(data))
+
+	if err := yaml.Unmarshal([]byte(expandedData), &config); err != nil {
+		return config, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return config, nil
+}
+
 func (e *PluginEngine) setupLogging() error {
 	writers := []io.Writer{os.Stderr}
 
@@ -127,119 +242,273 @@ func (e *PluginEngine) setupLogging() error {
 	return nil
 }
 
+func (e *PluginEngine) setupQueue() error {
+	if e.config.System.StorageDir == "" {
+		return nil
+	}
+
+	q, err := queue.Open(filepath.Join(e.config.System.StorageDir, "queue"), queue.DefaultSegmentSize)
+	if err != nil {
+		return fmt.Errorf("failed to open disk queue: %w", err)
+	}
+
+	e.SetQueue(q)
+	return nil
+}
+
 func (e *PluginEngine) initializePlugins() error {
-	// Initialize inputs
-	for _, inputConfig := range e.config.Inputs {
-		if err := e.initializeInput(inputConfig); err != nil {
-			return fmt.Errorf("failed to initialize input: %w", err)
-		}
+	plugins, err := e.buildPlugins(e.config)
+	if err != nil {
+		return err
+	}
+
+	for _, in := range plugins.Inputs {
+		e.RegisterInput(in)
+	}
+	for _, parser := range plugins.Parsers {
+		e.RegisterParser(parser)
+	}
+	for _, chain := range plugins.Chains {
+		e.RegisterParserChain(chain)
 	}
+	for _, filter := range plugins.Filters {
+		e.RegisterFilter(filter)
+	}
+	for _, out := range plugins.Outputs {
+		e.RegisterOutput(out.Plugin, out.Options)
+	}
+
+	e.plugins = plugins
+	return nil
+}
 
-	// Initialize parsers
-	for _, parserConfig := range e.config.Parsers {
-		if err := e.initializeParser(parserConfig); err != nil {
-			return fmt.Errorf("failed to initialize parser: %w", err)
+// buildPlugins creates the plugins of config. Plugins whose config is
+// identical to one of the running config are reused, so they keep running
+// with their state during a reload. If a plugin can't be created, the ones
+// created so far are cleaned up again.
+func (e *PluginEngine) buildPlugins(config Config) (plugins engine.Plugins, err error) {
+	var created []internal.Plugin
+	defer func() {
+		if err != nil {
+			for _, plugin := range created {
+				plugin.Exit()
+			}
+		}
+	}()
+
+	// Build inputs
+	used := make([]bool, len(e.config.Inputs))
+	for _, inputConfig := range config.Inputs {
+		if idx := findConfig(e.config.Inputs, used, inputConfig, len(e.plugins.Inputs)); idx >= 0 {
+			plugins.Inputs = append(plugins.Inputs, e.plugins.Inputs[idx])
+			continue
+		}
+		in, err := newInput(inputConfig)
+		if err != nil {
+			return plugins, fmt.Errorf("failed to initialize input: %w", err)
 		}
+		created = append(created, in)
+		plugins.Inputs = append(plugins.Inputs, in)
 	}
 
-	// Initialize filters
-	for _, filterConfig := range e.config.Filters {
-		if err := e.initializeFilter(filterConfig); err != nil {
-			return fmt.Errorf("failed to initialize filter: %w", err)
+	// Build parsers
+	used = make([]bool, len(e.config.Parsers))
+	for _, parserConfig := range config.Parsers {
+		if idx := findConfig(e.config.Parsers, used, parserConfig, len(e.plugins.Parsers)); idx >= 0 {
+			plugins.Parsers = append(plugins.Parsers, e.plugins.Parsers[idx])
+			continue
 		}
+		parser, err := newParser(parserConfig)
+		if err != nil {
+			return plugins, fmt.Errorf("failed to initialize parser: %w", err)
+		}
+		created = append(created, parser)
+		plugins.Parsers = append(plugins.Parsers, parser)
 	}
 
-	// Initialize outputs
-	for _, outputConfig := range e.config.Outputs {
-		if err := e.initializeOutput(outputConfig); err != nil {
-			return fmt.Errorf("failed to initialize output: %w", err)
+	// Build parser chains, they only refer to parsers and are always rebuilt
+	for _, chainConfig := range config.ParserChains {
+		chain, err := newParserChain(chainConfig, plugins.Parsers)
+		if err != nil {
+			return plugins, fmt.Errorf("failed to initialize parser chain: %w", err)
 		}
+		plugins.Chains = append(plugins.Chains, chain)
 	}
 
-	return nil
-}
+	// Build filters
+	used = make([]bool, len(e.config.Filters))
+	for _, filterConfig := range config.Filters {
+		if idx := findConfig(e.config.Filters, used, filterConfig, len(e.plugins.Filters)); idx >= 0 {
+			plugins.Filters = append(plugins.Filters, e.plugins.Filters[idx])
+			continue
+		}
+		filter, err := newFilter(filterConfig)
+		if err != nil {
+			return plugins, fmt.Errorf("failed to initialize filter: %w", err)
+		}
+		created = append(created, filter)
+		plugins.Filters = append(plugins.Filters, filter)
+	}
 
-func (e *PluginEngine) initializeInput(config map[string]any) error {
-	var inputObject input.Plugin
+	// Build outputs
+	used = make([]bool, len(e.config.Outputs))
+	for _, outputConfig := range config.Outputs {
+		if idx := findConfig(e.config.Outputs, used, outputConfig, len(e.plugins.Outputs)); idx >= 0 {
+			plugins.Outputs = append(plugins.Outputs, e.plugins.Outputs[idx])
+			continue
+		}
+		out, err := newOutput(outputConfig)
+		if err != nil {
+			return plugins, fmt.Errorf("failed to initialize output: %w", err)
+		}
+		created = append(created, out)
 
-	switch strings.ToLower(config["Type"].(string)) {
-	case "tail":
-		inputObject = &inputtail.Tail{}
-	case "tcp":
-		inputObject = &inputtcp.TCP{}
-	case "http":
-		inputObject = &inputhttp.InHTTP{}
-	default:
-		return fmt.Errorf("unknown input type: %s", config["Type"])
+		// System changes require a restart, so the running System config applies
+		opts, err := e.outputOptions(outputConfig, e.config.System.DeadLetterFile)
+		if err != nil {
+			return plugins, fmt.Errorf("output %s: %w", out.Name(), err)
+		}
+		plugins.Outputs = append(plugins.Outputs, engine.Output{Plugin: out, Options: opts})
 	}
 
-	if err := inputObject.Init(config); err != nil {
-		return err
+	return plugins, nil
+}
+
+// findConfig returns the index of the first unused entry in configs that is
+// identical to config and marks it as used. Only the first n entries have a
+// running plugin. It returns -1 if there is none.
+func findConfig(configs []map[string]any, used []bool, config map[string]any, n int) int {
+	for i := range min(len(configs), n) {
+		if !used[i] && reflect.DeepEqual(configs[i], config) {
+			used[i] = true
+			return i
+		}
 	}
+	return -1
+}
 
-	e.RegisterInput(inputObject)
-	return nil
+// pluginType returns the lower case Type of a plugin config, empty if it
+// is missing or not a string
+func pluginType(config map[string]any) string {
+	typ, _ := config["Type"].(string)
+	return strings.ToLower(typ)
 }
 
-func (e *PluginEngine) initializeParser(config map[string]any) error {
-	var parserObject parser.Plugin
+func newInput(config map[string]any) (input.Plugin, error) {
+	return newPlugin("input", inputTypes, config)
+}
 
-	switch strings.ToLower(config["Type"].(string)) {
-	case "json":
-		parserObject = &parserjson.Json{}
-	case "regex":
-		parserObject = &parserregex.Regex{}
-	default:
-		return fmt.Errorf("unknown filter type: %s", config["Type"])
+func newParser(config map[string]any) (parser.Plugin, error) {
+	return newPlugin("parser", parserTypes, config)
+}
+
+// newParserChain creates a parser chain from the parsers referred to by name
+func newParserChain(config map[string]any, parsers []parser.Plugin) (engine.ParserChain, error) {
+	var cfg parserChainConfig
+	if err := schema.Decode(config, &cfg); err != nil {
+		return engine.ParserChain{}, err
 	}
 
-	if err := parserObject.Init(config); err != nil {
-		return err
+	chain := engine.ParserChain{
+		Match:     cfg.Match,
+		OnFailure: engine.OnFailure(cfg.OnFailure),
+		ErrorTag:  cfg.ErrorTag,
 	}
 
-	e.RegisterParser(parserObject)
-	return nil
+	if len(cfg.Parsers) == 0 {
+		return chain, errors.New("a parser chain needs a list of Parsers")
+	}
+	for _, name := range cfg.Parsers {
+		idx := slices.IndexFunc(parsers, func(p parser.Plugin) bool { return p.Name() == name })
+		if idx == -1 {
+			return chain, fmt.Errorf("unknown parser '%v' in parser chain", name)
+		}
+		chain.Parsers = append(chain.Parsers, parsers[idx])
+	}
+
+	if chain.OnFailure == engine.OnFailureErrorTag && chain.ErrorTag == "" {
+		return chain, errors.New("OnFailure 'error_tag' needs an ErrorTag")
+	}
+
+	return chain, nil
 }
 
-func (e *PluginEngine) initializeFilter(config map[string]any) error {
-	var filterObject filter.Plugin
+func newFilter(config map[string]any) (filter.Plugin, error) {
+	return newPlugin("filter", filterTypes, config)
+}
 
-	switch strings.ToLower(config["Type"].(string)) {
-	case "grep":
-		filterObject = &filtergrep.Grep{}
-	default:
-		return fmt.Errorf("unknown filter type: %s", config["Type"])
-	}
+func newOutput(config map[string]any) (output.Plugin, error) {
+	return newPlugin("output", outputTypes, config)
+}
 
-	if err := filterObject.Init(config); err != nil {
-		return err
+// outputOptions reads the engine side settings of an output
+func (e *PluginEngine) outputOptions(config map[string]any, deadLetterFile string) (engine.OutputOptions, error) {
+	opts := engine.DefaultOutputOptions()
+
+	var cfg outputConfig
+	if err := schema.Decode(config, &cfg); err != nil {
+		return opts, err
 	}
 
-	e.RegisterFilter(filterObject)
-	return nil
-}
+	if cfg.Workers > 0 {
+		opts.Workers = cfg.Workers
+	}
+	if cfg.QueueSize > 0 {
+		opts.QueueSize = cfg.QueueSize
+	}
+	if cfg.OverflowPolicy != "" {
+		opts.OverflowPolicy = engine.OverflowPolicy(cfg.OverflowPolicy)
+	}
 
-func (e *PluginEngine) initializeOutput(config map[string]any) error {
-	var outputObject output.Plugin
+	// Unset batch settings fall back to the System ones
+	opts.Batch = engine.BatchSettings{
+		Size:          cfg.BatchSize,
+		Bytes:         int(cfg.BatchBytes),
+		FlushInterval: cfg.FlushInterval,
+	}
 
-	switch strings.ToLower(config["Type"].(string)) {
-	case "stdout":
-		outputObject = &outputstdout.Stdout{}
-	case "splunk":
-		outputObject = &outputsplunk.Splunk{}
-	case "counter":
-		outputObject = &outputcounter.Counter{}
-	case "gelf":
-		outputObject = &outputgelf.GELF{}
-	default:
-		return fmt.Errorf("unknown output type: %s", config["Type"])
+	if cfg.Retry.MaxAttempts > 0 {
+		opts.Retry.MaxAttempts = cfg.Retry.MaxAttempts
+	}
+	if cfg.Retry.InitialBackoff > 0 {
+		opts.Retry.InitialBackoff = cfg.Retry.InitialBackoff
+	}
+	if cfg.Retry.MaxBackoff > 0 {
+		opts.Retry.MaxBackoff = cfg.Retry.MaxBackoff
+	}
+	if cfg.Retry.Jitter != nil {
+		opts.Retry.Jitter = *cfg.Retry.Jitter
 	}
 
-	if err := outputObject.Init(config); err != nil {
-		return err
+	if cfg.DeadLetterFile != "" {
+		deadLetterFile = cfg.DeadLetterFile
+	}
+	if deadLetterFile != "" {
+		dl, err := e.deadLetter(deadLetterFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.DeadLetter = dl
 	}
 
-	e.RegisterOutput(outputObject)
-	return nil
+	return opts, nil
 }
 
+// deadLetter returns the dead letter file for path, outputs configured
+// with the same path share one file
+func (e *PluginEngine) deadLetter(path string) (*engine.DeadLetter, error) {
+	if dl, exists := e.deadLetters[path]; exists {
+		return dl, nil
+	}
+
+	dl, err := engine.NewDeadLetter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.deadLetters == nil {
+		e.deadLetters = make(map[string]*engine.DeadLetter)
+	}
+	e.deadLetters[path] = dl
+	return dl, nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/engine"
+	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	parserjson "github.com/MuchTitan/go-log-forwarder/internal/parser/json"
+	parserregex "github.com/MuchTitan/go-log-forwarder/internal/parser/regex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewParserChain(t *testing.T) {
+	nginx := &parserregex.Regex{}
+	require.NoError(t, nginx.Init(map[string]any{"Name": "nginx", "Pattern": `^(?P<host>\S+)`}))
+	json := &parserjson.Json{}
+	require.NoError(t, json.Init(map[string]any{}))
+	parsers := []parser.Plugin{nginx, json}
+
+	chain, err := newParserChain(map[string]any{
+		"Match":   "nginx*",
+		"Parsers": []any{"nginx", "json"},
+	}, parsers)
+	require.NoError(t, err)
+	assert.Equal(t, "nginx*", chain.Match)
+	assert.Equal(t, []parser.Plugin{nginx, json}, chain.Parsers)
+	assert.Equal(t, engine.OnFailureKeepRaw, chain.OnFailure)
+
+	chain, err = newParserChain(map[string]any{
+		"Parsers":   []any{"json"},
+		"OnFailure": "error_tag",
+		"ErrorTag":  "parse_error",
+	}, parsers)
+	require.NoError(t, err)
+	assert.Equal(t, "*", chain.Match)
+	assert.Equal(t, engine.OnFailureErrorTag, chain.OnFailure)
+	assert.Equal(t, "parse_error", chain.ErrorTag)
+
+	_, err = newParserChain(map[string]any{"Parsers": []any{"apache"}}, parsers)
+	assert.ErrorContains(t, err, "unknown parser 'apache'")
+
+	_, err = newParserChain(map[string]any{"Match": "*"}, parsers)
+	assert.ErrorContains(t, err, "Parsers: missing required field")
+
+	_, err = newParserChain(map[string]any{"Parsers": []any{"json"}, "OnFailure": "error_tag"}, parsers)
+	assert.ErrorContains(t, err, "needs an ErrorTag")
+
+	_, err = newParserChain(map[string]any{"Parsers": []any{"json"}, "OnFailure": "retry"}, parsers)
+	assert.ErrorContains(t, err, "OnFailure: unsupported value 'retry'")
+}
+
+func TestNewTestEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.yaml")
+	writeConfig(t, path, `
+Inputs:
+  - Type: tcp
+    Tag: app
+Parsers:
+  - Type: json
+Filters:
+  - Type: grep
+    Include: ["level"]
+Outputs:
+  - Type: counter
+`)
+
+	pe, err := NewTestEngine(path)
+	require.NoError(t, err)
+	assert.Empty(t, pe.plugins.Inputs)
+	assert.Empty(t, pe.plugins.Outputs)
+
+	trace := pe.Trace(internal.Event{RawData: `{"level":"info"}`, Metadata: internal.Metadata{Tag: "app"}})
+	assert.Equal(t, []engine.ParserResult{{Parser: "json", Success: true}}, trace.Parsers)
+	require.NotNil(t, trace.Event)
+	assert.Equal(t, map[string]any{"level": "info"}, trace.Event.ParsedData)
+
+	trace = pe.Trace(internal.Event{RawData: "plain line", Metadata: internal.Metadata{Tag: "app"}})
+	assert.Equal(t, "filter grep", trace.DroppedBy)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/engine"
+	"github.com/sirupsen/logrus"
+)
+
+// Reload re-reads the config file and applies the changes to the running
+// engine. Only inputs, parsers, filters and outputs whose config changed are
+// replaced. An invalid config is rejected and the running plugins are kept.
+func (e *PluginEngine) Reload() error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	logrus.WithField("file", e.configPath).Info("Reloading config")
+
+	if err := validateConfigFile(e.configPath); err != nil {
+		return err
+	}
+
+	config, err := readConfig(e.configPath)
+	if err != nil {
+		return err
+	}
+
+	plugins, err := e.buildPlugins(config)
+	if err != nil {
+		e.closeUnusedDeadLetters()
+		return err
+	}
+
+	e.Engine.Reload(plugins)
+
+	if systemChanged(e.config.System, config.System) {
+		logrus.Warn("Changes to the System section other than logLevel require a restart")
+	}
+	logrus.SetLevel(config.System.GetLogLevel())
+
+	e.config.System.LogLevel = config.System.LogLevel
+	e.config.Inputs = config.Inputs
+	e.config.Parsers = config.Parsers
+	e.config.ParserChains = config.ParserChains
+	e.config.Filters = config.Filters
+	e.config.Outputs = config.Outputs
+	e.plugins = plugins
+	e.closeUnusedDeadLetters()
+
+	logrus.Info("Config reloaded")
+	return nil
+}
+
+func systemChanged(running, next SystemConfig) bool {
+	next.LogLevel = running.LogLevel
+	return !reflect.DeepEqual(running, next)
+}
+
+// closeUnusedDeadLetters closes the dead letter files no running output
+// writes to anymore
+func (e *PluginEngine) closeUnusedDeadLetters() {
+	used := make(map[*engine.DeadLetter]bool)
+	for _, out := range e.plugins.Outputs {
+		used[out.Options.DeadLetter] = true
+	}
+
+	for path, dl := range e.deadLetters {
+		if !used[dl] {
+			dl.Close()
+			delete(e.deadLetters, path)
+		}
+	}
+}
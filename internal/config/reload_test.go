@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestPluginEngine_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.yaml")
+	writeConfig(t, path, `
+Parsers:
+  - Type: json
+Outputs:
+  - Type: counter
+    Name: kept
+  - Type: counter
+    Name: removed
+`)
+
+	pe, err := NewPluginEngine(path)
+	require.NoError(t, err)
+	require.NoError(t, pe.Start())
+	defer pe.Stop()
+
+	kept := pe.plugins.Outputs[0].Plugin
+	parser := pe.plugins.Parsers[0]
+
+	writeConfig(t, path, `
+Parsers:
+  - Type: json
+Outputs:
+  - Type: counter
+    Name: added
+    Match: "app"
+  - Type: counter
+    Name: kept
+`)
+	require.NoError(t, pe.Reload())
+
+	require.Len(t, pe.plugins.Outputs, 2)
+	assert.Equal(t, "added", pe.plugins.Outputs[0].Plugin.Name())
+	assert.Same(t, kept, pe.plugins.Outputs[1].Plugin)
+	assert.Same(t, parser, pe.plugins.Parsers[0])
+
+	// An invalid config keeps the running plugins
+	writeConfig(t, path, `
+Outputs:
+  - Type: unknown
+`)
+	assert.ErrorContains(t, pe.Reload(), "unknown output type")
+	require.Len(t, pe.plugins.Outputs, 2)
+	assert.Same(t, kept, pe.plugins.Outputs[1].Plugin)
+
+	writeConfig(t, path, "Outputs: [")
+	assert.ErrorContains(t, pe.Reload(), "failed to parse config")
+	assert.Len(t, pe.plugins.Outputs, 2)
+}
+
+func TestFindConfig(t *testing.T) {
+	configs := []map[string]any{
+		{"Type": "counter"},
+		{"Type": "stdout", "Match": "*"},
+		{"Type": "counter"},
+	}
+	used := make([]bool, len(configs))
+
+	assert.Equal(t, 1, findConfig(configs, used, map[string]any{"Type": "stdout", "Match": "*"}, 3))
+	assert.Equal(t, 0, findConfig(configs, used, map[string]any{"Type": "counter"}, 3))
+	assert.Equal(t, 2, findConfig(configs, used, map[string]any{"Type": "counter"}, 3))
+	assert.Equal(t, -1, findConfig(configs, used, map[string]any{"Type": "counter"}, 3))
+	assert.Equal(t, -1, findConfig(configs, make([]bool, 3), map[string]any{"Type": "stdout", "Match": "app"}, 3))
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/filter"
+	filtergrep "github.com/MuchTitan/go-log-forwarder/internal/filter/grep"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	inputexec "github.com/MuchTitan/go-log-forwarder/internal/input/exec"
+	inputforward "github.com/MuchTitan/go-log-forwarder/internal/input/forward"
+	inputgelf "github.com/MuchTitan/go-log-forwarder/internal/input/gelf"
+	inputhttp "github.com/MuchTitan/go-log-forwarder/internal/input/http"
+	inputstdin "github.com/MuchTitan/go-log-forwarder/internal/input/stdin"
+	inputsyslog "github.com/MuchTitan/go-log-forwarder/internal/input/syslog"
+	inputtail "github.com/MuchTitan/go-log-forwarder/internal/input/tail"
+	inputtcp "github.com/MuchTitan/go-log-forwarder/internal/input/tcp"
+	inputudp "github.com/MuchTitan/go-log-forwarder/internal/input/udp"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	outputcounter "github.com/MuchTitan/go-log-forwarder/internal/output/counter"
+	outputforward "github.com/MuchTitan/go-log-forwarder/internal/output/forward"
+	outputgelf "github.com/MuchTitan/go-log-forwarder/internal/output/gelf"
+	outputsplunk "github.com/MuchTitan/go-log-forwarder/internal/output/splunk"
+	outputstdout "github.com/MuchTitan/go-log-forwarder/internal/output/stdout"
+	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	parserjson "github.com/MuchTitan/go-log-forwarder/internal/parser/json"
+	parserregex "github.com/MuchTitan/go-log-forwarder/internal/parser/regex"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+)
+
+// registration is a plugin type that can be configured
+type registration[T internal.Plugin] struct {
+	new    func() T
+	config any // The Config struct the plugin decodes its config into
+}
+
+var inputTypes = map[string]registration[input.Plugin]{
+	"tail":    {func() input.Plugin { return &inputtail.Tail{} }, inputtail.Config{}},
+	"tcp":     {func() input.Plugin { return &inputtcp.TCP{} }, inputtcp.Config{}},
+	"http":    {func() input.Plugin { return &inputhttp.InHTTP{} }, inputhttp.Config{}},
+	"syslog":  {func() input.Plugin { return &inputsyslog.Syslog{} }, inputsyslog.Config{}},
+	"udp":     {func() input.Plugin { return &inputudp.UDP{} }, inputudp.Config{}},
+	"gelf":    {func() input.Plugin { return &inputgelf.GELF{} }, inputgelf.Config{}},
+	"forward": {func() input.Plugin { return &inputforward.Forward{} }, inputforward.Config{}},
+	"stdin":   {func() input.Plugin { return &inputstdin.Stdin{} }, inputstdin.Config{}},
+	"exec":    {func() input.Plugin { return &inputexec.Exec{} }, inputexec.Config{}},
+}
+
+var parserTypes = map[string]registration[parser.Plugin]{
+	"json":  {func() parser.Plugin { return &parserjson.Json{} }, parserjson.Config{}},
+	"regex": {func() parser.Plugin { return &parserregex.Regex{} }, parserregex.Config{}},
+}
+
+var filterTypes = map[string]registration[filter.Plugin]{
+	"grep": {func() filter.Plugin { return &filtergrep.Grep{} }, filtergrep.Config{}},
+}
+
+var outputTypes = map[string]registration[output.Plugin]{
+	"stdout":  {func() output.Plugin { return &outputstdout.Stdout{} }, outputstdout.Config{}},
+	"splunk":  {func() output.Plugin { return &outputsplunk.Splunk{} }, outputsplunk.Config{}},
+	"counter": {func() output.Plugin { return &outputcounter.Counter{} }, outputcounter.Config{}},
+	"gelf":    {func() output.Plugin { return &outputgelf.GELF{} }, outputgelf.Config{}},
+	"forward": {func() output.Plugin { return &outputforward.Forward{} }, outputforward.Config{}},
+}
+
+// newPlugin creates and initializes a plugin of the Type in config
+func newPlugin[T internal.Plugin](kind string, types map[string]registration[T], config map[string]any) (T, error) {
+	var plugin T
+	typ, ok := types[pluginType(config)]
+	if !ok {
+		return plugin, fmt.Errorf("unknown %s type: %s", kind, config["Type"])
+	}
+
+	plugin = typ.new()
+	if err := plugin.Init(config); err != nil {
+		return plugin, err
+	}
+	return plugin, nil
+}
+
+// outputConfig holds the fields the engine reads for every output
+type outputConfig struct {
+	Workers        int             `config:"Workers" min:"1"`
+	QueueSize      int             `config:"QueueSize" min:"1"`
+	OverflowPolicy string          `config:"OverflowPolicy" enum:"block,drop_oldest"`
+	DeadLetterFile string          `config:"DeadLetterFile"`
+	BatchSize      int             `config:"BatchSize" min:"1"`
+	BatchBytes     schema.ByteSize `config:"BatchBytes" min:"1"`
+	FlushInterval  time.Duration   `config:"FlushInterval"`
+	Retry          retryConfig     `config:"Retry"`
+}
+
+type retryConfig struct {
+	MaxAttempts    int           `config:"MaxAttempts" min:"1"`
+	InitialBackoff time.Duration `config:"InitialBackoff"`
+	MaxBackoff     time.Duration `config:"MaxBackoff"`
+	Jitter         *float64      `config:"Jitter" min:"0" max:"1"`
+}
+
+// parserChainConfig is the config of a parser chain
+type parserChainConfig struct {
+	Match     string   `config:"Match" default:"*"`
+	Parsers   []string `config:"Parsers,required"`
+	OnFailure string   `config:"OnFailure" default:"keep_raw" enum:"keep_raw,drop,error_tag"`
+	ErrorTag  string   `config:"ErrorTag"`
+}
+
+var (
+	systemSchema       = schema.Of(SystemConfig{})
+	parserChainSchema  = schema.Of(parserChainConfig{})
+	outputConfigSchema = schema.Of(outputConfig{})
+)
+
+// typeField is the Type every plugin config has
+func typeField(kind, typ string) schema.Field {
+	return schema.Field{
+		Name:        "Type",
+		Kind:        schema.KindString,
+		Required:    true,
+		Description: fmt.Sprintf("Must be set to `%s` to use the %s %s.", typ, typ, kind),
+	}
+}
+
+// schemas returns the schema of every plugin type, extended by the fields
+// the engine reads
+func schemas[T internal.Plugin](kind string, types map[string]registration[T], engineFields schema.Schema) map[string]schema.Schema {
+	result := make(map[string]schema.Schema, len(types))
+	for name, typ := range types {
+		result[name] = schema.Schema{typeField(kind, name)}.With(schema.Of(typ.config)).With(engineFields)
+	}
+	return result
+}
+
+var (
+	inputSchemas  = schemas("input", inputTypes, nil)
+	parserSchemas = schemas("parser", parserTypes, nil)
+	filterSchemas = schemas("filter", filterTypes, nil)
+	outputSchemas = schemas("output", outputTypes, outputConfigSchema)
+)
+
+// PluginDoc is the reference of the config of a plugin type
+type PluginDoc struct {
+	Kind   string // input, parser, filter or output
+	Type   string
+	Schema schema.Schema // Without the fields the engine reads for every output
+}
+
+// PluginDocs returns the reference of every plugin type, sorted by kind and type
+func PluginDocs() []PluginDoc {
+	var docs []PluginDoc
+	docs = appendDocs(docs, "input", inputTypes)
+	docs = appendDocs(docs, "parser", parserTypes)
+	docs = appendDocs(docs, "filter", filterTypes)
+	docs = appendDocs(docs, "output", outputTypes)
+	return docs
+}
+
+func appendDocs[T internal.Plugin](docs []PluginDoc, kind string, types map[string]registration[T]) []PluginDoc {
+	for _, name := range slices.Sorted(maps.Keys(types)) {
+		docs = append(docs, PluginDoc{
+			Kind:   kind,
+			Type:   name,
+			Schema: schema.Schema{typeField(kind, name)}.With(schema.Of(types[name].config)),
+		})
+	}
+	return docs
+}
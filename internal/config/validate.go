@@ -0,0 +1,335 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a single error found while validating a config file
+type Problem struct {
+	Section string // e.g. Inputs, empty for the file itself
+	Index   int    // Index of the plugin in the section, -1 for System
+	Field   string
+	Line    int
+	Message string
+}
+
+func (p Problem) Error() string {
+	location := p.Section
+	if p.Index >= 0 {
+		location = fmt.Sprintf("%s[%d]", p.Section, p.Index)
+	}
+	if p.Field != "" {
+		location += "." + p.Field
+	}
+
+	msg := p.Message
+	if location != "" {
+		msg = location + ": " + msg
+	}
+	if p.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", p.Line, msg)
+	}
+	return msg
+}
+
+// ValidationError holds every problem found in a config file
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("invalid config, found %d problem(s):", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the config file at path without creating any plugin, so
+// no port or file is opened. It returns an error only if the file can't be
+// read.
+func Validate(path string) ([]Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return validateData([]byte(os.ExpandEnv(string(data)))), nil
+}
+
+// validateConfigFile returns a ValidationError for every problem in the config file
+func validateConfigFile(path string) error {
+	problems, err := Validate(path)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validateData(data []byte) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []Problem{{Index: -1, Message: fmt.Sprintf("failed to parse config: %v", err)}}
+	}
+	if len(root.Content) == 0 {
+		return []Problem{{Index: -1, Message: "config file is empty"}}
+	}
+
+	v := &validator{}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		v.add("", -1, "", doc, "expected a map of sections")
+		return v.problems
+	}
+
+	var parserNames []string
+	var chains *yaml.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		switch key.Value {
+		case "System":
+			v.validateSystem(value)
+		case "Inputs":
+			v.validatePlugins("Inputs", "input", value, inputSchemas)
+		case "Parsers":
+			parserNames = v.validatePlugins("Parsers", "parser", value, parserSchemas)
+		case "ParserChains":
+			chains = value
+		case "Filters":
+			v.validatePlugins("Filters", "filter", value, filterSchemas)
+		case "Outputs":
+			v.validatePlugins("Outputs", "output", value, outputSchemas)
+		default:
+			v.add(key.Value, -1, "", key, "unknown section")
+		}
+	}
+	// Chains refer to the parsers by name, which may be defined below them
+	if chains != nil {
+		v.validateParserChains(chains, parserNames)
+	}
+
+	slices.SortStableFunc(v.problems, func(a, b Problem) int { return cmp.Compare(a.Line, b.Line) })
+	return v.problems
+}
+
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) add(section string, index int, field string, node *yaml.Node, format string, args ...any) {
+	v.problems = append(v.problems, Problem{
+		Section: section,
+		Index:   index,
+		Field:   field,
+		Line:    node.Line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validateSystem(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.add("System", -1, "", node, "expected a map, got %s", describe(node))
+		return
+	}
+	if !v.check("System", -1, node, systemSchema) {
+		return
+	}
+
+	// Let the engine check the values it depends on
+	var system SystemConfig
+	if err := node.Decode(&system); err != nil {
+		return
+	}
+	if _, err := system.EngineSettings(); err != nil {
+		v.add("System", -1, "", node, "%v", err)
+	}
+}
+
+// validatePlugins checks every plugin of a section and returns their names
+func (v *validator) validatePlugins(section, pluginKind string, node *yaml.Node, schemas map[string]schema.Schema) []string {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		v.add(section, -1, "", node, "expected a list, got %s", describe(node))
+		return nil
+	}
+
+	var names []string
+	for i, plugin := range node.Content {
+		if plugin.Kind != yaml.MappingNode {
+			v.add(section, i, "", plugin, "expected a map, got %s", describe(plugin))
+			continue
+		}
+
+		typeNode := mapValue(plugin, "Type")
+		if typeNode == nil {
+			v.add(section, i, "Type", plugin, "missing required field")
+			continue
+		}
+		if !isScalar(typeNode, "!!str") {
+			v.add(section, i, "Type", typeNode, "expected a string, got %s", describe(typeNode))
+			continue
+		}
+
+		pluginType := strings.ToLower(typeNode.Value)
+		s, ok := schemas[pluginType]
+		if !ok {
+			v.add(section, i, "Type", typeNode, "unknown %s type '%s', expected one of %s",
+				pluginKind, typeNode.Value, strings.Join(sortedKeys(schemas), ", "))
+			continue
+		}
+		v.check(section, i, plugin, s)
+
+		name := pluginType
+		if nameNode := mapValue(plugin, "Name"); nameNode != nil && nameNode.Value != "" {
+			name = nameNode.Value
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (v *validator) validateParserChains(node *yaml.Node, parserNames []string) {
+	const section = "ParserChains"
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	if node.Kind != yaml.SequenceNode {
+		v.add(section, -1, "", node, "expected a list, got %s", describe(node))
+		return
+	}
+
+	for i, chain := range node.Content {
+		if chain.Kind != yaml.MappingNode {
+			v.add(section, i, "", chain, "expected a map, got %s", describe(chain))
+			continue
+		}
+
+		v.check(section, i, chain, parserChainSchema)
+
+		if parsers := mapValue(chain, "Parsers"); parsers != nil && parsers.Kind == yaml.SequenceNode {
+			if len(parsers.Content) == 0 {
+				v.add(section, i, "Parsers", parsers, "needs at least one parser")
+			}
+			for _, name := range parsers.Content {
+				if !slices.Contains(parserNames, name.Value) {
+					v.add(section, i, "Parsers", name, "unknown parser '%s'", name.Value)
+				}
+			}
+		}
+
+		onFailure := mapValue(chain, "OnFailure")
+		if onFailure != nil && strings.EqualFold(onFailure.Value, "error_tag") && mapValue(chain, "ErrorTag") == nil {
+			v.add(section, i, "ErrorTag", chain, "OnFailure 'error_tag' needs an ErrorTag")
+		}
+	}
+}
+
+// check checks a map against a schema and reports whether it is valid
+func (v *validator) check(section string, index int, node *yaml.Node, s schema.Schema) bool {
+	var config map[string]any
+	if err := node.Decode(&config); err != nil {
+		v.add(section, index, "", node, "%v", err)
+		return false
+	}
+
+	errs := s.Check(config)
+	for _, err := range errs {
+		v.add(section, index, err.Field, locate(node, err), "%s", err.Message)
+	}
+	return len(errs) == 0
+}
+
+// locate returns the node of the field a problem refers to, for a missing
+// field the map that lacks it
+func locate(node *yaml.Node, err *schema.FieldError) *yaml.Node {
+	path := strings.Split(err.Field, ".")
+	for i, name := range path {
+		// Items of a list are named by their index, e.g. Tokens[0]
+		name, index, isItem := strings.Cut(strings.TrimSuffix(name, "]"), "[")
+		key, value := mapEntry(node, name)
+		if key == nil {
+			break
+		}
+		if i == len(path)-1 && err.Unknown {
+			return key
+		}
+		node = value
+		if isItem {
+			idx, convErr := strconv.Atoi(index)
+			if convErr != nil || node.Kind != yaml.SequenceNode || idx >= len(node.Content) {
+				break
+			}
+			node = node.Content[idx]
+		}
+	}
+	return node
+}
+
+// describe names the type of a node for error messages
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a map"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+
+	switch node.Tag {
+	case "!!str":
+		return fmt.Sprintf("the string '%s'", node.Value)
+	case "!!int":
+		return fmt.Sprintf("the int %s", node.Value)
+	case "!!float":
+		return fmt.Sprintf("the number %s", node.Value)
+	case "!!bool":
+		return fmt.Sprintf("the bool %s", node.Value)
+	case "!!null":
+		return "nothing"
+	}
+	return node.Value
+}
+
+func isScalar(node *yaml.Node, tag string) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == tag
+}
+
+// mapValue returns the value of key in a mapping node, nil if it is missing
+func mapValue(node *yaml.Node, key string) *yaml.Node {
+	_, value := mapEntry(node, key)
+	return value
+}
+
+// mapEntry returns the key and value nodes of key in a mapping node
+func mapEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
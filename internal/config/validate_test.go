@@ -0,0 +1,212 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid config",
+			config: `
+System:
+  logLevel: debug
+  FlushInterval: 2s
+Inputs:
+  - Type: tail
+    Glob: "./logs/*.log"
+    EnableDB: true
+  - Type: tcp
+    BufferSize: 128KiB
+    Timeout: 5m
+Parsers:
+  - Type: regex
+    Name: nginx
+    Pattern: '^(?P<ip>\S+)'
+    TimeFormat: "02/Jan/2006:15:04:05 -0700"
+  - Type: json
+ParserChains:
+  - Match: "web*"
+    Parsers: [nginx, json]
+    OnFailure: error_tag
+    ErrorTag: parse_error
+Filters:
+  - Type: grep
+    Include: ["error"]
+Outputs:
+  - Type: stdout
+    Format: plain
+    Retry:
+      MaxAttempts: 5
+      Jitter: 0.5
+    BatchBytes: 5MB
+`,
+		},
+		{
+			name: "unknown keys",
+			config: `
+Sytem: {}
+Inputs:
+  - Type: tail
+    Glob: "*.log"
+    port: 80
+    Colour: true
+`,
+			want: []string{
+				"line 2: Sytem: unknown section",
+				"line 6: Inputs[0].port: unknown field",
+				"line 7: Inputs[0].Colour: unknown field",
+			},
+		},
+		{
+			name: "wrong types",
+			config: `
+System:
+  FailFast: "yes"
+Inputs:
+  - Type: http
+    Port: "8080"
+Outputs:
+  - Type: splunk
+    Token: abc
+    EventIndex: main
+    Compress: 1
+    Retry:
+      MaxAttempts: 0
+      Jitter: 2
+`,
+			want: []string{
+				"line 3: System.FailFast: expected a bool, got the string 'yes'",
+				"line 6: Inputs[0].Port: expected an int, got the string '8080'",
+				"line 11: Outputs[0].Compress: expected a bool, got the int 1",
+				"line 13: Outputs[0].Retry.MaxAttempts: has to be at least 1, got 0",
+				"line 14: Outputs[0].Retry.Jitter: has to be between 0 and 1, got 2",
+			},
+		},
+		{
+			name: "missing fields",
+			config: `
+Inputs:
+  - Tag: app
+  - Type: tail
+Outputs:
+  - Type: gelf
+    Port: 12201
+`,
+			want: []string{
+				"line 3: Inputs[0].Type: missing required field",
+				"line 4: Inputs[1].Glob: missing required field",
+				"line 6: Outputs[0].HostKey: missing required field",
+			},
+		},
+		{
+			name: "invalid values",
+			config: `
+Parsers:
+  - Type: regex
+    Pattern: "(unclosed"
+    TimeFormat: "yesterday"
+Filters:
+  - Type: grep
+    Exclude: ["[a-"]
+Inputs:
+  - Type: http
+    BufferSize: lots
+Outputs:
+  - Type: stdout
+    Format: xml
+    FlushInterval: soon
+`,
+			want: []string{
+				"line 4: Parsers[0].Pattern: invalid regular expression: error parsing regexp: missing closing ): `(unclosed`",
+				"line 5: Parsers[0].TimeFormat: invalid time format 'yesterday', expected a Go layout like 2006-01-02T15:04:05Z07:00",
+				"line 8: Filters[0].Exclude: invalid regular expression '[a-': error parsing regexp: missing closing ]: `[a-`",
+				"line 11: Inputs[0].BufferSize: invalid byte size 'lots', expected a size like 64KiB",
+				"line 14: Outputs[0].Format: unsupported value 'xml', expected one of json, plain, template",
+				"line 15: Outputs[0].FlushInterval: invalid duration 'soon', expected a positive duration like 30s",
+			},
+		},
+		{
+			name: "unknown plugin types",
+			config: `
+Inputs:
+  - Type: kafka
+Outputs:
+  - Type: 42
+`,
+			want: []string{
+				"line 3: Inputs[0].Type: unknown input type 'kafka', expected one of exec, forward, gelf, http, stdin, syslog, tail, tcp, udp",
+				"line 5: Outputs[0].Type: expected a string, got the int 42",
+			},
+		},
+		{
+			name: "parser chains",
+			config: `
+ParserChains:
+  - Parsers: [missing]
+    OnFailure: error_tag
+Parsers:
+  - Type: json
+`,
+			want: []string{
+				"line 3: ParserChains[0].Parsers: unknown parser 'missing'",
+				"line 3: ParserChains[0].ErrorTag: OnFailure 'error_tag' needs an ErrorTag",
+			},
+		},
+		{
+			name: "lists of maps",
+			config: `
+Inputs:
+  - Type: http
+    Auth:
+      Tokens:
+        - Token: abc
+        - Tag: team-b
+          Teg: team-b
+`,
+			want: []string{
+				"line 7: Inputs[0].Auth.Tokens[1].Token: missing required field",
+				"line 8: Inputs[0].Auth.Tokens[1].Teg: unknown field",
+			},
+		},
+		{
+			name:   "syntax error",
+			config: "Outputs: [",
+			want:   []string{"failed to parse config: yaml: line 1: did not find expected node content"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range validateData([]byte(tt.config)) {
+				got = append(got, p.Error())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewPluginEngine_InvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.yaml")
+	writeConfig(t, path, `
+Inputs:
+  - Type: tcp
+    Port: "6666"
+  - Name: missing-type
+`)
+
+	_, err := NewPluginEngine(path)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 2)
+	assert.ErrorContains(t, err, "line 4: Inputs[0].Port: expected an int")
+}
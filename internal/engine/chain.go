@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/MuchTitan/go-log-forwarder/internal/util"
+)
+
+// OnFailure decides what happens to an event no parser of a chain could parse
+type OnFailure string
+
+const (
+	OnFailureKeepRaw  OnFailure = "keep_raw"  // Pass the event on unparsed
+	OnFailureDrop     OnFailure = "drop"      // Drop the event
+	OnFailureErrorTag OnFailure = "error_tag" // Pass the event on unparsed with the ErrorTag
+)
+
+// ParserChain is an ordered list of parsers that is tried for the events
+// matching its tag. The first parser that parses the raw data wins, parsers
+// with a KeyName are applied on top of it.
+type ParserChain struct {
+	Match     string
+	Parsers   []parser.Plugin
+	OnFailure OnFailure
+	ErrorTag  string
+}
+
+func (c *ParserChain) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, c.Match)
+}
+
+// parse runs the event through the chain. It returns false if the event
+// has to be dropped.
+func (c *ParserChain) parse(event *internal.Event, trace *Trace) bool {
+	if trace != nil {
+		trace.Chain = c.Match
+	}
+	if runParsers(c.Parsers, event, false, trace) {
+		return true
+	}
+
+	switch c.OnFailure {
+	case OnFailureDrop:
+		if trace != nil {
+			trace.DroppedBy = "parser chain " + c.Match
+		}
+		return false
+	case OnFailureErrorTag:
+		event.Metadata.Tag = c.ErrorTag
+	}
+	return true
+}
+
+// parse runs the event through the first parser chain matching its tag.
+// Without a matching chain the parsers matching the tag are run in the
+// configured order and the event is kept unparsed if none succeeds. It
+// returns false if the event has to be dropped. With a trace the parsers
+// that were tried are recorded.
+func (e *Engine) parse(event *internal.Event, trace *Trace) bool {
+	for _, chain := range e.chains {
+		if chain.MatchTag(event.Metadata.Tag) {
+			return chain.parse(event, trace)
+		}
+	}
+
+	runParsers(e.parsers, event, true, trace)
+	return true
+}
+
+// runParsers runs the parsers of the raw data until one succeeds, and every
+// parser that decodes a field of the parsed data, which allows to decode
+// wrapped formats layer by layer. With matchTag parsers whose Match doesn't
+// cover the tag of the event are skipped. It reports whether any parser
+// succeeded.
+func runParsers(parsers []parser.Plugin, event *internal.Event, matchTag bool, trace *Trace) bool {
+	parsed, rawParsed := false, false
+	for _, p := range parsers {
+		if matchTag && !p.MatchTag(event.Metadata.Tag) {
+			continue
+		}
+		field := parser.DecodesField(p)
+		if rawParsed && !field {
+			continue
+		}
+		ok := p.Process(event)
+		parserEvents.WithLabelValues(p.Name(), parserResult(ok)).Inc()
+		if trace != nil {
+			trace.Parsers = append(trace.Parsers, ParserResult{Parser: p.Name(), Success: ok})
+		}
+		if ok {
+			parsed = true
+			rawParsed = rawParsed || !field
+		}
+	}
+	return parsed
+}
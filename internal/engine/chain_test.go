@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/MuchTitan/go-log-forwarder/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixParser parses lines starting with its prefix
+type prefixParser struct {
+	name  string
+	match string
+}
+
+func (p *prefixParser) Name() string                     { return p.name }
+func (p *prefixParser) Init(config map[string]any) error { return nil }
+func (p *prefixParser) Exit() error                      { return nil }
+
+func (p *prefixParser) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, p.match)
+}
+
+func (p *prefixParser) Process(event *internal.Event) bool {
+	if !strings.HasPrefix(event.RawData, p.name+":") {
+		return false
+	}
+	event.ParsedData = map[string]any{"parser": p.name}
+	return true
+}
+
+func TestEngine_Parse(t *testing.T) {
+	nginx := &prefixParser{name: "nginx", match: "nginx"}
+	json := &prefixParser{name: "json", match: "*"}
+
+	e := NewEngine(DefaultSettings())
+	e.RegisterParser(nginx)
+	e.RegisterParser(json)
+	e.RegisterParserChain(ParserChain{
+		Match:     "strict",
+		Parsers:   []parser.Plugin{json},
+		OnFailure: OnFailureDrop,
+	})
+	e.RegisterParserChain(ParserChain{
+		Match:     "web*",
+		Parsers:   []parser.Plugin{nginx, json},
+		OnFailure: OnFailureErrorTag,
+		ErrorTag:  "parse_error",
+	})
+
+	tests := []struct {
+		name       string
+		tag        string
+		line       string
+		wantKeep   bool
+		wantParser string
+		wantTag    string
+	}{
+		{"parser matching the tag", "nginx", "nginx:GET /", true, "nginx", "nginx"},
+		{"parser not matching the tag", "http", "nginx:GET /", true, "", "http"},
+		{"fall back to next parser", "nginx", "json:{}", true, "json", "nginx"},
+		{"keep unparsed without chain", "http", "plain", true, "", "http"},
+		{"chain in order", "web", "nginx:GET /", true, "nginx", "web"},
+		{"chain fallback", "web", "json:{}", true, "json", "web"},
+		{"chain error tag", "web", "plain", true, "", "parse_error"},
+		{"chain drop", "strict", "plain", false, "", "strict"},
+		{"chain ignores parser match", "strict", "json:{}", true, "json", "strict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &internal.Event{RawData: tt.line, Metadata: internal.Metadata{Tag: tt.tag}}
+
+			assert.Equal(t, tt.wantKeep, e.parse(event, nil))
+			assert.Equal(t, tt.wantTag, event.Metadata.Tag)
+			if tt.wantParser == "" {
+				assert.Nil(t, event.ParsedData)
+			} else {
+				assert.Equal(t, tt.wantParser, event.ParsedData["parser"])
+			}
+		})
+	}
+}
+
+// dockerParser wraps the raw data after "docker:" into the field log
+type dockerParser struct {
+	prefixParser
+}
+
+func (d *dockerParser) Process(event *internal.Event) bool {
+	log, ok := strings.CutPrefix(event.RawData, "docker:")
+	if !ok {
+		return false
+	}
+	event.ParsedData = map[string]any{"log": log}
+	return true
+}
+
+// fieldParser decodes the field log
+type fieldParser struct {
+	prefixParser
+}
+
+func (f *fieldParser) KeyName() string { return "log" }
+
+func (f *fieldParser) Process(event *internal.Event) bool {
+	log, ok := event.ParsedData["log"].(string)
+	if !ok {
+		return false
+	}
+	event.ParsedData["message"] = strings.ToUpper(log)
+	return true
+}
+
+func TestEngine_ParseLayered(t *testing.T) {
+	docker := &dockerParser{prefixParser{name: "docker", match: "*"}}
+	json := &prefixParser{name: "json", match: "*"}
+	field := &fieldParser{prefixParser{name: "field", match: "*"}}
+
+	e := NewEngine(DefaultSettings())
+	e.RegisterParser(docker)
+	e.RegisterParser(json)
+	e.RegisterParser(field)
+
+	// The field parser decodes what the parser of the raw data produced
+	event := &internal.Event{RawData: "docker:started"}
+	assert.True(t, e.parse(event, nil))
+	assert.Equal(t, map[string]any{"log": "started", "message": "STARTED"}, event.ParsedData)
+
+	// Only the first parser of the raw data is applied
+	event = &internal.Event{RawData: "json:{}"}
+	assert.True(t, e.parse(event, nil))
+	assert.Equal(t, map[string]any{"parser": "json"}, event.ParsedData)
+
+	// A field parser alone counts as success of a chain
+	chain := ParserChain{Match: "*", Parsers: []parser.Plugin{docker, field}, OnFailure: OnFailureDrop}
+	event = &internal.Event{RawData: "plain", ParsedData: map[string]any{"log": "wrapped"}}
+	assert.True(t, chain.parse(event, nil))
+	assert.Equal(t, "WRAPPED", event.ParsedData["message"])
+
+	event = &internal.Event{RawData: "plain"}
+	assert.False(t, chain.parse(event, nil))
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+)
+
+// DeadLetter is a JSONL file that receives the events an output
+// could not write after all retries
+type DeadLetter struct {
+	path string
+	file *os.File
+	mu   sync.Mutex
+}
+
+type deadLetterEntry struct {
+	Time   time.Time      `json:"time"`
+	Output string         `json:"output"`
+	Error  string         `json:"error"`
+	Event  internal.Event `json:"event"`
+}
+
+func NewDeadLetter(path string) (*DeadLetter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open dead letter file: %w", err)
+	}
+	return &DeadLetter{path: path, file: file}, nil
+}
+
+func (d *DeadLetter) Path() string {
+	return d.path
+}
+
+// Write appends one line per event to the dead letter file
+func (d *DeadLetter) Write(outputName string, lastErr error, events []internal.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	encoder := json.NewEncoder(d.file)
+	for _, event := range events {
+		entry := deadLetterEntry{
+			Time:   now,
+			Output: outputName,
+			Error:  lastErr.Error(),
+			Event:  event,
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("could not write to dead letter file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *DeadLetter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.file.Close()
+}
@@ -2,40 +2,104 @@ package engine
 
 import (
 	"context"
+	"fmt"
 	"sync"
-	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/filter"
 	"github.com/MuchTitan/go-log-forwarder/internal/input"
 	"github.com/MuchTitan/go-log-forwarder/internal/output"
 	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/MuchTitan/go-log-forwarder/internal/queue"
 	"github.com/sirupsen/logrus"
 )
 
+const defaultPipelineBufferSize = 1000
+
+// Settings are the engine wide settings from the System section
+type Settings struct {
+	PipelineBufferSize int
+	Batch              BatchSettings // Defaults for every output
+	FailFast           bool          // Fail Start if an input can't be started
+	InputRestart       RetryPolicy   // Backoff between restarts of a failed input, MaxAttempts is ignored
+	// ReadyQueueThreshold is the fraction (0-1) of an output queue that may
+	// be filled before the engine is not ready anymore
+	ReadyQueueThreshold float64
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		PipelineBufferSize:  defaultPipelineBufferSize,
+		Batch:               DefaultBatchSettings(),
+		InputRestart:        DefaultInputRestartPolicy(),
+		ReadyQueueThreshold: defaultReadyQueueThreshold,
+	}
+}
+
 type Engine struct {
-	inputs   []input.Plugin
+	inputs   []*inputRunner
 	parsers  []parser.Plugin
+	chains   []ParserChain
 	filters  []filter.Plugin
-	outputs  []output.Plugin
+	outputs  []*outputWorker
+	mu       sync.RWMutex // Guards the plugin lists against the admin API
+	settings Settings
 	pipeline chan internal.Event
+	reloadCh chan func()
+	queue    *queue.Queue
 	wg       sync.WaitGroup
 	ctx      context.Context
 	cancel   context.CancelFunc
+
+	shutdown     chan struct{} // Closed once an input requested a shutdown
+	shutdownOnce sync.Once
 }
 
-func NewEngine() *Engine {
-	ctx, cancel := context.WithCancel(context.Background())
-	return &Engine{
-		pipeline: make(chan internal.Event),
-		ctx:      ctx,
-		cancel:   cancel,
+func NewEngine(settings Settings) *Engine {
+	if settings.PipelineBufferSize < 0 {
+		settings.PipelineBufferSize = 0
+	}
+	settings.Batch = settings.Batch.withDefaults(DefaultBatchSettings())
+	if settings.InputRestart.InitialBackoff <= 0 {
+		settings.InputRestart = DefaultInputRestartPolicy()
+	}
+	if settings.ReadyQueueThreshold <= 0 || settings.ReadyQueueThreshold > 1 {
+		settings.ReadyQueueThreshold = defaultReadyQueueThreshold
 	}
+
+	e := &Engine{
+		settings: settings,
+		pipeline: make(chan internal.Event, settings.PipelineBufferSize),
+		reloadCh: make(chan func()),
+		shutdown: make(chan struct{}),
+	}
+	// Inputs are started with contexts derived from the engine context
+	e.ctx, e.cancel = context.WithCancel(input.WithShutdown(context.Background(), e.requestShutdown))
+	return e
+}
+
+func (e *Engine) requestShutdown() {
+	e.shutdownOnce.Do(func() {
+		logrus.Info("Input requested a shutdown")
+		close(e.shutdown)
+	})
+}
+
+// ShutdownRequested is closed once an input asked the forwarder to shut
+// down. The engine keeps running until Stop is called.
+func (e *Engine) ShutdownRequested() <-chan struct{} {
+	return e.shutdown
+}
+
+// SetQueue enables persisting events to the disk queue before they are
+// handed to the outputs
+func (e *Engine) SetQueue(q *queue.Queue) {
+	e.queue = q
 }
 
 // RegisterInput adds an input plugin to the engine
 func (e *Engine) RegisterInput(input input.Plugin) {
-	e.inputs = append(e.inputs, input)
+	e.inputs = append(e.inputs, &inputRunner{Plugin: input})
 }
 
 // RegisterParser adds an parser plugin to the engine
@@ -43,28 +107,41 @@ func (e *Engine) RegisterParser(parser parser.Plugin) {
 	e.parsers = append(e.parsers, parser)
 }
 
+// RegisterParserChain adds a parser chain to the engine, chains are
+// matched in the order they are registered
+func (e *Engine) RegisterParserChain(chain ParserChain) {
+	e.chains = append(e.chains, chain)
+}
+
 // RegisterFilter adds a filter plugin to the engine
 func (e *Engine) RegisterFilter(filter filter.Plugin) {
 	e.filters = append(e.filters, filter)
 }
 
 // RegisterOutput adds an output plugin to the engine
-func (e *Engine) RegisterOutput(output output.Plugin) {
-	e.outputs = append(e.outputs, output)
+func (e *Engine) RegisterOutput(output output.Plugin, opts OutputOptions) {
+	opts.Batch = opts.Batch.withDefaults(e.settings.Batch)
+	e.outputs = append(e.outputs, newOutputWorker(output, opts))
 }
 
 // Start begins the processing pipeline
 func (e *Engine) Start() error {
+	// Start output workers
+	for _, output := range e.outputs {
+		output.start(e.ctx)
+	}
+
+	if e.queue != nil {
+		if err := e.replayQueue(); err != nil {
+			return fmt.Errorf("could not replay disk queue: %w", err)
+		}
+	}
+
 	// Start input plugins
 	for _, in := range e.inputs {
-		e.wg.Add(1)
-		go func(in input.Plugin) {
-			defer e.wg.Done()
-			if err := in.Start(e.ctx, e.pipeline); err != nil {
-				// TODO: Implement proper error handling (error channel?)
-				logrus.WithError(err).Errorf("Coundnt start input: %s.", in.Name())
-			}
-		}(in)
+		if err := e.startInput(in, e.settings.FailFast); err != nil {
+			return err
+		}
 	}
 
 	// Start processing worker
@@ -78,85 +155,171 @@ func (e *Engine) Start() error {
 func (e *Engine) processRecords() {
 	defer e.wg.Done()
 
-	buffer := make([]internal.Event, 0, 200)
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
 	for {
 		select {
 		case <-e.ctx.Done():
-			return
+			// Process what the inputs already handed over
+			for {
+				select {
+				case event := <-e.pipeline:
+					e.process(event)
+				default:
+					return
+				}
+			}
 
 		case event := <-e.pipeline:
-			processedEvent := &event
+			e.process(event)
 
-			for _, parser := range e.parsers {
-				if ok := parser.Process(processedEvent); ok {
-					break
+		case swap := <-e.reloadCh:
+			// Process what the inputs already handed over with the old plugins
+			for drained := false; !drained; {
+				select {
+				case event := <-e.pipeline:
+					e.process(event)
+				default:
+					drained = true
 				}
 			}
+			swap()
+		}
+	}
+}
 
-			// Apply filters
-			for _, filter := range e.filters {
-				if !filter.MatchTag(event.Metadata.Tag) {
-					continue
-				}
-				var err error
-				processedEvent, err = filter.Process(processedEvent)
-				if err != nil {
-					logrus.WithError(err).Errorf("Coundnt filter event")
-					continue
-				}
-				if processedEvent == nil {
-					// Event was filtered out
-					break
-				}
-			}
+// process runs an event through the parsers and filters and hands it to
+// the outputs
+func (e *Engine) process(event internal.Event) {
+	inputEvents.WithLabelValues(event.Metadata.InputSource, event.Metadata.Tag).Inc()
+	pipelineDepth.WithLabelValues().Set(float64(len(e.pipeline)))
 
-			if processedEvent != nil {
-				buffer = append(buffer, *processedEvent)
-			}
+	processedEvent := e.transform(&event, nil)
+	if processedEvent == nil {
+		// Nothing to deliver, so the input can move on
+		event.Ack()
+		return
+	}
 
-			// Flush if buffer is full
-			if len(buffer) >= 100 {
-				e.flush(buffer)
-				buffer = buffer[:0]
+	var record *queue.Record
+	if e.queue != nil {
+		var err error
+		if record, err = e.queue.Append(*processedEvent); err != nil {
+			logrus.WithError(err).Error("[Engine] Coundnt persist event to disk queue")
+		}
+	}
+	// The workers drain the output queues until the engine is stopped, so
+	// the event is handed over even if the engine is shutting down already.
+	// Giving up would lose the events the inputs handed over.
+	e.dispatch(context.Background(), *processedEvent, record)
+}
+
+// transform runs an event through the parsers and filters. It returns nil
+// if the event was dropped. With a trace every step is recorded.
+func (e *Engine) transform(event *internal.Event, trace *Trace) *internal.Event {
+	if !e.parse(event, trace) {
+		return nil
+	}
+
+	for _, filter := range e.filters {
+		if !filter.MatchTag(event.Metadata.Tag) {
+			continue
+		}
+		var before string
+		if trace != nil {
+			before = snapshot(event)
+		}
+
+		processed, err := filter.Process(event)
+		if err != nil {
+			logrus.WithError(err).Errorf("Coundnt filter event")
+			trace.addFilter(filter.Name(), FilterFailed, err)
+			continue
+		}
+		if processed == nil {
+			// Event was filtered out
+			filterDropped.WithLabelValues(filter.Name()).Inc()
+			trace.addFilter(filter.Name(), FilterDropped, nil)
+			if trace != nil {
+				trace.DroppedBy = "filter " + filter.Name()
 			}
+			return nil
+		}
 
-		case <-ticker.C:
-			// Periodic flush
-			if len(buffer) > 0 {
-				e.flush(buffer)
-				buffer = buffer[:0]
+		event = processed
+		if trace != nil {
+			result := FilterPassed
+			if snapshot(event) != before {
+				result = FilterModified
 			}
+			trace.addFilter(filter.Name(), result, nil)
 		}
 	}
+	return event
 }
 
-// flush writes records to all output plugins
-func (e *Engine) flush(records []internal.Event) {
+// dispatch hands an event to the queue of every output matching its tag
+func (e *Engine) dispatch(ctx context.Context, event internal.Event, record *queue.Record) {
+	matches := 0
+	for _, output := range e.outputs {
+		if output.MatchTag(event.Metadata.Tag) {
+			matches++
+		}
+	}
+	if matches == 0 {
+		record.Ack()
+		event.Ack()
+		return
+	}
+
+	d := newDelivery(event, record, matches)
 	for _, output := range e.outputs {
-		if err := output.Write(records); err != nil {
-			logrus.WithError(err).WithField("writer", output.Name()).Error("[Engine] Coundnt write to output")
+		if output.MatchTag(event.Metadata.Tag) {
+			output.enqueue(ctx, d)
 		}
 	}
 }
 
+// replayQueue dispatches events left in the disk queue by a previous run.
+// Events that still can't be delivered stay queued for the next start.
+func (e *Engine) replayQueue() error {
+	return e.queue.Replay(func(event internal.Event, record *queue.Record) {
+		e.dispatch(e.ctx, event, record)
+	})
+}
+
 // Stop gracefully shuts down the engine
 func (e *Engine) Stop() error {
 	e.cancel()
 	e.wg.Wait()
 
+	// Drain the output queues
+	for _, output := range e.outputs {
+		output.stop()
+	}
+
 	// Cleanup plugins
 	for _, input := range e.inputs {
-		input.Exit()
+		input.exit()
+	}
+	for _, parser := range e.parsers {
+		parser.Exit()
 	}
 	for _, filter := range e.filters {
 		filter.Exit()
 	}
+	closed := make(map[*DeadLetter]bool)
 	for _, output := range e.outputs {
 		output.Flush()
 		output.Exit()
+		if dl := output.opts.DeadLetter; dl != nil && !closed[dl] {
+			closed[dl] = true
+			dl.Close()
+		}
+	}
+
+	if e.queue != nil {
+		if err := e.queue.Close(); err != nil {
+			logrus.WithError(err).Error("[Engine] Coundnt close disk queue")
+		}
 	}
 
 	return nil
@@ -0,0 +1,375 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/queue"
+	"github.com/MuchTitan/go-log-forwarder/internal/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockOutput records written events and fails the first failures writes
+type mockOutput struct {
+	name     string
+	match    string
+	failures int
+	mu       sync.Mutex
+	writes   int
+	batches  []int
+	events   []internal.Event
+}
+
+func (m *mockOutput) Name() string                     { return m.name }
+func (m *mockOutput) Init(config map[string]any) error { return nil }
+func (m *mockOutput) Exit() error                      { return nil }
+func (m *mockOutput) Flush() error                     { return nil }
+
+func (m *mockOutput) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, m.match)
+}
+
+func (m *mockOutput) Write(events []internal.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writes++
+	m.batches = append(m.batches, len(events))
+	if m.writes <= m.failures {
+		return errors.New("service unavailable")
+	}
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *mockOutput) written() []internal.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]internal.Event(nil), m.events...)
+}
+
+func fastRetry(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+func newTestWorker(out *mockOutput, opts OutputOptions) *outputWorker {
+	w := newOutputWorker(out, opts)
+	w.ctx = context.Background()
+	return w
+}
+
+func testDeliveries(tag string, lines ...string) []*delivery {
+	deliveries := make([]*delivery, 0, len(lines))
+	for _, line := range lines {
+		event := internal.Event{
+			RawData:  line,
+			Metadata: internal.Metadata{Tag: tag},
+		}
+		deliveries = append(deliveries, newDelivery(event, nil, 1))
+	}
+	return deliveries
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 10*time.Second, policy.Backoff(5))
+	assert.Equal(t, 10*time.Second, policy.Backoff(50))
+
+	policy.Jitter = 0.5
+	for range 100 {
+		backoff := policy.Backoff(1)
+		assert.GreaterOrEqual(t, backoff, 500*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 1500*time.Millisecond)
+	}
+}
+
+func TestOutputWorker_FlushRetries(t *testing.T) {
+	out := &mockOutput{name: "flaky", match: "*", failures: 2}
+	w := newTestWorker(out, OutputOptions{Retry: fastRetry(3)})
+
+	w.flush(testDeliveries("app", "line1", "line2"))
+
+	assert.Equal(t, 3, out.writes)
+	assert.Len(t, out.written(), 2)
+}
+
+func TestOutputWorker_FlushDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	dl, err := NewDeadLetter(filepath.Join(dir, "dead.jsonl"))
+	require.NoError(t, err)
+
+	q, err := queue.Open(filepath.Join(dir, "queue"), 0)
+	require.NoError(t, err)
+
+	broken := &mockOutput{name: "broken", match: "app", failures: 100}
+	working := &mockOutput{name: "working", match: "*"}
+	brokenWorker := newTestWorker(broken, OutputOptions{Retry: fastRetry(2), DeadLetter: dl})
+	workingWorker := newTestWorker(working, OutputOptions{Retry: fastRetry(2)})
+
+	var deliveries []*delivery
+	for _, line := range []string{"line1", "line2"} {
+		event := internal.Event{RawData: line, Metadata: internal.Metadata{Tag: "app"}}
+		record, err := q.Append(event)
+		require.NoError(t, err)
+		deliveries = append(deliveries, newDelivery(event, record, 2))
+	}
+
+	brokenWorker.flush(deliveries)
+	assert.Equal(t, 2, q.Pending())
+	workingWorker.flush(deliveries)
+	require.NoError(t, dl.Close())
+
+	assert.Equal(t, 2, broken.writes)
+	assert.Len(t, working.written(), 2)
+	// Dead lettered events count as delivered
+	assert.Equal(t, 0, q.Pending())
+
+	file, err := os.Open(dl.Path())
+	require.NoError(t, err)
+	defer file.Close()
+
+	var entries []deadLetterEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry deadLetterEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 2)
+	assert.Equal(t, "broken", entries[0].Output)
+	assert.Equal(t, "service unavailable", entries[0].Error)
+	assert.Equal(t, "line1", entries[0].Event.RawData)
+	assert.Equal(t, "line2", entries[1].Event.RawData)
+}
+
+func TestOutputWorker_FlushKeepsFailedRecordsQueued(t *testing.T) {
+	q, err := queue.Open(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	w := newTestWorker(&mockOutput{name: "broken", match: "*", failures: 100}, OutputOptions{Retry: fastRetry(1)})
+
+	event := internal.Event{RawData: "line1"}
+	record, err := q.Append(event)
+	require.NoError(t, err)
+
+	w.flush([]*delivery{newDelivery(event, record, 1)})
+	assert.Equal(t, 1, q.Pending())
+}
+
+func TestOutputWorker_DropOldest(t *testing.T) {
+	w := newTestWorker(&mockOutput{name: "slow", match: "*"}, OutputOptions{
+		QueueSize:      2,
+		OverflowPolicy: OverflowDropOldest,
+	})
+
+	for _, d := range testDeliveries("app", "line1", "line2", "line3") {
+		w.enqueue(context.Background(), d)
+	}
+
+	assert.Equal(t, uint64(1), w.Dropped())
+	assert.Equal(t, "line2", (<-w.queue).event.RawData)
+	assert.Equal(t, "line3", (<-w.queue).event.RawData)
+}
+
+func (m *mockOutput) batchSizes() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]int(nil), m.batches...)
+}
+
+func TestOutputWorker_Batching(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch BatchSettings
+		full  []int // Written before the shutdown
+		want  []int
+	}{
+		{
+			name:  "by size",
+			batch: BatchSettings{Size: 3, FlushInterval: time.Hour},
+			full:  []int{3, 3},
+			want:  []int{3, 3, 1},
+		},
+		{
+			name:  "by bytes",
+			batch: BatchSettings{Size: 100, Bytes: 10, FlushInterval: time.Hour},
+			full:  []int{2, 2, 2},
+			want:  []int{2, 2, 2, 1},
+		},
+		{
+			name:  "immediate",
+			batch: BatchSettings{Size: 1, FlushInterval: time.Hour},
+			full:  []int{1, 1, 1, 1, 1, 1, 1},
+			want:  []int{1, 1, 1, 1, 1, 1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &mockOutput{name: "batched", match: "*"}
+			w := newOutputWorker(out, OutputOptions{Batch: tt.batch})
+			w.start(context.Background())
+
+			for _, d := range testDeliveries("app", "line1", "line2", "line3", "line4", "line5", "line6", "line7") {
+				w.enqueue(context.Background(), d)
+			}
+
+			// An incomplete batch waits for the flush interval or the shutdown
+			assert.Eventually(t, func() bool {
+				return len(out.batchSizes()) == len(tt.full)
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, tt.full, out.batchSizes())
+
+			w.stop()
+			assert.Equal(t, tt.want, out.batchSizes())
+		})
+	}
+}
+
+func TestOutputWorker_FlushInterval(t *testing.T) {
+	out := &mockOutput{name: "batched", match: "*"}
+	w := newOutputWorker(out, OutputOptions{Batch: BatchSettings{Size: 100, FlushInterval: 20 * time.Millisecond}})
+	w.start(context.Background())
+	defer w.stop()
+
+	for _, d := range testDeliveries("app", "line1", "line2") {
+		w.enqueue(context.Background(), d)
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(out.written()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{2}, out.batchSizes())
+}
+
+func TestBatchSettings_WithDefaults(t *testing.T) {
+	defaults := BatchSettings{Size: 500, Bytes: 1 << 20, FlushInterval: 5 * time.Second}
+
+	assert.Equal(t, defaults, BatchSettings{}.withDefaults(defaults))
+	assert.Equal(t,
+		BatchSettings{Size: 1, Bytes: 1 << 20, FlushInterval: 5 * time.Second},
+		BatchSettings{Size: 1}.withDefaults(defaults),
+	)
+}
+
+// blockingOutput never returns from Write until it is released
+type blockingOutput struct {
+	mockOutput
+	release chan struct{}
+}
+
+func (b *blockingOutput) Write(events []internal.Event) error {
+	<-b.release
+	return b.mockOutput.Write(events)
+}
+
+func TestEngine_SlowOutputDoesNotBlockOthers(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	slow := &blockingOutput{mockOutput: mockOutput{name: "slow", match: "*"}, release: make(chan struct{})}
+	fast := &mockOutput{name: "fast", match: "*"}
+	e.RegisterOutput(slow, OutputOptions{QueueSize: 1, OverflowPolicy: OverflowDropOldest})
+	e.RegisterOutput(fast, OutputOptions{})
+	require.NoError(t, e.Start())
+
+	for i := range 250 {
+		e.pipeline <- internal.Event{RawData: fmt.Sprintf("line%d", i), Metadata: internal.Metadata{Tag: "app"}}
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(fast.written()) == 250
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(slow.release)
+	e.Stop()
+	assert.Positive(t, e.outputs[0].Dropped())
+}
+
+func TestEngine_AcksEvents(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	out := &mockOutput{name: "app", match: "app"}
+	e.RegisterOutput(out, OutputOptions{})
+	require.NoError(t, e.Start())
+
+	var acked sync.Map
+	send := func(line, tag string) {
+		e.pipeline <- internal.Event{
+			RawData:  line,
+			Metadata: internal.Metadata{Tag: tag},
+			OnAck:    func() { acked.Store(line, true) },
+		}
+	}
+
+	send("delivered", "app")
+	send("unmatched", "other")
+
+	assert.Eventually(t, func() bool {
+		_, delivered := acked.Load("delivered")
+		_, unmatched := acked.Load("unmatched")
+		return delivered && unmatched
+	}, 5*time.Second, 10*time.Millisecond)
+	e.Stop()
+}
+
+func TestEngine_StopDeliversPipeline(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	out := &mockOutput{name: "app", match: "*"}
+	// A small queue makes handing over the events wait for the worker
+	e.RegisterOutput(out, OutputOptions{QueueSize: 1, Batch: BatchSettings{Size: 1}})
+
+	// The events are still in the pipeline when the engine is shut down
+	e.cancel()
+	for i := range 200 {
+		e.pipeline <- internal.Event{RawData: fmt.Sprint(i), Metadata: internal.Metadata{Tag: "app"}}
+	}
+	require.NoError(t, e.Start())
+	require.NoError(t, e.Stop())
+
+	assert.Len(t, out.written(), 200)
+}
+
+func TestDelivery_AckOnlyWhenAllOutputsDelivered(t *testing.T) {
+	acks := 0
+	event := internal.Event{OnAck: func() { acks++ }}
+
+	d := newDelivery(event, nil, 2)
+	d.done(true)
+	assert.Equal(t, 0, acks)
+	d.done(true)
+	assert.Equal(t, 1, acks)
+
+	d = newDelivery(event, nil, 2)
+	d.done(false)
+	d.done(true)
+	assert.Equal(t, 1, acks)
+}
+
+func TestDelivery_NackWhenGivenUp(t *testing.T) {
+	acks, nacks := 0, 0
+	event := internal.Event{OnAck: func() { acks++ }, OnNack: func() { nacks++ }}
+
+	d := newDelivery(event, nil, 2)
+	d.done(false)
+	assert.Equal(t, 0, nacks)
+	d.done(true)
+	assert.Equal(t, 0, acks)
+	assert.Equal(t, 1, nacks)
+
+	// Events evicted from a full queue are released as well
+	w := newTestWorker(&mockOutput{name: "slow"}, OutputOptions{QueueSize: 1, OverflowPolicy: OverflowDropOldest})
+	w.enqueue(context.Background(), newDelivery(event, nil, 1))
+	w.enqueue(context.Background(), newDelivery(internal.Event{}, nil, 1))
+	assert.Equal(t, 2, nacks)
+}
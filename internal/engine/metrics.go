@@ -0,0 +1,33 @@
+package engine
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	pipelineDepth = metrics.NewGaugeVec("forwarder_pipeline_depth",
+		"Events handed over by the inputs that wait to be parsed and filtered.")
+	inputEvents = metrics.NewCounterVec("forwarder_input_events_total",
+		"Events received from the inputs.", "input", "tag")
+	parserEvents = metrics.NewCounterVec("forwarder_parser_events_total",
+		"Events a parser was run on, by result (success or failure).", "parser", "result")
+	filterDropped = metrics.NewCounterVec("forwarder_filter_dropped_total",
+		"Events dropped by a filter.", "filter")
+	outputWritten = metrics.NewCounterVec("forwarder_output_events_written_total",
+		"Events written by an output.", "output")
+	outputFailed = metrics.NewCounterVec("forwarder_output_events_failed_total",
+		"Events an output failed to write after all retries.", "output")
+	outputRetried = metrics.NewCounterVec("forwarder_output_events_retried_total",
+		"Events an output retried to write.", "output")
+	outputDropped = metrics.NewCounterVec("forwarder_output_events_dropped_total",
+		"Events dropped because the queue of an output was full.", "output")
+	outputFlushDuration = metrics.NewHistogramVec("forwarder_output_flush_duration_seconds",
+		"Time an output took to write and flush a batch.", metrics.DefaultBuckets, "output")
+	outputQueueDepth = metrics.NewGaugeVec("forwarder_output_queue_depth",
+		"Events waiting in the queue of an output.", "output")
+)
+
+func parserResult(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "failure"
+}
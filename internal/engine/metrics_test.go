@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// counterValues returns the current values of counters, they are global so
+// tests check how much they increased
+func counterValues(counters ...*metrics.Counter) []uint64 {
+	values := make([]uint64, 0, len(counters))
+	for _, counter := range counters {
+		values = append(values, counter.Value())
+	}
+	return values
+}
+
+func counterIncreases(before []uint64, counters ...*metrics.Counter) []uint64 {
+	after := counterValues(counters...)
+	for i := range after {
+		after[i] -= before[i]
+	}
+	return after
+}
+
+func TestOutputWorker_Metrics(t *testing.T) {
+	counters := []*metrics.Counter{
+		outputWritten.WithLabelValues("metrics-flaky"),
+		outputRetried.WithLabelValues("metrics-flaky"),
+		outputFailed.WithLabelValues("metrics-flaky"),
+		outputFailed.WithLabelValues("metrics-broken"),
+	}
+	before := counterValues(counters...)
+	flushes := outputFlushDuration.WithLabelValues("metrics-flaky").Count()
+
+	flaky := &mockOutput{name: "metrics-flaky", match: "*", failures: 1}
+	w := newTestWorker(flaky, OutputOptions{Retry: fastRetry(2)})
+	w.flush(testDeliveries("app", "line1", "line2"))
+
+	broken := &mockOutput{name: "metrics-broken", match: "*", failures: 100}
+	w = newTestWorker(broken, OutputOptions{Retry: fastRetry(1)})
+	w.flush(testDeliveries("app", "line1"))
+
+	assert.Equal(t, []uint64{2, 2, 0, 1}, counterIncreases(before, counters...))
+	assert.Equal(t, flushes+2, outputFlushDuration.WithLabelValues("metrics-flaky").Count())
+}
+
+func TestEngine_ProcessMetrics(t *testing.T) {
+	counters := []*metrics.Counter{
+		inputEvents.WithLabelValues("metrics-input", "app"),
+		parserEvents.WithLabelValues("metered", "success"),
+		parserEvents.WithLabelValues("metered", "failure"),
+		filterDropped.WithLabelValues("metrics-filter"),
+	}
+	before := counterValues(counters...)
+
+	e := NewEngine(DefaultSettings())
+	e.RegisterParser(&prefixParser{name: "metered", match: "*"})
+	e.RegisterFilter(&dropFilter{name: "metrics-filter", drop: "metered:drop"})
+
+	for _, line := range []string{"metered:keep", "metered:drop", "raw"} {
+		e.process(internal.Event{RawData: line, Metadata: internal.Metadata{InputSource: "metrics-input", Tag: "app"}})
+	}
+
+	assert.Equal(t, []uint64{3, 2, 1, 1}, counterIncreases(before, counters...))
+}
+
+// dropFilter drops the events with the given raw data
+type dropFilter struct {
+	name string
+	drop string
+}
+
+func (f *dropFilter) Name() string                     { return f.name }
+func (f *dropFilter) Init(config map[string]any) error { return nil }
+func (f *dropFilter) Exit() error                      { return nil }
+func (f *dropFilter) MatchTag(inputTag string) bool    { return true }
+
+func (f *dropFilter) Process(event *internal.Event) (*internal.Event, error) {
+	if event.RawData == f.drop {
+		return nil, nil
+	}
+	return event, nil
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"slices"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/filter"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// Output is an output plugin together with the settings the engine applies to it
+type Output struct {
+	Plugin  output.Plugin
+	Options OutputOptions
+}
+
+// Plugins is the complete set of plugins the engine should run after a reload
+type Plugins struct {
+	Inputs  []input.Plugin
+	Parsers []parser.Plugin
+	Chains  []ParserChain
+	Filters []filter.Plugin
+	Outputs []Output
+}
+
+// Reload replaces the running plugins with the given set. Plugins that are
+// part of the running and the new set keep running with their state, the
+// others are stopped or started. Events that are already in the pipeline are
+// processed by the old parsers and filters and delivered to the old outputs.
+// Reload must only be called on a started engine.
+func (e *Engine) Reload(plugins Plugins) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Stop removed inputs first, so their last events are still processed
+	// by the current pipeline and their ports are free for new inputs
+	var inputs, addedInputs []*inputRunner
+	for _, in := range plugins.Inputs {
+		idx := slices.IndexFunc(e.inputs, func(r *inputRunner) bool { return r.Plugin == in })
+		if idx >= 0 {
+			inputs = append(inputs, e.inputs[idx])
+			continue
+		}
+		runner := &inputRunner{Plugin: in}
+		inputs = append(inputs, runner)
+		addedInputs = append(addedInputs, runner)
+	}
+	for _, in := range e.inputs {
+		if !slices.Contains(inputs, in) {
+			logrus.WithField("input", in.Name()).Info("[Engine] Stopping removed input")
+			in.stop()
+		}
+	}
+
+	var outputs, addedOutputs []*outputWorker
+	for _, out := range plugins.Outputs {
+		idx := slices.IndexFunc(e.outputs, func(w *outputWorker) bool { return w.Plugin == out.Plugin })
+		if idx >= 0 {
+			outputs = append(outputs, e.outputs[idx])
+			continue
+		}
+		out.Options.Batch = out.Options.Batch.withDefaults(e.settings.Batch)
+		worker := newOutputWorker(out.Plugin, out.Options)
+		worker.start(e.ctx)
+		outputs = append(outputs, worker)
+		addedOutputs = append(addedOutputs, worker)
+	}
+
+	oldParsers, oldFilters, oldOutputs := e.parsers, e.filters, e.outputs
+	e.inputs = inputs
+	e.swap(func() {
+		e.parsers = plugins.Parsers
+		e.chains = plugins.Chains
+		e.filters = plugins.Filters
+		e.outputs = outputs
+	})
+
+	for _, parser := range oldParsers {
+		if !slices.Contains(e.parsers, parser) {
+			parser.Exit()
+		}
+	}
+	for _, filter := range oldFilters {
+		if !slices.Contains(e.filters, filter) {
+			filter.Exit()
+		}
+	}
+
+	// Removed outputs deliver what is left in their queue before they exit
+	for _, output := range oldOutputs {
+		if slices.Contains(e.outputs, output) {
+			continue
+		}
+		logrus.WithField("writer", output.Name()).Info("[Engine] Stopping removed output")
+		output.stop()
+		output.Flush()
+		output.Exit()
+	}
+
+	for _, in := range addedInputs {
+		logrus.WithField("input", in.Name()).Info("[Engine] Starting added input")
+		e.startInput(in, false)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"added_inputs":  len(addedInputs),
+		"added_outputs": len(addedOutputs),
+	}).Info("[Engine] Reloaded plugins")
+}
+
+// swap runs fn in the processing goroutine once it processed the events that
+// are already in the pipeline. fn is run directly if the engine was stopped.
+func (e *Engine) swap(fn func()) {
+	done := make(chan struct{})
+	select {
+	case e.reloadCh <- func() { fn(); close(done) }:
+		<-done
+	case <-e.ctx.Done():
+		fn()
+	}
+}
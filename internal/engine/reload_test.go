@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockInput records whether it is running
+type mockInput struct {
+	name    string
+	running atomic.Bool
+	exited  atomic.Bool
+}
+
+func (m *mockInput) Name() string                     { return m.name }
+func (m *mockInput) Tag() string                      { return m.name }
+func (m *mockInput) Init(config map[string]any) error { return nil }
+
+func (m *mockInput) Start(ctx context.Context, output chan<- internal.Event) error {
+	m.running.Store(true)
+	go func() {
+		<-ctx.Done()
+		m.running.Store(false)
+	}()
+	return nil
+}
+
+func (m *mockInput) Exit() error {
+	m.exited.Store(true)
+	return nil
+}
+
+func TestEngine_Reload(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	kept := &mockInput{name: "kept"}
+	removed := &mockInput{name: "removed"}
+	oldOutput := &mockOutput{name: "old", match: "*"}
+	keptOutput := &mockOutput{name: "kept", match: "*"}
+	e.RegisterInput(kept)
+	e.RegisterInput(removed)
+	e.RegisterOutput(oldOutput, OutputOptions{Batch: BatchSettings{FlushInterval: time.Hour}})
+	e.RegisterOutput(keptOutput, OutputOptions{Batch: BatchSettings{Size: 1}})
+	require.NoError(t, e.Start())
+	defer e.Stop()
+
+	assert.Eventually(t, func() bool {
+		return kept.running.Load() && removed.running.Load()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	e.pipeline <- internal.Event{RawData: "before", Metadata: internal.Metadata{Tag: "app"}}
+	keptWorker := e.outputs[1]
+
+	added := &mockInput{name: "added"}
+	newOutput := &mockOutput{name: "new", match: "*"}
+	e.Reload(Plugins{
+		Inputs: []input.Plugin{kept, added},
+		Outputs: []Output{
+			{Plugin: keptOutput},
+			{Plugin: newOutput, Options: OutputOptions{Batch: BatchSettings{Size: 1}}},
+		},
+	})
+
+	// The removed output delivered its queue before it was stopped
+	assert.Len(t, oldOutput.written(), 1)
+	assert.True(t, removed.exited.Load())
+	assert.False(t, removed.running.Load())
+	assert.False(t, kept.exited.Load())
+	assert.True(t, kept.running.Load())
+	assert.Eventually(t, added.running.Load, 5*time.Second, 10*time.Millisecond)
+	assert.Same(t, keptWorker, e.outputs[0])
+
+	e.pipeline <- internal.Event{RawData: "after", Metadata: internal.Metadata{Tag: "app"}}
+	assert.Eventually(t, func() bool {
+		return len(newOutput.written()) == 1 && len(keptOutput.written()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, oldOutput.written(), 1)
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = 30 * time.Second
+	defaultRetryJitter         = 0.2
+)
+
+// RetryPolicy controls how often and how fast the engine retries a batch
+// an output failed to write
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64 // Fraction of the backoff that is randomized (0-1)
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Jitter:         defaultRetryJitter,
+	}
+}
+
+// Backoff returns the time to wait after the given failed attempt (starting at 1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		// Spread the backoff evenly in [backoff*(1-jitter), backoff*(1+jitter)]
+		delta := p.Jitter * float64(backoff)
+		backoff = time.Duration(float64(backoff) - delta + rand.Float64()*2*delta)
+	}
+	return backoff
+}
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultReadyQueueThreshold = 0.9
+
+// ErrUnknownPlugin is returned by the admin actions for a name no running
+// plugin has
+var ErrUnknownPlugin = errors.New("unknown plugin")
+
+const (
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateFailed  = "failed"
+)
+
+// PluginStatus is the state of a plugin as reported by the admin API
+type PluginStatus struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	State         string            `json:"state"`
+	LastError     string            `json:"lastError,omitempty"`
+	LastErrorTime *time.Time        `json:"lastErrorTime,omitempty"`
+	Counters      map[string]uint64 `json:"counters"`
+}
+
+// pluginState tracks whether a plugin currently fails and its last error
+type pluginState struct {
+	mu        sync.Mutex
+	failed    bool
+	lastErr   error
+	lastErrAt time.Time
+}
+
+func (s *pluginState) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.lastErr = err
+	s.lastErrAt = time.Now()
+}
+
+func (s *pluginState) recover() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = false
+}
+
+func (s *pluginState) isFailed() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed, s.lastErr
+}
+
+// fill sets the state and the last error of status
+func (s *pluginState) fill(status *PluginStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed {
+		status.State = StateFailed
+	}
+	if s.lastErr != nil {
+		at := s.lastErrAt
+		status.LastError = s.lastErr.Error()
+		status.LastErrorTime = &at
+	}
+}
+
+// Status reports the state and counters of every running plugin
+func (e *Engine) Status() []PluginStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var statuses []PluginStatus
+	for _, in := range e.inputs {
+		statuses = append(statuses, in.status())
+	}
+	for _, p := range e.parsers {
+		statuses = append(statuses, PluginStatus{
+			Name:  p.Name(),
+			Type:  "parser",
+			State: StateRunning,
+			Counters: map[string]uint64{
+				"success": parserEvents.WithLabelValues(p.Name(), parserResult(true)).Value(),
+				"failure": parserEvents.WithLabelValues(p.Name(), parserResult(false)).Value(),
+			},
+		})
+	}
+	for _, f := range e.filters {
+		statuses = append(statuses, PluginStatus{
+			Name:  f.Name(),
+			Type:  "filter",
+			State: StateRunning,
+			Counters: map[string]uint64{
+				"dropped": filterDropped.WithLabelValues(f.Name()).Value(),
+			},
+		})
+	}
+	for _, out := range e.outputs {
+		statuses = append(statuses, out.status())
+	}
+	return statuses
+}
+
+// Ready reports why the forwarder can't take events right now. It returns
+// nil once every input that isn't paused is running, no output failed its
+// last write and no output queue is filled beyond the ready threshold.
+func (e *Engine) Ready() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var errs []error
+	for _, in := range e.inputs {
+		if !in.ready() {
+			errs = append(errs, fmt.Errorf("input %s is not running", in.Name()))
+		}
+	}
+	for _, out := range e.outputs {
+		if failed, err := out.state.isFailed(); failed {
+			errs = append(errs, fmt.Errorf("output %s is unreachable: %w", out.Name(), err))
+		}
+		if limit := e.settings.ReadyQueueThreshold * float64(cap(out.queue)); float64(len(out.queue)) >= limit {
+			errs = append(errs, fmt.Errorf("queue of output %s holds %d of %d events", out.Name(), len(out.queue), cap(out.queue)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PauseInput stops an input until it is resumed. The input keeps its state,
+// e.g. a tail input continues at the offsets it stopped at.
+func (e *Engine) PauseInput(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	in := e.findInput(name)
+	if in == nil {
+		return fmt.Errorf("%w: input %s", ErrUnknownPlugin, name)
+	}
+	if in.isPaused() {
+		return nil
+	}
+
+	in.stop()
+	in.setPaused(true)
+	return nil
+}
+
+// ResumeInput starts a paused input again
+func (e *Engine) ResumeInput(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	in := e.findInput(name)
+	if in == nil {
+		return fmt.Errorf("%w: input %s", ErrUnknownPlugin, name)
+	}
+	if !in.isPaused() {
+		return nil
+	}
+
+	in.setPaused(false)
+	return e.startInput(in, false)
+}
+
+// FlushOutput writes the events an output collected for its current batch
+// without waiting for the batch to fill up
+func (e *Engine) FlushOutput(ctx context.Context, name string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, out := range e.outputs {
+		if out.Name() == name {
+			return out.flushNow(ctx)
+		}
+	}
+	return fmt.Errorf("%w: output %s", ErrUnknownPlugin, name)
+}
+
+func (e *Engine) findInput(name string) *inputRunner {
+	for _, in := range e.inputs {
+		if in.Name() == name {
+			return in
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findStatus(statuses []PluginStatus, pluginType, name string) PluginStatus {
+	for _, status := range statuses {
+		if status.Type == pluginType && status.Name == name {
+			return status
+		}
+	}
+	return PluginStatus{}
+}
+
+func TestEngine_PauseResumeInput(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	in := &mockInput{name: "status-input"}
+	e.RegisterInput(in)
+	require.Error(t, e.Ready(), "inputs are not started yet")
+	require.NoError(t, e.Start())
+	defer e.Stop()
+
+	assert.Eventually(t, in.running.Load, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, e.Ready())
+
+	require.NoError(t, e.PauseInput("status-input"))
+	assert.Eventually(t, func() bool { return !in.running.Load() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatePaused, findStatus(e.Status(), "input", "status-input").State)
+	// A paused input doesn't make the engine unready
+	assert.NoError(t, e.Ready())
+
+	require.NoError(t, e.ResumeInput("status-input"))
+	assert.Eventually(t, in.running.Load, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StateRunning, findStatus(e.Status(), "input", "status-input").State)
+
+	assert.ErrorIs(t, e.PauseInput("missing"), ErrUnknownPlugin)
+}
+
+func TestEngine_ReadyFailingInput(t *testing.T) {
+	settings := fastRestartSettings()
+	settings.InputRestart.InitialBackoff = time.Hour
+	settings.InputRestart.MaxBackoff = time.Hour
+	e := NewEngine(settings)
+	e.RegisterInput(&failingInput{mockInput: mockInput{name: "broken"}, startFailures: 1})
+	require.NoError(t, e.Start())
+	defer e.Stop()
+
+	assert.ErrorContains(t, e.Ready(), "input broken is not running")
+	status := findStatus(e.Status(), "input", "broken")
+	assert.Equal(t, StateFailed, status.State)
+	assert.Equal(t, "address already in use", status.LastError)
+}
+
+func TestEngine_ReadyFailingOutput(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	broken := &mockOutput{name: "status-broken", match: "*", failures: 1}
+	// Counters are global, so only their increase is checked
+	written := outputWritten.WithLabelValues("status-broken").Value()
+	failed := outputFailed.WithLabelValues("status-broken").Value()
+	e.RegisterOutput(broken, OutputOptions{Retry: fastRetry(1), Batch: BatchSettings{Size: 1}})
+	require.NoError(t, e.Start())
+	defer e.Stop()
+
+	e.pipeline <- internal.Event{RawData: "line1", Metadata: internal.Metadata{Tag: "app"}}
+	assert.Eventually(t, func() bool { return e.Ready() != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorContains(t, e.Ready(), "output status-broken is unreachable: service unavailable")
+
+	// The next successful write makes the output reachable again
+	e.pipeline <- internal.Event{RawData: "line2", Metadata: internal.Metadata{Tag: "app"}}
+	assert.Eventually(t, func() bool { return e.Ready() == nil }, 5*time.Second, 10*time.Millisecond)
+
+	status := findStatus(e.Status(), "output", "status-broken")
+	assert.Equal(t, StateRunning, status.State)
+	assert.Equal(t, "service unavailable", status.LastError)
+	assert.Equal(t, written+1, status.Counters["written"])
+	assert.Equal(t, failed+1, status.Counters["failed"])
+}
+
+func TestEngine_ReadyQueueThreshold(t *testing.T) {
+	settings := DefaultSettings()
+	settings.ReadyQueueThreshold = 0.5
+	e := NewEngine(settings)
+	e.RegisterOutput(&mockOutput{name: "status-full", match: "*"}, OutputOptions{QueueSize: 4})
+
+	// The workers are not started, so the queue fills up
+	worker := e.outputs[0]
+	for _, d := range testDeliveries("app", "line1", "line2") {
+		worker.enqueue(context.Background(), d)
+	}
+	assert.ErrorContains(t, e.Ready(), "queue of output status-full holds 2 of 4 events")
+}
+
+func TestEngine_FlushOutput(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	out := &mockOutput{name: "status-flush", match: "*"}
+	e.RegisterOutput(out, OutputOptions{Workers: 2, Batch: BatchSettings{Size: 100, FlushInterval: time.Hour}})
+	require.NoError(t, e.Start())
+	defer e.Stop()
+
+	e.pipeline <- internal.Event{RawData: "line1", Metadata: internal.Metadata{Tag: "app"}}
+	assert.Eventually(t, func() bool { return len(e.outputs[0].queue) == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, out.written())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, e.FlushOutput(ctx, "status-flush"))
+	assert.Len(t, out.written(), 1)
+
+	assert.ErrorIs(t, e.FlushOutput(ctx, "missing"), ErrUnknownPlugin)
+}
@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInputRestartInitialBackoff = time.Second
+	defaultInputRestartMaxBackoff     = time.Minute
+)
+
+func DefaultInputRestartPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialBackoff: defaultInputRestartInitialBackoff,
+		MaxBackoff:     defaultInputRestartMaxBackoff,
+		Jitter:         defaultRetryJitter,
+	}
+}
+
+// inputRunner runs an input with its own context, so it can be stopped
+// without stopping the engine
+type inputRunner struct {
+	input.Plugin
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed once the supervisor returned
+	mu       sync.Mutex
+	running  bool
+	paused   bool
+	restarts uint64
+	state    pluginState
+}
+
+// start starts the input, it has to be cleaned up with exit even if
+// starting failed
+func (r *inputRunner) start(ctx context.Context, errCh chan<- error, pipeline chan<- internal.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = true
+	if err := r.Start(input.WithErrorChannel(ctx, errCh), pipeline); err != nil {
+		r.state.fail(err)
+		return err
+	}
+	r.state.recover()
+	return nil
+}
+
+// exit stops the input if it is running
+func (r *inputRunner) exit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.running {
+		return
+	}
+	r.running = false
+	if err := r.Exit(); err != nil {
+		logrus.WithError(err).WithField("input", r.Name()).Error("[Engine] Coundnt stop input")
+	}
+}
+
+func (r *inputRunner) isPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+func (r *inputRunner) setPaused(paused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = paused
+}
+
+// ready reports whether the input is running or was paused on purpose
+func (r *inputRunner) ready() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failed, _ := r.state.isFailed()
+	return r.paused || (r.running && !failed)
+}
+
+func (r *inputRunner) status() PluginStatus {
+	r.mu.Lock()
+	status := PluginStatus{
+		Name:  r.Name(),
+		Type:  "input",
+		State: StateRunning,
+		Counters: map[string]uint64{
+			"events":   inputEvents.WithLabelValues(r.Name(), r.Tag()).Value(),
+			"restarts": r.restarts,
+		},
+	}
+	paused := r.paused
+	r.mu.Unlock()
+
+	r.state.fill(&status)
+	if paused {
+		status.State = StatePaused
+	}
+	return status
+}
+
+// stop stops the supervisor and the input
+func (r *inputRunner) stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	r.exit()
+}
+
+// startInput starts an input and supervises it in the background. With
+// failFast an input that can't be started is returned as error, otherwise
+// it is restarted like an input that failed while running.
+func (e *Engine) startInput(in *inputRunner, failFast bool) error {
+	ctx, cancel := context.WithCancel(e.ctx)
+	errCh := make(chan error, 1)
+
+	err := in.start(ctx, errCh, e.pipeline)
+	if err != nil && failFast {
+		cancel()
+		in.exit()
+		return fmt.Errorf("could not start input %s: %w", in.Name(), err)
+	}
+
+	in.cancel = cancel
+	in.done = make(chan struct{})
+	e.wg.Add(1)
+	go e.superviseInput(ctx, in, errCh, err)
+	return nil
+}
+
+// superviseInput restarts the input with backoff whenever it failed to
+// start or reported an error, until ctx is done
+func (e *Engine) superviseInput(ctx context.Context, in *inputRunner, errCh chan error, err error) {
+	defer e.wg.Done()
+	defer close(in.done)
+
+	policy := e.settings.InputRestart
+	attempt := 0
+	for {
+		if err == nil {
+			started := time.Now()
+			select {
+			case err = <-errCh:
+				in.state.fail(err)
+			case <-ctx.Done():
+				return
+			}
+			// An input that ran for a while starts over with the shortest backoff
+			if time.Since(started) > policy.MaxBackoff {
+				attempt = 0
+			}
+		}
+		in.exit()
+
+		attempt++
+		in.mu.Lock()
+		in.restarts++
+		in.mu.Unlock()
+		backoff := policy.Backoff(attempt)
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"input":   in.Name(),
+			"attempt": attempt,
+			"backoff": backoff,
+		}).Error("[Engine] Input failed, restarting")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		// Drop an error reported by the failed run
+		select {
+		case <-errCh:
+		default:
+		}
+		err = in.start(ctx, errCh, e.pipeline)
+	}
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingInput fails to start startFailures times and reports an error
+// after every further start until runFailures are used up
+type failingInput struct {
+	mockInput
+	startFailures int32
+	runFailures   int32
+	starts        atomic.Int32
+	exits         atomic.Int32
+}
+
+func (f *failingInput) Start(ctx context.Context, output chan<- internal.Event) error {
+	start := f.starts.Add(1)
+	if start <= f.startFailures {
+		return errors.New("address already in use")
+	}
+	if start <= f.startFailures+f.runFailures {
+		go input.ReportError(ctx, errors.New("connection lost"))
+	}
+	return nil
+}
+
+func (f *failingInput) Exit() error {
+	f.exits.Add(1)
+	return nil
+}
+
+func fastRestartSettings() Settings {
+	settings := DefaultSettings()
+	settings.InputRestart = RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	return settings
+}
+
+func TestEngine_RestartsFailedInputs(t *testing.T) {
+	e := NewEngine(fastRestartSettings())
+	in := &failingInput{mockInput: mockInput{name: "flaky"}, startFailures: 2, runFailures: 2}
+	e.RegisterInput(in)
+	require.NoError(t, e.Start())
+
+	assert.Eventually(t, func() bool {
+		return in.starts.Load() == 5
+	}, 5*time.Second, time.Millisecond)
+	// The last start succeeded and is not restarted anymore
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(5), in.starts.Load())
+	assert.Equal(t, int32(4), in.exits.Load())
+
+	e.Stop()
+	assert.Equal(t, int32(5), in.exits.Load())
+}
+
+func TestEngine_FailFast(t *testing.T) {
+	settings := fastRestartSettings()
+	settings.FailFast = true
+	e := NewEngine(settings)
+	healthy := &failingInput{mockInput: mockInput{name: "healthy"}}
+	broken := &failingInput{mockInput: mockInput{name: "broken"}, startFailures: 1}
+	e.RegisterInput(healthy)
+	e.RegisterInput(broken)
+
+	assert.ErrorContains(t, e.Start(), "could not start input broken: address already in use")
+	e.Stop()
+
+	assert.Equal(t, int32(1), broken.starts.Load())
+	assert.Equal(t, int32(1), broken.exits.Load())
+	assert.Equal(t, int32(1), healthy.exits.Load())
+}
+
+// eofInput asks for the shutdown once it is started
+type eofInput struct {
+	mockInput
+}
+
+func (i *eofInput) Start(ctx context.Context, output chan<- internal.Event) error {
+	go input.RequestShutdown(ctx)
+	return nil
+}
+
+func TestEngine_ShutdownRequested(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	e.RegisterInput(&eofInput{mockInput: mockInput{name: "stdin"}})
+	e.RegisterInput(&eofInput{mockInput: mockInput{name: "stdin2"}})
+	require.NoError(t, e.Start())
+
+	select {
+	case <-e.ShutdownRequested():
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown was not requested")
+	}
+	require.NoError(t, e.Stop())
+}
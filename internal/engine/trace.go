@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"encoding/json"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+)
+
+// FilterResult is what a filter did to an event
+type FilterResult string
+
+const (
+	FilterPassed   FilterResult = "passed"
+	FilterModified FilterResult = "modified"
+	FilterDropped  FilterResult = "dropped"
+	FilterFailed   FilterResult = "failed"
+)
+
+// ParserResult is the outcome of a parser that was tried on an event
+type ParserResult struct {
+	Parser  string
+	Success bool
+}
+
+// FilterStep is the outcome of a filter that was applied to an event
+type FilterStep struct {
+	Filter string
+	Result FilterResult
+	Err    error
+}
+
+// Trace records the way of an event through the parsers and filters
+type Trace struct {
+	Chain     string // Match of the parser chain that was used, empty without one
+	Parsers   []ParserResult
+	Filters   []FilterStep
+	DroppedBy string          // The chain or filter that dropped the event
+	Event     *internal.Event // The processed event, nil if it was dropped
+}
+
+// Trace runs an event through the parsers and filters like a running engine
+// would, without handing it to the outputs, and records every step. It is
+// meant for testing a config with sample events.
+func (e *Engine) Trace(event internal.Event) Trace {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var trace Trace
+	trace.Event = e.transform(&event, &trace)
+	return trace
+}
+
+func (t *Trace) addFilter(name string, result FilterResult, err error) {
+	if t != nil {
+		t.Filters = append(t.Filters, FilterStep{Filter: name, Result: result, Err: err})
+	}
+}
+
+// snapshot returns the data of an event a filter may change
+func snapshot(event *internal.Event) string {
+	data, _ := json.Marshal(struct {
+		RawData    string
+		ParsedData map[string]any
+		Tag        string
+	}{event.RawData, event.ParsedData, event.Metadata.Tag})
+	return string(data)
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// tagFilter sets the tag of every event, or fails with err
+type tagFilter struct {
+	dropFilter
+	tag string
+	err error
+}
+
+func (f *tagFilter) Process(event *internal.Event) (*internal.Event, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	event.Metadata.Tag = f.tag
+	return event, nil
+}
+
+func TestEngine_Trace(t *testing.T) {
+	e := NewEngine(DefaultSettings())
+	json := &prefixParser{name: "json", match: "*"}
+	e.RegisterParser(&prefixParser{name: "nginx", match: "*"})
+	e.RegisterParser(json)
+	e.RegisterParserChain(ParserChain{Match: "strict", Parsers: []parser.Plugin{json}, OnFailure: OnFailureDrop})
+	e.RegisterFilter(&tagFilter{dropFilter: dropFilter{name: "broken"}, err: errors.New("broken")})
+	e.RegisterFilter(&dropFilter{name: "drop", drop: "json:drop"})
+	e.RegisterFilter(&tagFilter{dropFilter: dropFilter{name: "retag"}, tag: "app"})
+
+	trace := e.Trace(internal.Event{RawData: "json:{}", Metadata: internal.Metadata{Tag: "app"}})
+	assert.Equal(t, []ParserResult{{"nginx", false}, {"json", true}}, trace.Parsers)
+	assert.Equal(t, []FilterStep{
+		{Filter: "broken", Result: FilterFailed, Err: errors.New("broken")},
+		{Filter: "drop", Result: FilterPassed},
+		{Filter: "retag", Result: FilterPassed},
+	}, trace.Filters)
+	assert.Empty(t, trace.DroppedBy)
+	assert.Equal(t, map[string]any{"parser": "json"}, trace.Event.ParsedData)
+
+	trace = e.Trace(internal.Event{RawData: "json:drop", Metadata: internal.Metadata{Tag: "web"}})
+	assert.Equal(t, "filter drop", trace.DroppedBy)
+	assert.Nil(t, trace.Event)
+	assert.Len(t, trace.Filters, 2)
+
+	trace = e.Trace(internal.Event{RawData: "json:{}", Metadata: internal.Metadata{Tag: "web"}})
+	assert.Equal(t, FilterModified, trace.Filters[2].Result)
+	assert.Equal(t, "app", trace.Event.Metadata.Tag)
+
+	trace = e.Trace(internal.Event{RawData: "plain", Metadata: internal.Metadata{Tag: "strict"}})
+	assert.Equal(t, "strict", trace.Chain)
+	assert.Equal(t, []ParserResult{{"json", false}}, trace.Parsers)
+	assert.Equal(t, "parser chain strict", trace.DroppedBy)
+	assert.Empty(t, trace.Filters)
+}
@@ -0,0 +1,404 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/queue"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOutputWorkers   = 1
+	defaultOutputQueueSize = 1000
+	defaultBatchSize       = 100
+	defaultFlushInterval   = time.Second
+)
+
+// BatchSettings decide when the events queued for an output are written.
+// A batch is written once it holds Size events or Bytes bytes, or when
+// FlushInterval passed. Zero values are inherited from the engine settings.
+type BatchSettings struct {
+	Size          int
+	Bytes         int // Approximate, 0 means no limit
+	FlushInterval time.Duration
+}
+
+func DefaultBatchSettings() BatchSettings {
+	return BatchSettings{
+		Size:          defaultBatchSize,
+		FlushInterval: defaultFlushInterval,
+	}
+}
+
+func (b BatchSettings) withDefaults(defaults BatchSettings) BatchSettings {
+	if b.Size <= 0 {
+		b.Size = defaults.Size
+	}
+	if b.Bytes <= 0 {
+		b.Bytes = defaults.Bytes
+	}
+	if b.FlushInterval <= 0 {
+		b.FlushInterval = defaults.FlushInterval
+	}
+	return b
+}
+
+// eventSize estimates the size of an event for BatchSettings.Bytes
+func eventSize(event internal.Event) int {
+	size := len(event.RawData)
+	for key, value := range event.ParsedData {
+		size += len(key)
+		if str, ok := value.(string); ok {
+			size += len(str)
+		} else {
+			size += 8
+		}
+	}
+	return size
+}
+
+// OverflowPolicy decides what happens when the queue of an output is full
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"       // Wait until the output catches up
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // Drop the oldest queued event
+)
+
+// OutputOptions are the settings the engine applies to an output
+type OutputOptions struct {
+	Retry          RetryPolicy
+	DeadLetter     *DeadLetter // Optional
+	Workers        int
+	QueueSize      int
+	OverflowPolicy OverflowPolicy
+	Batch          BatchSettings
+}
+
+func DefaultOutputOptions() OutputOptions {
+	return OutputOptions{
+		Retry:          DefaultRetryPolicy(),
+		Workers:        defaultOutputWorkers,
+		QueueSize:      defaultOutputQueueSize,
+		OverflowPolicy: OverflowBlock,
+	}
+}
+
+// delivery is an event on its way to the outputs together with its record
+// in the disk queue (nil when the queue is disabled). The event and the
+// record are acknowledged once every output the event was dispatched to
+// delivered it.
+type delivery struct {
+	event     internal.Event
+	record    *queue.Record
+	remaining atomic.Int32
+	failed    atomic.Bool
+}
+
+func newDelivery(event internal.Event, record *queue.Record, outputs int) *delivery {
+	d := &delivery{event: event, record: record}
+	d.remaining.Store(int32(outputs))
+	return d
+}
+
+// done is called by every output once it is finished with the event. An
+// event that one of the outputs couldn't deliver is released to its input,
+// a persisted one stays in the disk queue for the next start.
+func (d *delivery) done(delivered bool) {
+	if !delivered {
+		d.failed.Store(true)
+	}
+	if d.remaining.Add(-1) != 0 {
+		return
+	}
+	if d.failed.Load() {
+		d.event.Nack()
+		return
+	}
+	d.record.Ack()
+	d.event.Ack()
+}
+
+// outputWorker feeds an output plugin from its own bounded queue, so a slow
+// output does not hold up the pipeline or any other output
+type outputWorker struct {
+	output.Plugin
+	opts     OutputOptions
+	ctx      context.Context
+	queue    chan *delivery
+	wg       sync.WaitGroup
+	dropped  atomic.Uint64
+	reported atomic.Uint64
+	flushReq chan chan struct{}
+	state    pluginState
+}
+
+func newOutputWorker(out output.Plugin, opts OutputOptions) *outputWorker {
+	if opts.Workers < 1 {
+		opts.Workers = defaultOutputWorkers
+	}
+	if opts.QueueSize < 1 {
+		opts.QueueSize = defaultOutputQueueSize
+	}
+	if opts.OverflowPolicy == "" {
+		opts.OverflowPolicy = OverflowBlock
+	}
+	opts.Batch = opts.Batch.withDefaults(DefaultBatchSettings())
+	return &outputWorker{
+		Plugin:   out,
+		opts:     opts,
+		queue:    make(chan *delivery, opts.QueueSize),
+		flushReq: make(chan chan struct{}),
+	}
+}
+
+func (w *outputWorker) start(ctx context.Context) {
+	w.ctx = ctx
+	for range w.opts.Workers {
+		w.wg.Add(1)
+		go w.run()
+	}
+}
+
+// stop lets the workers drain the queue and waits for them to finish
+func (w *outputWorker) stop() {
+	close(w.queue)
+	w.wg.Wait()
+	w.reportDrops()
+}
+
+// enqueue hands an event to the worker according to its overflow policy
+func (w *outputWorker) enqueue(ctx context.Context, d *delivery) {
+	if w.opts.OverflowPolicy == OverflowDropOldest {
+		for {
+			select {
+			case w.queue <- d:
+				outputQueueDepth.WithLabelValues(w.Name()).Set(float64(len(w.queue)))
+				return
+			default:
+			}
+
+			select {
+			case old := <-w.queue:
+				w.dropped.Add(1)
+				outputDropped.WithLabelValues(w.Name()).Inc()
+				old.done(false)
+			default:
+			}
+		}
+	}
+
+	select {
+	case w.queue <- d:
+		outputQueueDepth.WithLabelValues(w.Name()).Set(float64(len(w.queue)))
+	case <-ctx.Done():
+		d.done(false)
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full
+func (w *outputWorker) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+func (w *outputWorker) reportDrops() {
+	dropped := w.dropped.Load()
+	if prev := w.reported.Swap(dropped); dropped > prev {
+		logrus.WithFields(logrus.Fields{
+			"writer":  w.Name(),
+			"dropped": dropped - prev,
+			"total":   dropped,
+		}).Warn("[Engine] Output queue full, dropped oldest events")
+	}
+}
+
+func (w *outputWorker) run() {
+	defer w.wg.Done()
+
+	settings := w.opts.Batch
+	batch := make([]*delivery, 0, settings.Size)
+	batchBytes := 0
+	ticker := time.NewTicker(settings.FlushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) > 0 {
+			w.flush(batch)
+			batch = batch[:0]
+			batchBytes = 0
+		}
+	}
+
+	for {
+		select {
+		case d, ok := <-w.queue:
+			outputQueueDepth.WithLabelValues(w.Name()).Set(float64(len(w.queue)))
+			if !ok {
+				flush()
+				return
+			}
+
+			batch = append(batch, d)
+			if settings.Bytes > 0 {
+				batchBytes += eventSize(d.event)
+			}
+			if len(batch) >= settings.Size || (settings.Bytes > 0 && batchBytes >= settings.Bytes) {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+			w.reportDrops()
+
+		case done := <-w.flushReq:
+			flush()
+			close(done)
+		}
+	}
+}
+
+// flush writes a batch to the output and marks its events as done
+func (w *outputWorker) flush(batch []*delivery) {
+	events := make([]internal.Event, 0, len(batch))
+	persisted := false
+	for _, d := range batch {
+		events = append(events, d.event)
+		persisted = persisted || d.record != nil
+	}
+
+	err := w.deliver(events)
+	delivered := err == nil
+	if delivered {
+		w.state.recover()
+		outputWritten.WithLabelValues(w.Name()).Add(len(events))
+	} else {
+		w.state.fail(err)
+		outputFailed.WithLabelValues(w.Name()).Add(len(events))
+		// Persisted events are replayed on the next start when the
+		// delivery was interrupted by a shutdown
+		if w.ctx.Err() != nil && persisted {
+			logrus.WithError(err).WithField("writer", w.Name()).Warn("[Engine] Delivery interrupted by shutdown, keeping events in disk queue")
+		} else {
+			delivered = w.deadLetter(err, events)
+		}
+		if !delivered && w.ctx.Err() != nil {
+			// The events are neither acknowledged nor released, so inputs
+			// read them again on the next start
+			return
+		}
+	}
+
+	for _, d := range batch {
+		d.done(delivered)
+	}
+}
+
+// flushNow makes every worker write its current batch and waits for them
+func (w *outputWorker) flushNow(ctx context.Context) error {
+	for range w.opts.Workers {
+		done := make(chan struct{})
+		select {
+		case w.flushReq <- done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (w *outputWorker) status() PluginStatus {
+	status := PluginStatus{
+		Name:  w.Name(),
+		Type:  "output",
+		State: StateRunning,
+		Counters: map[string]uint64{
+			"written": outputWritten.WithLabelValues(w.Name()).Value(),
+			"failed":  outputFailed.WithLabelValues(w.Name()).Value(),
+			"retried": outputRetried.WithLabelValues(w.Name()).Value(),
+			"dropped": w.dropped.Load(),
+			"queued":  uint64(len(w.queue)),
+		},
+	}
+	w.state.fill(&status)
+	return status
+}
+
+// deliver writes events to the output and retries according to its policy
+func (w *outputWorker) deliver(events []internal.Event) error {
+	policy := w.opts.Retry
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = w.write(events)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= policy.MaxAttempts {
+			break
+		}
+
+		outputRetried.WithLabelValues(w.Name()).Add(len(events))
+		backoff := policy.Backoff(attempt)
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"writer":  w.Name(),
+			"attempt": attempt,
+			"backoff": backoff,
+		}).Warn("[Engine] Coundnt write to output, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			return err
+		}
+	}
+
+	logrus.WithError(err).WithFields(logrus.Fields{
+		"writer":   w.Name(),
+		"attempts": policy.MaxAttempts,
+		"events":   len(events),
+	}).Error("[Engine] Coundnt write to output")
+	return err
+}
+
+func (w *outputWorker) write(events []internal.Event) error {
+	started := time.Now()
+	defer func() {
+		outputFlushDuration.WithLabelValues(w.Name()).Observe(time.Since(started).Seconds())
+	}()
+
+	if err := w.Write(events); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// deadLetter hands events the output failed to write to its dead letter file.
+// It returns false if the events could not be stored anywhere.
+func (w *outputWorker) deadLetter(lastErr error, events []internal.Event) bool {
+	dl := w.opts.DeadLetter
+	if dl == nil {
+		return false
+	}
+
+	if err := dl.Write(w.Name(), lastErr, events); err != nil {
+		logrus.WithError(err).WithField("writer", w.Name()).Error("[Engine] Coundnt write events to dead letter file")
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"writer": w.Name(),
+		"file":   dl.Path(),
+		"events": len(events),
+	}).Warn("[Engine] Moved events to dead letter file")
+	return true
+}
@@ -3,13 +3,22 @@ package filtergrep
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"regexp"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 )
 
+// Config is the configuration of the grep filter
+type Config struct {
+	Name    string   `config:"Name" default:"grep" desc:"The name of the filter instance."`
+	Match   string   `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	Op      string   `config:"Op" default:"and" enum:"and,or" desc:"The operation that should be performed based on the matches in the exclude and include."`
+	Include []string `config:"Include" format:"regex" desc:"Regex patterns in the RE2 syntax applied to the log line. Filters the log line out when not positive."`
+	Exclude []string `config:"Exclude" format:"regex" desc:"Regex patterns in the RE2 syntax applied to the log line. Filters the log line out when positive."`
+}
+
 type Grep struct {
 	name    string
 	match   string
@@ -27,38 +36,16 @@ func (g *Grep) MatchTag(inputTag string) bool {
 }
 
 func (g *Grep) Init(config map[string]any) error {
-	g.op = util.MustString(config["Op"])
-	if g.op == "" {
-		g.op = "and"
-	}
-
-	g.name = util.MustString(config["Name"])
-	if g.name == "" {
-		g.name = "grep"
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	g.match = util.MustString(config["Match"])
-	if g.match == "" {
-		g.match = "*"
-	}
-
-	if regex, exists := config["Include"]; exists {
-		var ok bool
-		if g.include, ok = regex.([]string); !ok {
-			return errors.New("cant convert regex patterns to string array")
-		}
-	}
-
-	if exclude, exists := config["Exclude"]; exists {
-		var ok bool
-		if g.exclude, ok = exclude.([]string); !ok {
-			return errors.New("cant convert exclude patterns to string array")
-		}
-	}
-
-	if g.op != "and" && g.op == "or" {
-		return fmt.Errorf("unsupported logic operator '%s' in grep filter", g.op)
-	}
+	g.name = cfg.Name
+	g.match = cfg.Match
+	g.op = cfg.Op
+	g.include = cfg.Include
+	g.exclude = cfg.Exclude
 
 	if len(g.exclude) == 0 && len(g.include) == 0 {
 		return errors.New("not exclude or include regex pattern provided for the grep filter")
@@ -91,3 +91,15 @@ func TestGrepProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestGrepInit(t *testing.T) {
+	g := &Grep{}
+	// Lists are decoded from YAML as []any
+	assert.NoError(t, g.Init(map[string]any{"Include": []any{"error.*"}, "Op": "OR"}))
+	assert.Equal(t, []string{"error.*"}, g.include)
+	assert.Equal(t, "or", g.op)
+	assert.Equal(t, "grep", g.Name())
+
+	assert.ErrorContains(t, g.Init(map[string]any{"Include": []any{"error"}, "Op": "xor"}), "Op: unsupported value 'xor'")
+	assert.ErrorContains(t, g.Init(map[string]any{}), "not exclude or include regex pattern provided")
+}
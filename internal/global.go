@@ -10,6 +10,29 @@ type Event struct {
 	RawData    string
 	ParsedData map[string]any
 	Metadata   Metadata
+	// OnAck is called by the engine once every output matching the event
+	// delivered it, or the event was filtered out. Inputs use it to commit
+	// their read position only for delivered events.
+	OnAck func() `json:"-"`
+	// OnNack is called by the engine instead of OnAck once it gave up on the
+	// event, e.g. it was dropped because an output queue was full or an
+	// output failed without a dead letter. Inputs must not wait for the
+	// event anymore.
+	OnNack func() `json:"-"`
+}
+
+// Ack reports the event as delivered to the input it originates from
+func (e *Event) Ack() {
+	if e.OnAck != nil {
+		e.OnAck()
+	}
+}
+
+// Nack reports the event as given up to the input it originates from
+func (e *Event) Nack() {
+	if e.OnNack != nil {
+		e.OnNack()
+	}
 }
 
 type Metadata struct {
@@ -18,6 +41,9 @@ type Metadata struct {
 	Tag         string
 	LineNum     int
 	InputSource string
+	// Fields holds further metadata some inputs provide, e.g. the common
+	// name of the client certificate of a tls connection
+	Fields map[string]string `json:",omitempty"`
 }
 
 // Plugin interface that all plugins must implement
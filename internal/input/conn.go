@@ -0,0 +1,51 @@
+package input
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+)
+
+// acceptRetryDelay is the wait before a listener accepts again after an error
+const acceptRetryDelay = 100 * time.Millisecond
+
+// WaitAcceptRetry waits before a listener accepts again after an error,
+// errors like too many open files don't go away right away. It returns
+// false if ctx is done before.
+func WaitAcceptRetry(ctx context.Context) bool {
+	select {
+	case <-time.After(acceptRetryDelay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// IdleTimeoutReader reads from a connection and fails a read once the
+// connection had no data for the timeout, so idle connections are closed
+type IdleTimeoutReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func NewIdleTimeoutReader(conn net.Conn, timeout time.Duration) *IdleTimeoutReader {
+	return &IdleTimeoutReader{conn: conn, timeout: timeout}
+}
+
+func (r *IdleTimeoutReader) Read(p []byte) (int, error) {
+	if err := r.conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		return 0, err
+	}
+	return r.conn.Read(p)
+}
+
+// SenderHost returns the host of a remote address, the local host name for
+// sources without one like a unix socket
+func SenderHost(source string) string {
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		return host
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
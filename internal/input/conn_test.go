@@ -0,0 +1,50 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleTimeoutReader(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	reader := NewIdleTimeoutReader(server, 20*time.Millisecond)
+	go client.Write([]byte("data"))
+	buf := make([]byte, 4)
+	n, err := reader.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(buf[:n]))
+
+	// Without data the read fails after the timeout
+	_, err = reader.Read(buf)
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+}
+
+func TestSenderHost(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", SenderHost("10.0.0.1:514"))
+	assert.Equal(t, "::1", SenderHost("[::1]:514"))
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, SenderHost("/dev/log"))
+}
+
+func TestWaitAcceptRetry(t *testing.T) {
+	start := time.Now()
+	assert.True(t, WaitAcceptRetry(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), acceptRetryDelay)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, WaitAcceptRetry(ctx))
+}
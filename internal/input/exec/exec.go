@@ -0,0 +1,274 @@
+package inputexec
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ModeInterval    = "interval"
+	ModeLongRunning = "long-running"
+)
+
+// waitDelay is how long the output of a killed command is still read, e.g.
+// when a process it started keeps stdout open
+const waitDelay = 5 * time.Second
+
+var readLine = input.Delimited('\n')
+
+// Config is the configuration of the exec input
+type Config struct {
+	Name           string          `config:"Name" default:"exec" desc:"The name of the input instance."`
+	Tag            string          `config:"Tag" default:"exec" desc:"A tag associated with the log events of stdout lines."`
+	StderrTag      string          `config:"StderrTag" desc:"The tag of the events of stderr lines. Defaults to the Tag with a .stderr suffix."`
+	Command        string          `config:"Command,required" desc:"The command to run, it is run by /bin/sh -c."`
+	Env            []string        `config:"Env" desc:"Environment variables of the command as KEY=value, in addition to the ones of the forwarder."`
+	Mode           string          `config:"Mode" default:"interval" enum:"interval,long-running" desc:"Whether the command is run on every Interval or kept running. See [Modes](#modes)."`
+	Interval       time.Duration   `config:"Interval" default:"10s" desc:"How often the command is run in the interval mode."`
+	Timeout        time.Duration   `config:"Timeout" desc:"How long a run may take in the interval mode before the command is killed. Not limited if unset."`
+	InitialBackoff time.Duration   `config:"InitialBackoff" default:"1s" desc:"The wait before the command is restarted after it exited in the long-running mode."`
+	MaxBackoff     time.Duration   `config:"MaxBackoff" default:"1m" desc:"The maximum wait before a restart, the wait doubles with every exit that follows shortly after the last one."`
+	MaxLineSize    schema.ByteSize `config:"MaxLineSize" default:"1MiB" min:"1" desc:"The maximum size of an output line. Longer lines are dropped."`
+}
+
+// Exec runs a command and turns the lines it writes to stdout and stderr
+// into events
+type Exec struct {
+	name           string
+	tag            string
+	stderrTag      string
+	command        string
+	source         string
+	env            []string
+	mode           string
+	interval       time.Duration
+	timeout        time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxLineSize    int
+	output         chan<- internal.Event
+	wg             sync.WaitGroup
+	ctx            context.Context
+	cancel         context.CancelFunc
+}
+
+func (e *Exec) Name() string {
+	return e.name
+}
+
+func (e *Exec) Tag() string {
+	return e.tag
+}
+
+func (e *Exec) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.Command) == "" {
+		return errors.New("exec input needs a Command")
+	}
+
+	e.name = cfg.Name
+	e.tag = cfg.Tag
+	e.stderrTag = cfg.StderrTag
+	if e.stderrTag == "" {
+		e.stderrTag = cfg.Tag + ".stderr"
+	}
+	e.command = cfg.Command
+	// The program of the command, e.g. journalctl for "journalctl -f | grep sshd"
+	e.source = filepath.Base(strings.Fields(cfg.Command)[0])
+	e.env = cfg.Env
+	e.mode = cfg.Mode
+	e.interval = cfg.Interval
+	e.timeout = cfg.Timeout
+	e.initialBackoff = cfg.InitialBackoff
+	e.maxBackoff = max(cfg.MaxBackoff, cfg.InitialBackoff)
+	e.maxLineSize = int(cfg.MaxLineSize)
+	return nil
+}
+
+func (e *Exec) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	e.ctx, e.cancel = context.WithCancel(parentCtx)
+	e.output = output
+
+	logrus.WithFields(logrus.Fields{
+		"command":  e.command,
+		"mode":     e.mode,
+		"interval": e.interval,
+	}).Info("Starting exec input")
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if e.mode == ModeLongRunning {
+			e.runLongRunning()
+		} else {
+			e.runInterval()
+		}
+	}()
+
+	return nil
+}
+
+// runInterval runs the command right away and then on every interval. A
+// run that takes longer than the interval delays the next one.
+func (e *Exec) runInterval() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := e.ctx, context.CancelFunc(func() {})
+		if e.timeout > 0 {
+			ctx, cancel = context.WithTimeout(e.ctx, e.timeout)
+		}
+		err := e.run(ctx)
+		cancel()
+		if e.ctx.Err() != nil {
+			return
+		}
+		e.exited(err)
+
+		select {
+		case <-ticker.C:
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// runLongRunning keeps the command running and restarts it with a backoff
+// whenever it exits. The backoff starts over once the command ran for
+// longer than the maximum backoff.
+func (e *Exec) runLongRunning() {
+	backoff := e.initialBackoff
+	for {
+		started := time.Now()
+		err := e.run(e.ctx)
+		if e.ctx.Err() != nil {
+			return
+		}
+		e.exited(err)
+
+		if time.Since(started) > e.maxBackoff {
+			backoff = e.initialBackoff
+		}
+		logrus.WithFields(logrus.Fields{
+			"command": e.command,
+			"backoff": backoff,
+		}).Warn("exec command exited, restarting it")
+
+		select {
+		case <-time.After(backoff):
+		case <-e.ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, e.maxBackoff)
+	}
+}
+
+// exited records the end of a run of the command
+func (e *Exec) exited(err error) {
+	if err != nil {
+		runs.WithLabelValues(e.name, "failure").Inc()
+		logrus.WithField("command", e.command).WithError(err).Warn("exec command failed")
+		return
+	}
+	runs.WithLabelValues(e.name, "success").Inc()
+	logrus.WithField("command", e.command).Debug("exec command finished")
+}
+
+// run runs the command once and passes its output on until it exited
+func (e *Exec) run(ctx context.Context) error {
+	cmd := shellCommand(ctx, e.command)
+	cmd.Env = append(os.Environ(), e.env...)
+	cmd.WaitDelay = waitDelay
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The output has to be read completely before waiting for the command
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		e.readLines(stdout, e.tag)
+	}()
+	go func() {
+		defer wg.Done()
+		e.readLines(stderr, e.stderrTag)
+	}()
+	wg.Wait()
+
+	return cmd.Wait()
+}
+
+// readLines turns the lines of one output of the command into events with
+// the given tag
+func (e *Exec) readLines(r io.Reader, tag string) {
+	reader := bufio.NewReader(r)
+	linenumber := 0
+	for {
+		line, err := readLine(reader, e.maxLineSize)
+		if errors.Is(err, input.ErrRecordTooLarge) {
+			oversizedLines.WithLabelValues(e.name).Inc()
+			logrus.WithField("command", e.command).Warn("exec output line larger than MaxLineSize, dropping it")
+			continue
+		}
+		if err != nil {
+			// The pipe is closed once the command exited
+			if err != io.EOF && !errors.Is(err, os.ErrClosed) {
+				logrus.WithField("command", e.command).WithError(err).Error("Coundnt read exec command output")
+			}
+			return
+		}
+
+		linenumber++
+		event := internal.Event{
+			Timestamp: time.Now(),
+			RawData:   string(line),
+			Metadata: internal.Metadata{
+				Source:  e.source,
+				LineNum: linenumber,
+			},
+		}
+		input.AddMetadata(&event, e)
+		event.Metadata.Tag = tag
+
+		// The command waits for the pipeline as it blocks on writing
+		select {
+		case e.output <- event:
+		case <-e.ctx.Done():
+			// Keep reading, so the command isn't blocked before it is killed
+		}
+	}
+}
+
+func (e *Exec) Exit() error {
+	logrus.Info("Stopping exec input")
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+	return nil
+}
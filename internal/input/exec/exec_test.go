@@ -0,0 +1,115 @@
+package inputexec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExec_Interval(t *testing.T) {
+	e := &Exec{}
+	require.NoError(t, e.Init(map[string]any{
+		"Name":     "uptime",
+		"Tag":      "host",
+		"Command":  `printf 'line one\nline two\n'; echo "$GREETING" >&2; exit 3`,
+		"Env":      []any{"GREETING=hello"},
+		"Interval": "20ms",
+	}))
+	before := runs.WithLabelValues("uptime", "failure").Value()
+
+	output := make(chan internal.Event, 100)
+	require.NoError(t, e.Start(context.Background(), output))
+
+	var stdout []string
+	var stderr internal.Event
+	for len(stdout) < 4 {
+		event := inputtest.Receive(t, output)
+		if event.Metadata.Tag == "host.stderr" {
+			stderr = event
+			continue
+		}
+		assert.Equal(t, "host", event.Metadata.Tag)
+		assert.Equal(t, "printf", event.Metadata.Source)
+		stdout = append(stdout, event.RawData)
+	}
+	require.NoError(t, e.Exit())
+
+	// The command ran at least twice
+	assert.Equal(t, []string{"line one", "line two", "line one", "line two"}, stdout)
+	assert.Equal(t, "hello", stderr.RawData)
+	assert.Equal(t, "uptime", stderr.Metadata.InputSource)
+	assert.GreaterOrEqual(t, runs.WithLabelValues("uptime", "failure").Value(), before+1)
+}
+
+func TestExec_Timeout(t *testing.T) {
+	e := &Exec{}
+	require.NoError(t, e.Init(map[string]any{
+		"Name":     "slow",
+		"Command":  "echo started; sleep 10",
+		"Interval": "1h",
+		"Timeout":  "50ms",
+	}))
+	before := runs.WithLabelValues("slow", "failure").Value()
+
+	output := make(chan internal.Event, 10)
+	require.NoError(t, e.Start(context.Background(), output))
+	defer e.Exit()
+
+	assert.Equal(t, "started", inputtest.Receive(t, output).RawData)
+	assert.Eventually(t, func() bool {
+		return runs.WithLabelValues("slow", "failure").Value() == before+1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestExec_LongRunningRestarts(t *testing.T) {
+	e := &Exec{}
+	require.NoError(t, e.Init(map[string]any{
+		"Name":           "daemon",
+		"Command":        "echo up",
+		"Mode":           "long-running",
+		"StderrTag":      "errors",
+		"InitialBackoff": "10ms",
+		"MaxBackoff":     "20ms",
+	}))
+
+	output := make(chan internal.Event, 10)
+	require.NoError(t, e.Start(context.Background(), output))
+	defer e.Exit()
+
+	// The command is started again after it exited
+	for range 3 {
+		event := inputtest.Receive(t, output)
+		assert.Equal(t, "up", event.RawData)
+		assert.Equal(t, "echo", event.Metadata.Source)
+	}
+}
+
+func TestExec_ExitKillsCommand(t *testing.T) {
+	e := &Exec{}
+	require.NoError(t, e.Init(map[string]any{
+		"Command": "echo running; sleep 30 | cat",
+		"Mode":    "long-running",
+	}))
+
+	output := make(chan internal.Event, 10)
+	require.NoError(t, e.Start(context.Background(), output))
+	assert.Equal(t, "running", inputtest.Receive(t, output).RawData)
+
+	// The processes started by the shell are killed as well, so Exit
+	// doesn't wait for the wait delay
+	started := time.Now()
+	require.NoError(t, e.Exit())
+	assert.Less(t, time.Since(started), waitDelay)
+}
+
+func TestExec_InitErrors(t *testing.T) {
+	assert.Error(t, (&Exec{}).Init(map[string]any{}))
+	assert.Error(t, (&Exec{}).Init(map[string]any{"Command": "  "}))
+	assert.Error(t, (&Exec{}).Init(map[string]any{"Command": "date", "Interval": "0s"}))
+	assert.Error(t, (&Exec{}).Init(map[string]any{"Command": "date", "Mode": "cron"}))
+}
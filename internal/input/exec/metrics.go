@@ -0,0 +1,10 @@
+package inputexec
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	runs = metrics.NewCounterVec("forwarder_exec_runs_total",
+		"Runs of the command of an exec input by whether it exited successfully.", "input", "result")
+	oversizedLines = metrics.NewCounterVec("forwarder_exec_oversized_lines_total",
+		"Output lines an exec input dropped because they were larger than MaxLineSize.", "input")
+)
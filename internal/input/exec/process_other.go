@@ -0,0 +1,13 @@
+//go:build !unix
+
+package inputexec
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand runs command with the shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package inputexec
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs command with the shell. The command gets its own
+// process group, which is killed as a whole when ctx is done, so processes
+// started by the shell don't outlive it.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
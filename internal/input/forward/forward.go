@@ -0,0 +1,367 @@
+package inputforward
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/msgpack"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the forward input
+type Config struct {
+	Name           string          `config:"Name" default:"forward" desc:"The name of the input instance."`
+	Tag            string          `config:"Tag" default:"forward" desc:"The tag of events whose message has an empty Fluent tag."`
+	TagPrefix      string          `config:"TagPrefix" desc:"A prefix added to the Fluent tag of every event, e.g. fluent. to match them with fluent.*."`
+	ListenAddr     string          `config:"ListenAddr" default:"0.0.0.0" desc:"The address on which the forward input should listen on."`
+	Port           int             `config:"Port" default:"24224" min:"1" max:"65535" desc:"The port on which the forward input should listen on."`
+	SharedKey      string          `config:"SharedKey" desc:"The key clients have to know. Enables the handshake when set."`
+	SelfHostname   string          `config:"SelfHostname" desc:"The hostname the input tells clients in the handshake, the hostname of the machine by default."`
+	MaxMessageSize schema.ByteSize `config:"MaxMessageSize" default:"16MiB" min:"1" desc:"The maximum size of a message, compressed entries are limited to it after decompressing them. Connections sending larger messages are closed."`
+	Timeout        time.Duration   `config:"Timeout" default:"10m" desc:"Connections without data for this long are closed."`
+	TLS            input.TLSConfig `config:"TLS" desc:"Terminates tls on the connections."`
+}
+
+type Forward struct {
+	name           string
+	tag            string
+	tagPrefix      string
+	addr           string
+	sharedKey      string
+	selfHostname   string
+	maxMessageSize int
+	timeout        time.Duration
+	tlsConfig      *tls.Config
+	listener       net.Listener
+	conns          map[net.Conn]struct{}
+	connsMu        sync.Mutex
+	wg             sync.WaitGroup
+	ctx            context.Context
+	cancel         context.CancelFunc
+	output         chan<- internal.Event
+}
+
+func (f *Forward) Name() string {
+	return f.name
+}
+
+func (f *Forward) Tag() string {
+	return f.tag
+}
+
+func (f *Forward) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+
+	f.name = cfg.Name
+	f.tag = cfg.Tag
+	f.tagPrefix = cfg.TagPrefix
+	f.addr = net.JoinHostPort(cfg.ListenAddr, fmt.Sprint(cfg.Port))
+	f.sharedKey = cfg.SharedKey
+	f.selfHostname = cfg.SelfHostname
+	if f.selfHostname == "" {
+		f.selfHostname, _ = os.Hostname()
+	}
+	f.maxMessageSize = int(cfg.MaxMessageSize)
+	f.timeout = cfg.Timeout
+
+	f.tlsConfig = nil
+	if cfg.TLS.Enabled() {
+		var err error
+		if f.tlsConfig, err = cfg.TLS.ServerConfig(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *Forward) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	f.ctx, f.cancel = context.WithCancel(parentCtx)
+	f.output = output
+	f.conns = map[net.Conn]struct{}{}
+
+	listener, err := net.Listen("tcp", f.addr)
+	if err != nil {
+		return fmt.Errorf("couldn't start forward input: %w", err)
+	}
+	if f.tlsConfig != nil {
+		listener = tls.NewListener(listener, f.tlsConfig)
+	}
+	f.listener = listener
+
+	f.wg.Add(1)
+	go f.accept(parentCtx)
+
+	logrus.WithFields(logrus.Fields{
+		"addr":      f.addr,
+		"tls":       f.tlsConfig != nil,
+		"handshake": f.sharedKey != "",
+	}).Info("Starting forward input")
+	return nil
+}
+
+func (f *Forward) accept(parentCtx context.Context) {
+	defer f.wg.Done()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			if f.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				input.ReportError(parentCtx, fmt.Errorf("forward listener closed: %w", err))
+				return
+			}
+			logrus.WithError(err).Error("could not accept forward connection")
+			if !input.WaitAcceptRetry(f.ctx) {
+				return
+			}
+			continue
+		}
+
+		f.connsMu.Lock()
+		f.conns[conn] = struct{}{}
+		f.connsMu.Unlock()
+
+		f.wg.Add(1)
+		go f.handleConnection(conn)
+	}
+}
+
+// handleConnection reads the messages of a connection until it is closed.
+// The chunk of a message is acknowledged once its entries were delivered.
+func (f *Forward) handleConnection(conn net.Conn) {
+	defer f.wg.Done()
+	defer func() {
+		f.connsMu.Lock()
+		delete(f.conns, conn)
+		f.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	remoteAddr := conn.RemoteAddr().String()
+	logger := logrus.WithField("remote_addr", remoteAddr)
+	logger.Debug("New forward connection established")
+
+	var fields map[string]string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(f.ctx, f.timeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			logger.WithError(err).Warn("tls handshake failed")
+			return
+		}
+		state := tlsConn.ConnectionState()
+		if cn := input.ClientCN(&state); cn != "" {
+			fields = map[string]string{input.ClientCNField: cn}
+		}
+	}
+
+	decoder := msgpack.NewDecoder(input.NewIdleTimeoutReader(conn, f.timeout), f.maxMessageSize)
+	host := input.SenderHost(remoteAddr)
+	if f.sharedKey != "" {
+		clientHostname, err := f.handshake(conn, decoder)
+		if err != nil {
+			authFailures.WithLabelValues(f.name).Inc()
+			logger.WithError(err).Warn("forward handshake failed")
+			return
+		}
+		host = clientHostname
+	}
+
+	var writeMu sync.Mutex
+	lineNum := 0
+	for {
+		value, err := decoder.Decode()
+		if err != nil {
+			switch {
+			case errors.Is(err, msgpack.ErrTooLarge):
+				droppedMessages.WithLabelValues(f.name, "too_large").Inc()
+				logger.Warn("forward message larger than MaxMessageSize, closing connection")
+			case err == io.EOF || f.ctx.Err() != nil:
+			default:
+				logger.WithError(err).Debug("Closing forward connection")
+			}
+			return
+		}
+
+		msg, err := decodeMessage(value, f.maxMessageSize)
+		if err != nil {
+			reason := "invalid"
+			if errors.Is(err, errMessageTooLarge) {
+				reason = "too_large"
+			}
+			droppedMessages.WithLabelValues(f.name, reason).Inc()
+			logger.WithError(err).Debug("Coundnt decode forward message")
+			continue
+		}
+
+		var ack *chunkAck
+		if msg.chunk != "" {
+			chunk := msg.chunk
+			ack = newChunkAck(len(msg.entries), func() {
+				// Acks are sent by the engine while the next messages are read
+				writeMu.Lock()
+				defer writeMu.Unlock()
+				if err := f.write(conn, map[string]any{"ack": chunk}); err != nil {
+					logger.WithError(err).Debug("Coundnt send forward ack")
+				}
+			})
+		}
+
+		for _, e := range msg.entries {
+			lineNum++
+			if !f.emit(msg.tag, e, internal.Metadata{Source: remoteAddr, Host: host, LineNum: lineNum, Fields: maps.Clone(fields)}, ack) {
+				return
+			}
+		}
+	}
+}
+
+// chunkAck acknowledges the chunk of a message once the engine acknowledged
+// every entry of it. A chunk with an entry the engine gave up on is not
+// acknowledged, so the client sends it again.
+type chunkAck struct {
+	remaining atomic.Int32
+	failed    atomic.Bool
+	send      func()
+}
+
+func newChunkAck(entries int, send func()) *chunkAck {
+	c := &chunkAck{send: send}
+	c.remaining.Store(int32(entries))
+	if entries == 0 {
+		send()
+	}
+	return c
+}
+
+func (c *chunkAck) done(delivered bool) {
+	if !delivered {
+		c.failed.Store(true)
+	}
+	if c.remaining.Add(-1) == 0 && !c.failed.Load() {
+		c.send()
+	}
+}
+
+// emit hands an entry to the engine. It waits for room in the pipeline, the
+// client is slowed down instead of losing events. It returns false once the
+// input is stopped.
+func (f *Forward) emit(tag string, e entry, metadata internal.Metadata, ack *chunkAck) bool {
+	raw, _ := json.Marshal(e.record)
+	event := internal.Event{
+		Timestamp:  e.time,
+		RawData:    string(raw),
+		ParsedData: e.record,
+		Metadata:   metadata,
+	}
+	input.AddMetadata(&event, f)
+	event.Metadata.Host = metadata.Host
+	if tag != "" {
+		event.Metadata.Tag = f.tagPrefix + tag
+	}
+	if ack != nil {
+		event.OnAck = func() { ack.done(true) }
+		event.OnNack = func() { ack.done(false) }
+	}
+
+	select {
+	case f.output <- event:
+		return true
+	case <-f.ctx.Done():
+		return false
+	}
+}
+
+// handshake authenticates a client with the shared key. The input sends a
+// HELO with a nonce, the client answers with a PING holding the digest of
+// the key and the input confirms with a PONG holding its own digest. It
+// returns the hostname of the client.
+func (f *Forward) handshake(conn net.Conn, decoder *msgpack.Decoder) (string, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	if err := f.write(conn, []any{"HELO", map[string]any{"nonce": nonce, "auth": "", "keepalive": true}}); err != nil {
+		return "", err
+	}
+
+	value, err := decoder.Decode()
+	if err != nil {
+		return "", fmt.Errorf("cant read PING: %w", err)
+	}
+	ping, _ := value.([]any)
+	if len(ping) < 4 {
+		return "", errors.New("invalid PING")
+	}
+	kind, _ := asString(ping[0])
+	hostname, _ := asString(ping[1])
+	salt, _ := asString(ping[2])
+	clientDigest, _ := asString(ping[3])
+	if kind != "PING" {
+		return "", fmt.Errorf("expected PING, got '%s'", kind)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clientDigest), []byte(digest(salt, hostname, string(nonce), f.sharedKey))) != 1 {
+		f.write(conn, []any{"PONG", false, "shared key mismatch", f.selfHostname, ""})
+		return "", fmt.Errorf("shared key of %s doesn't match", hostname)
+	}
+	if err := f.write(conn, []any{"PONG", true, "", f.selfHostname, digest(salt, f.selfHostname, string(nonce), f.sharedKey)}); err != nil {
+		return "", err
+	}
+	return hostname, nil
+}
+
+// write sends a value to a client
+func (f *Forward) write(conn net.Conn, value any) error {
+	data, err := msgpack.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(f.timeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+func (f *Forward) Exit() error {
+	logrus.WithField("name", f.name).Info("Stopping forward input")
+	if f.cancel != nil {
+		f.cancel()
+	}
+
+	if f.listener != nil {
+		if err := f.listener.Close(); err != nil {
+			logrus.WithError(err).Error("could not close forward listener")
+		}
+	}
+
+	f.connsMu.Lock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+	f.connsMu.Unlock()
+
+	f.wg.Wait()
+	f.listener = nil
+	return nil
+}
@@ -0,0 +1,254 @@
+package inputforward
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/tlstest"
+	"github.com/MuchTitan/go-log-forwarder/internal/msgpack"
+	outputforward "github.com/MuchTitan/go-log-forwarder/internal/output/forward"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a raw forward client
+type client struct {
+	conn    net.Conn
+	decoder *msgpack.Decoder
+}
+
+func dial(t *testing.T, f *Forward) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", f.listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	return &client{conn: conn, decoder: msgpack.NewDecoder(conn, 0)}
+}
+
+func (c *client) send(t *testing.T, value any) {
+	t.Helper()
+	data, err := msgpack.Marshal(value)
+	require.NoError(t, err)
+	_, err = c.conn.Write(data)
+	require.NoError(t, err)
+}
+
+func (c *client) read(t *testing.T) any {
+	t.Helper()
+	value, err := c.decoder.Decode()
+	require.NoError(t, err)
+	return value
+}
+
+func TestForward_Init(t *testing.T) {
+	f := &Forward{}
+	require.NoError(t, f.Init(map[string]any{}))
+	assert.Equal(t, "forward", f.name)
+	assert.Equal(t, "0.0.0.0:24224", f.addr)
+	assert.Equal(t, 16<<20, f.maxMessageSize)
+	assert.NotEmpty(t, f.selfHostname)
+
+	assert.Error(t, f.Init(map[string]any{"TLS": map[string]any{"CertFile": "cert.pem"}}))
+}
+
+func TestForward_MessageAndAck(t *testing.T) {
+	f := &Forward{}
+	output := inputtest.Start(t, f, map[string]any{"ListenAddr": "127.0.0.1", "Port": inputtest.FreePort(t, "tcp"), "TagPrefix": "fluent."})
+	c := dial(t, f)
+
+	sent := time.Unix(1700000000, 42)
+	c.send(t, []any{"app.web", sent, map[string]any{"log": "started", "pid": 7}, map[string]any{"chunk": "abc"}})
+
+	// The chunk is acknowledged once the engine delivered the event
+	event := inputtest.Receive(t, output)
+	event.Ack()
+	assert.Equal(t, map[string]any{"ack": "abc"}, c.read(t))
+
+	assert.Equal(t, "fluent.app.web", event.Metadata.Tag)
+	assert.Equal(t, sent, event.Timestamp)
+	assert.Equal(t, map[string]any{"log": "started", "pid": int64(7)}, event.ParsedData)
+	assert.JSONEq(t, `{"log":"started","pid":7}`, event.RawData)
+	assert.Equal(t, "127.0.0.1", event.Metadata.Host)
+	assert.Equal(t, "forward", event.Metadata.InputSource)
+	assert.Equal(t, 1, event.Metadata.LineNum)
+
+	// Messages without a tag keep the one of the input
+	c.send(t, []any{"", int64(1700000000), map[string]any{"log": "a"}})
+	assert.Equal(t, "forward", inputtest.Receive(t, output).Metadata.Tag)
+}
+
+func TestForward_NoAckForUndeliveredChunk(t *testing.T) {
+	f := &Forward{}
+	output := inputtest.Start(t, f, map[string]any{"ListenAddr": "127.0.0.1", "Port": inputtest.FreePort(t, "tcp")})
+	c := dial(t, f)
+
+	entries := []any{[]any{int64(1), map[string]any{"log": "a"}}, []any{int64(2), map[string]any{"log": "b"}}}
+	c.send(t, []any{"app", entries, map[string]any{"chunk": "lost"}})
+	delivered, dropped := inputtest.Receive(t, output), inputtest.Receive(t, output)
+	delivered.Ack()
+	dropped.Nack()
+
+	// Only the chunk that was delivered completely is acknowledged
+	c.send(t, []any{"app", int64(3), map[string]any{"log": "c"}, map[string]any{"chunk": "kept"}})
+	event := inputtest.Receive(t, output)
+	event.Ack()
+	assert.Equal(t, map[string]any{"ack": "kept"}, c.read(t))
+}
+
+func TestForward_InvalidMessages(t *testing.T) {
+	f := &Forward{}
+	output := inputtest.Start(t, f, map[string]any{"ListenAddr": "127.0.0.1", "Port": inputtest.FreePort(t, "tcp"), "MaxMessageSize": "1KiB"})
+	c := dial(t, f)
+
+	invalid := droppedMessages.WithLabelValues(f.name, "invalid").Value()
+	tooLarge := droppedMessages.WithLabelValues(f.name, "too_large").Value()
+
+	// An invalid message is dropped, the connection stays open
+	c.send(t, []any{"app", "no entries"})
+	c.send(t, []any{"app", int64(1), map[string]any{"log": "valid"}})
+	assert.Equal(t, "valid", inputtest.Receive(t, output).ParsedData["log"])
+	assert.Equal(t, invalid+1, droppedMessages.WithLabelValues(f.name, "invalid").Value())
+
+	// A message larger than the limit closes the connection
+	large := make([]byte, 2048)
+	c.send(t, []any{"app", int64(1), map[string]any{"log": string(large)}})
+	_, err := c.decoder.Decode()
+	assert.Error(t, err)
+	assert.Equal(t, tooLarge+1, droppedMessages.WithLabelValues(f.name, "too_large").Value())
+}
+
+func TestForward_Handshake(t *testing.T) {
+	f := &Forward{}
+	output := inputtest.Start(t, f, map[string]any{
+		"ListenAddr":   "127.0.0.1",
+		"Port":         inputtest.FreePort(t, "tcp"),
+		"SharedKey":    "secret",
+		"SelfHostname": "aggregator",
+	})
+
+	handshake := func(c *client, key string) []any {
+		helo := c.read(t).([]any)
+		require.Equal(t, "HELO", helo[0])
+		options := helo[1].(map[string]any)
+		nonce := string(options["nonce"].([]byte))
+		assert.Equal(t, "", options["auth"])
+
+		c.send(t, []any{"PING", "agent-1", "salt", digest("salt", "agent-1", nonce, key), "", ""})
+		pong := c.read(t).([]any)
+		require.Equal(t, "PONG", pong[0])
+		if pong[1] == true {
+			assert.Equal(t, "aggregator", pong[3])
+			assert.Equal(t, digest("salt", "aggregator", nonce, "secret"), pong[4])
+		}
+		return pong
+	}
+
+	c := dial(t, f)
+	pong := handshake(c, "secret")
+	assert.Equal(t, true, pong[1])
+	c.send(t, []any{"app", int64(1), map[string]any{"log": "a"}})
+	assert.Equal(t, "agent-1", inputtest.Receive(t, output).Metadata.Host)
+
+	failures := authFailures.WithLabelValues(f.name).Value()
+	c = dial(t, f)
+	pong = handshake(c, "wrong")
+	assert.Equal(t, false, pong[1])
+	assert.Equal(t, "shared key mismatch", pong[2])
+	_, err := c.decoder.Decode()
+	assert.Error(t, err)
+	assert.Equal(t, failures+1, authFailures.WithLabelValues(f.name).Value())
+}
+
+func TestForward_FromForwardOutput(t *testing.T) {
+	files := tlstest.New(t)
+
+	tests := []struct {
+		name   string
+		input  map[string]any
+		output map[string]any
+	}{
+		{"forward mode", map[string]any{}, map[string]any{}},
+		{
+			"compressed with ack, handshake and tls",
+			map[string]any{
+				"SharedKey": "secret",
+				"TLS":       map[string]any{"CertFile": files.CertFile, "KeyFile": files.KeyFile},
+			},
+			map[string]any{
+				"Compress":     true,
+				"RequireAck":   true,
+				"SharedKey":    "secret",
+				"SelfHostname": "agent-1",
+				"TLS":          true,
+				"VerifyTLS":    true,
+				"CAFile":       files.CAFile,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := inputtest.FreePort(t, "tcp")
+			tt.input["ListenAddr"] = "localhost"
+			tt.input["Port"] = port
+			output := inputtest.Start(t, &Forward{}, tt.input)
+
+			out := &outputforward.Forward{}
+			tt.output["Host"] = "localhost"
+			tt.output["Port"] = port
+			require.NoError(t, out.Init(tt.output))
+			defer out.Exit()
+
+			// The engine acknowledges the events, which the output waits for
+			// with RequireAck
+			delivered := make(chan internal.Event, 10)
+			go func() {
+				for event := range output {
+					event.Ack()
+					delivered <- event
+				}
+			}()
+
+			sent := time.Unix(1700000000, 123456789)
+			events := []internal.Event{
+				{ParsedData: map[string]any{"msg": "a"}, Timestamp: sent, Metadata: internal.Metadata{Tag: "app"}},
+				{RawData: "raw line", Timestamp: sent, Metadata: internal.Metadata{Tag: "system"}},
+				{ParsedData: map[string]any{"msg": "b"}, Timestamp: sent, Metadata: internal.Metadata{Tag: "app"}},
+			}
+			require.NoError(t, out.Write(events))
+			// The connection is kept for further writes
+			require.NoError(t, out.Write(events[:1]))
+
+			for _, want := range []struct {
+				tag    string
+				record map[string]any
+			}{
+				{"app", map[string]any{"msg": "a"}},
+				{"app", map[string]any{"msg": "b"}},
+				{"system", map[string]any{"log": "raw line"}},
+				{"app", map[string]any{"msg": "a"}},
+			} {
+				event := inputtest.Receive(t, delivered)
+				assert.Equal(t, want.tag, event.Metadata.Tag)
+				assert.Equal(t, want.record, event.ParsedData)
+				assert.True(t, sent.Equal(event.Timestamp))
+			}
+		})
+	}
+}
+
+func TestForward_OutputHandshakeRejected(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	inputtest.Start(t, &Forward{}, map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "SharedKey": "secret"})
+
+	out := &outputforward.Forward{}
+	require.NoError(t, out.Init(map[string]any{"Port": port, "SharedKey": "wrong"}))
+	defer out.Exit()
+
+	err := out.Write([]internal.Event{{RawData: "a", Metadata: internal.Metadata{Tag: "app"}}})
+	assert.ErrorContains(t, err, "shared key mismatch")
+}
@@ -0,0 +1,10 @@
+package inputforward
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	droppedMessages = metrics.NewCounterVec("forwarder_forward_dropped_messages_total",
+		"Messages a forward input dropped, by reason.", "input", "reason")
+	authFailures = metrics.NewCounterVec("forwarder_forward_auth_failures_total",
+		"Connections a forward input closed because the handshake failed.", "input")
+)
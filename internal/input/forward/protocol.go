@@ -0,0 +1,208 @@
+package inputforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/msgpack"
+)
+
+var (
+	errInvalidMessage  = errors.New("invalid forward message")
+	errMessageTooLarge = errors.New("forward message too large")
+)
+
+// entry is a record of a forward message with its time
+type entry struct {
+	time   time.Time
+	record map[string]any
+}
+
+// message is a decoded forward message, whatever mode it was sent in
+type message struct {
+	tag     string
+	entries []entry
+	// chunk is the id the client wants to be acknowledged, empty if it
+	// doesn't wait for an ack
+	chunk string
+}
+
+// decodeMessage decodes a message of the Message, Forward, PackedForward
+// or CompressedPackedForward mode. The entries of packed messages may not
+// be larger than maxSize after decompressing them.
+func decodeMessage(value any, maxSize int) (message, error) {
+	array, ok := value.([]any)
+	if !ok || len(array) < 2 {
+		return message{}, fmt.Errorf("%w: no array of a tag and entries", errInvalidMessage)
+	}
+	tag, ok := asString(array[0])
+	if !ok {
+		return message{}, fmt.Errorf("%w: tag is no string", errInvalidMessage)
+	}
+	msg := message{tag: tag}
+
+	var option any
+	var err error
+	switch data := array[1].(type) {
+	case []any:
+		// Forward mode: [tag, [[time, record], ...], option]
+		for _, item := range data {
+			var e entry
+			if e, err = decodeEntry(item); err != nil {
+				return message{}, err
+			}
+			msg.entries = append(msg.entries, e)
+		}
+		option = optionAt(array, 2)
+	case string, []byte:
+		// PackedForward mode: [tag, entries as one msgpack stream, option]
+		option = optionAt(array, 2)
+		packed, _ := asString(data)
+		if msg.entries, err = decodePacked([]byte(packed), option, maxSize); err != nil {
+			return message{}, err
+		}
+	default:
+		// Message mode: [tag, time, record, option]
+		if len(array) < 3 {
+			return message{}, fmt.Errorf("%w: message without record", errInvalidMessage)
+		}
+		e, err := decodeEntry([]any{array[1], array[2]})
+		if err != nil {
+			return message{}, err
+		}
+		msg.entries = []entry{e}
+		option = optionAt(array, 3)
+	}
+
+	if options, ok := option.(map[string]any); ok {
+		msg.chunk, _ = asString(options["chunk"])
+	}
+	return msg, nil
+}
+
+func optionAt(array []any, i int) any {
+	if i < len(array) {
+		return array[i]
+	}
+	return nil
+}
+
+// decodePacked decodes the entries of a PackedForward message, which are
+// gzip compressed in the CompressedPackedForward mode
+func decodePacked(packed []byte, option any, maxSize int) ([]entry, error) {
+	options, _ := option.(map[string]any)
+	switch compressed, _ := asString(options["compressed"]); compressed {
+	case "", "text":
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(packed))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidMessage, err)
+		}
+		defer gz.Close()
+		if packed, err = io.ReadAll(io.LimitReader(gz, int64(maxSize)+1)); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidMessage, err)
+		}
+		if len(packed) > maxSize {
+			return nil, errMessageTooLarge
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported compression '%s'", errInvalidMessage, compressed)
+	}
+
+	var entries []entry
+	decoder := msgpack.NewDecoder(bytes.NewReader(packed), 0)
+	for {
+		value, err := decoder.Decode()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidMessage, err)
+		}
+		e, err := decodeEntry(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// decodeEntry decodes an entry [time, record]
+func decodeEntry(value any) (entry, error) {
+	array, ok := value.([]any)
+	if !ok || len(array) < 2 {
+		return entry{}, fmt.Errorf("%w: entry is no array of time and record", errInvalidMessage)
+	}
+	timestamp, ok := decodeTime(array[0])
+	if !ok {
+		return entry{}, fmt.Errorf("%w: invalid time %v", errInvalidMessage, array[0])
+	}
+	record, ok := array[1].(map[string]any)
+	if !ok {
+		return entry{}, fmt.Errorf("%w: record is no map", errInvalidMessage)
+	}
+	return entry{time: timestamp, record: normalize(record).(map[string]any)}, nil
+}
+
+// decodeTime decodes the time of an entry, the seconds since the epoch or
+// an EventTime. Some clients send the seconds as float.
+func decodeTime(value any) (time.Time, bool) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, true
+	case int64:
+		return time.Unix(t, 0), true
+	case uint64:
+		return time.Unix(int64(t), 0), true
+	case float64:
+		// Float times are rounded to microseconds, their precision
+		whole, fraction := math.Modf(t)
+		return time.Unix(int64(whole), int64(math.Round(fraction*1e6))*int64(time.Microsecond)), true
+	}
+	return time.Time{}, false
+}
+
+// normalize turns the binary strings some clients send into strings, so the
+// records can be handled like JSON
+func normalize(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+	}
+	return value
+}
+
+func asString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// digest returns the hex encoded SHA-512 of the concatenated parts, the
+// handshake proves the knowledge of the shared key with it
+func digest(parts ...string) string {
+	h := sha512.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,108 @@
+package inputforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/msgpack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func packEntries(t *testing.T, entries ...[]any) []byte {
+	t.Helper()
+	var packed []byte
+	for _, e := range entries {
+		var err error
+		packed, err = msgpack.Append(packed, e)
+		require.NoError(t, err)
+	}
+	return packed
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	gz.Write(data)
+	require.NoError(t, gz.Close())
+	return buffer.Bytes()
+}
+
+func TestDecodeMessage(t *testing.T) {
+	sent := time.Unix(1700000000, 500)
+	packed := packEntries(t, []any{sent, map[string]any{"n": 1}}, []any{int64(1700000001), map[string]any{"n": 2}})
+
+	tests := []struct {
+		name  string
+		value []any
+	}{
+		{"forward", []any{"app", []any{[]any{sent, map[string]any{"n": 1}}, []any{int64(1700000001), map[string]any{"n": 2}}}, map[string]any{"chunk": "c1"}}},
+		{"packed forward", []any{"app", packed, map[string]any{"chunk": "c1", "size": 2}}},
+		{"packed forward as string", []any{"app", string(packed), map[string]any{"chunk": []byte("c1")}}},
+		{"compressed packed forward", []any{"app", gzipped(t, packed), map[string]any{"chunk": "c1", "compressed": "gzip"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := msgpack.Marshal(tt.value)
+			require.NoError(t, err)
+			value, err := msgpack.Unmarshal(data)
+			require.NoError(t, err)
+
+			msg, err := decodeMessage(value, 1024)
+			require.NoError(t, err)
+			assert.Equal(t, "app", msg.tag)
+			assert.Equal(t, "c1", msg.chunk)
+			require.Len(t, msg.entries, 2)
+			assert.Equal(t, sent, msg.entries[0].time)
+			assert.Equal(t, map[string]any{"n": int64(1)}, msg.entries[0].record)
+			assert.Equal(t, time.Unix(1700000001, 0), msg.entries[1].time)
+		})
+	}
+
+	t.Run("message", func(t *testing.T) {
+		msg, err := decodeMessage([]any{"app", 1700000000.25, map[string]any{"log": []byte("line")}}, 1024)
+		require.NoError(t, err)
+		assert.Empty(t, msg.chunk)
+		require.Len(t, msg.entries, 1)
+		assert.Equal(t, time.Unix(1700000000, 250*int64(time.Millisecond)), msg.entries[0].time)
+		assert.Equal(t, map[string]any{"log": "line"}, msg.entries[0].record)
+	})
+}
+
+func TestDecodeMessage_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"no array", map[string]any{"tag": "app"}},
+		{"no tag", []any{int64(1), []any{}}},
+		{"message without record", []any{"app", int64(1)}},
+		{"invalid time", []any{"app", "yesterday", map[string]any{}}},
+		{"record no map", []any{"app", []any{[]any{int64(1), "line"}}}},
+		{"unsupported compression", []any{"app", []byte{}, map[string]any{"compressed": "zstd"}}},
+		{"invalid gzip", []any{"app", []byte("plain"), map[string]any{"compressed": "gzip"}}},
+		{"invalid packed entries", []any{"app", []byte{0x92, 0x01}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeMessage(tt.value, 1024)
+			assert.ErrorIs(t, err, errInvalidMessage)
+		})
+	}
+
+	large := packEntries(t, []any{int64(1), map[string]any{"log": string(bytes.Repeat([]byte("a"), 2048))}})
+	_, err := decodeMessage([]any{"app", gzipped(t, large), map[string]any{"compressed": "gzip"}}, 1024)
+	assert.ErrorIs(t, err, errMessageTooLarge)
+}
+
+func TestDigest(t *testing.T) {
+	// The digest of the handshake, as computed by fluentd
+	assert.Equal(t,
+		"9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+		digest("", "", "", "hello"))
+}
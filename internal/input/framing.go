@@ -0,0 +1,117 @@
+package input
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrRecordTooLarge is returned by a Framer for a record longer than the
+// maximum size. The record is skipped, so the next one can be read.
+var ErrRecordTooLarge = errors.New("record too large")
+
+// Framer reads the next record of a stream
+type Framer func(reader *bufio.Reader, maxSize int) ([]byte, error)
+
+// Delimited reads records that end with delim, a trailing \r is removed
+// as well for \n. A record that is still incomplete when the stream ends is
+// returned as well.
+func Delimited(delim byte) Framer {
+	return func(reader *bufio.Reader, maxSize int) ([]byte, error) {
+		var record []byte
+		tooLarge := false
+		for {
+			// A record longer than the buffer of the reader is carried over
+			// across several reads
+			chunk, err := reader.ReadSlice(delim)
+			if !tooLarge {
+				record = append(record, chunk...)
+				if len(trimDelimiter(record, delim)) > maxSize {
+					tooLarge = true
+					record = nil
+				}
+			}
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if tooLarge {
+				if err == nil {
+					err = ErrRecordTooLarge
+				}
+				return nil, err
+			}
+			if err == io.EOF && len(record) > 0 {
+				return trimDelimiter(record, delim), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return trimDelimiter(record, delim), nil
+		}
+	}
+}
+
+func trimDelimiter(record []byte, delim byte) []byte {
+	if n := len(record); n > 0 && record[n-1] == delim {
+		record = record[:n-1]
+	}
+	if n := len(record); delim == '\n' && n > 0 && record[n-1] == '\r' {
+		record = record[:n-1]
+	}
+	return record
+}
+
+// ReadOctetCounted reads records prefixed with their length in ASCII and a
+// space, e.g. "5 hello", like the octet counting of RFC 6587
+func ReadOctetCounted(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var digits []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' {
+			break
+		}
+		if b < '0' || b > '9' || len(digits) >= 10 {
+			return nil, fmt.Errorf("invalid octet count '%s%c'", digits, b)
+		}
+		digits = append(digits, b)
+	}
+
+	length, err := strconv.Atoi(string(digits))
+	if err != nil {
+		return nil, fmt.Errorf("invalid octet count '%s'", digits)
+	}
+	return readRecord(reader, length, maxSize)
+}
+
+// ReadLengthPrefixed reads records prefixed with their length as 4 byte big endian integer
+func ReadLengthPrefixed(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+		return nil, err
+	}
+	return readRecord(reader, int(binary.BigEndian.Uint32(prefix[:])), maxSize)
+}
+
+func readRecord(reader *bufio.Reader, length, maxSize int) ([]byte, error) {
+	if length > maxSize {
+		if _, err := reader.Discard(length); err != nil {
+			return nil, err
+		}
+		return nil, ErrRecordTooLarge
+	}
+
+	record := make([]byte, length)
+	if _, err := io.ReadFull(reader, record); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("connection closed within a record: %w", err)
+		}
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,66 @@
+package input
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads records until the reader is exhausted, records that are
+// too large are recorded as "<too large>"
+func readAll(t *testing.T, framing Framer, data string, maxSize int) []string {
+	t.Helper()
+	reader := bufio.NewReaderSize(strings.NewReader(data), 16)
+	var records []string
+	for {
+		record, err := framing(reader, maxSize)
+		if err == ErrRecordTooLarge {
+			records = append(records, "<too large>")
+			continue
+		}
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, string(record))
+	}
+}
+
+func TestFramers(t *testing.T) {
+	long := strings.Repeat("x", 40)
+
+	tests := []struct {
+		name    string
+		framing Framer
+		data    string
+		want    []string
+	}{
+		{"newline", Delimited('\n'), "first\r\nsecond\n\n" + long + "\n" + long + long + "\nlast", []string{"first", "second", "", long, "<too large>", "last"}},
+		{"null byte", Delimited(0), "a\nb\x00c\x00", []string{"a\nb", "c"}},
+		{"octet counting", ReadOctetCounted, "5 hello12 with\nnewline0 ", []string{"hello", "with\nnewline", ""}},
+		{"octet counting too large", ReadOctetCounted, "100 " + strings.Repeat("y", 100) + "2 ok", []string{"<too large>", "ok"}},
+		{"length prefixed", ReadLengthPrefixed, "\x00\x00\x00\x03abc\x00\x00\x00\x01d", []string{"abc", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, readAll(t, tt.framing, tt.data, 50))
+		})
+	}
+}
+
+func TestFramers_Errors(t *testing.T) {
+	_, err := ReadOctetCounted(bufio.NewReader(strings.NewReader("12a hello")), 100)
+	assert.EqualError(t, err, "invalid octet count '12a'")
+
+	_, err = ReadLengthPrefixed(bufio.NewReader(strings.NewReader("\x00\x00\x00\x09abc")), 100)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDelimited_MaxSizeExcludesDelimiter(t *testing.T) {
+	assert.Equal(t, []string{"12345", "<too large>", "last"}, readAll(t, Delimited('\n'), "12345\r\n123456\nlast", 5))
+}
@@ -0,0 +1,233 @@
+package inputgelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	chunkHeaderLen = 12
+	// maxChunks is the maximum number of chunks of a message the GELF spec allows
+	maxChunks = 128
+)
+
+var (
+	chunkMagic = []byte{0x1e, 0x0f}
+	gzipMagic  = []byte{0x1f, 0x8b}
+
+	errInvalidChunk    = errors.New("invalid gelf chunk")
+	errMessageTooLarge = errors.New("gelf message too large")
+)
+
+// isChunk reports whether a datagram is a chunk of a larger message
+func isChunk(datagram []byte) bool {
+	return bytes.HasPrefix(datagram, chunkMagic)
+}
+
+// decompress returns the JSON of a payload, which is either gzip or zlib
+// compressed or plain. The JSON may not be larger than maxSize.
+func decompress(payload []byte, maxSize int) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch {
+	case bytes.HasPrefix(payload, gzipMagic):
+		reader, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0] == 0x78 && (int(payload[0])<<8|int(payload[1]))%31 == 0:
+		// The zlib header of the default window size
+		reader, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		if len(payload) > maxSize {
+			return nil, errMessageTooLarge
+		}
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cant decompress gelf message: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("cant decompress gelf message: %w", err)
+	}
+	if len(data) > maxSize {
+		return nil, errMessageTooLarge
+	}
+	return data, nil
+}
+
+// message is a decoded GELF message
+type message struct {
+	fields    map[string]any
+	host      string
+	timestamp time.Time
+}
+
+// decodeMessage decodes the JSON of a GELF message. The host and timestamp
+// are taken out of the fields and the underscore of additional fields is
+// removed, e.g. _user_id becomes user_id.
+func decodeMessage(data []byte) (message, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return message{}, fmt.Errorf("invalid gelf message: %w", err)
+	}
+	if short, ok := raw["short_message"].(string); !ok || short == "" {
+		return message{}, errors.New("invalid gelf message: missing short_message")
+	}
+
+	msg := message{fields: make(map[string]any, len(raw))}
+	for key, value := range raw {
+		switch key {
+		case "version":
+		case "host":
+			msg.host, _ = value.(string)
+		case "timestamp":
+			if seconds, ok := value.(float64); ok && seconds > 0 {
+				whole, fraction := math.Modf(seconds)
+				msg.timestamp = time.Unix(int64(whole), int64(math.Round(fraction*1e3))*int64(time.Millisecond))
+			}
+		case "level":
+			if level, ok := value.(float64); ok && level == math.Trunc(level) {
+				value = int(level)
+			}
+			msg.fields[key] = value
+		default:
+			if name, ok := strings.CutPrefix(key, "_"); ok {
+				// Standard fields win over additional fields of the same name
+				if _, exists := raw[name]; exists || name == "" {
+					continue
+				}
+				key = name
+			}
+			msg.fields[key] = value
+		}
+	}
+	return msg, nil
+}
+
+// assembler reassembles chunked messages. The chunks of a message have to
+// arrive within the timeout, otherwise the message is dropped. At most
+// maxPending messages are waited for, the oldest one is dropped for a
+// further one.
+type assembler struct {
+	timeout    time.Duration
+	maxSize    int
+	maxPending int
+	mu         sync.Mutex
+	pending    map[[8]byte]*chunkedMessage
+	// order holds the ids of the pending messages, the oldest first
+	order *list.List
+}
+
+type chunkedMessage struct {
+	element  *list.Element
+	chunks   [][]byte
+	received int
+	size     int
+	deadline time.Time
+	// discarded messages are kept until the deadline, so their remaining
+	// chunks are ignored instead of starting a new message
+	discarded bool
+}
+
+func newAssembler(timeout time.Duration, maxSize, maxPending int) *assembler {
+	return &assembler{
+		timeout:    timeout,
+		maxSize:    maxSize,
+		maxPending: maxPending,
+		pending:    map[[8]byte]*chunkedMessage{},
+		order:      list.New(),
+	}
+}
+
+// add adds a chunk and returns the payload of its message once all chunks
+// arrived, nil until then. evicted reports whether an incomplete message
+// was dropped to make room for the message of the chunk.
+func (a *assembler) add(chunk []byte, now time.Time) (payload []byte, evicted bool, err error) {
+	if len(chunk) < chunkHeaderLen {
+		return nil, false, errInvalidChunk
+	}
+	var id [8]byte
+	copy(id[:], chunk[2:10])
+	seq, count := int(chunk[10]), int(chunk[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		return nil, false, errInvalidChunk
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	msg, exists := a.pending[id]
+	if !exists {
+		if len(a.pending) >= a.maxPending {
+			oldestID := a.order.Front().Value.([8]byte)
+			evicted = !a.pending[oldestID].discarded
+			a.remove(oldestID)
+		}
+		msg = &chunkedMessage{chunks: make([][]byte, count), deadline: now.Add(a.timeout)}
+		msg.element = a.order.PushBack(id)
+		a.pending[id] = msg
+	}
+	if msg.discarded {
+		return nil, evicted, nil
+	}
+	if len(msg.chunks) != count {
+		msg.discarded = true
+		return nil, evicted, errInvalidChunk
+	}
+	if msg.chunks[seq] != nil {
+		// A duplicate
+		return nil, evicted, nil
+	}
+
+	data := bytes.Clone(chunk[chunkHeaderLen:])
+	msg.size += len(data)
+	if msg.size > a.maxSize {
+		msg.discarded = true
+		msg.chunks = nil
+		return nil, evicted, errMessageTooLarge
+	}
+	msg.chunks[seq] = data
+	msg.received++
+	if msg.received < count {
+		return nil, evicted, nil
+	}
+
+	a.remove(id)
+	return bytes.Join(msg.chunks, nil), evicted, nil
+}
+
+// remove has to be called with a.mu held
+func (a *assembler) remove(id [8]byte) {
+	a.order.Remove(a.pending[id].element)
+	delete(a.pending, id)
+}
+
+// expire removes the messages whose deadline passed and returns the number
+// of incomplete ones among them
+func (a *assembler) expire(now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	incomplete := 0
+	for id, msg := range a.pending {
+		if now.Before(msg.deadline) {
+			continue
+		}
+		if !msg.discarded {
+			incomplete++
+		}
+		a.remove(id)
+	}
+	return incomplete
+}
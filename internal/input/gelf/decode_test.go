@@ -0,0 +1,145 @@
+package inputgelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	plain := []byte(`{"short_message":"hi"}`)
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(plain)
+	gz.Close()
+
+	var zlibbed bytes.Buffer
+	zw := zlib.NewWriter(&zlibbed)
+	zw.Write(plain)
+	zw.Close()
+
+	for name, payload := range map[string][]byte{"plain": plain, "gzip": gzipped.Bytes(), "zlib": zlibbed.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			data, err := decompress(payload, 1024)
+			require.NoError(t, err)
+			assert.Equal(t, plain, data)
+
+			_, err = decompress(payload, 10)
+			assert.ErrorIs(t, err, errMessageTooLarge)
+		})
+	}
+
+	_, err := decompress([]byte{0x1f, 0x8b, 0x00}, 1024)
+	assert.ErrorContains(t, err, "cant decompress gelf message")
+}
+
+func TestDecodeMessage(t *testing.T) {
+	msg, err := decodeMessage([]byte(`{
+		"version": "1.1",
+		"host": "web-1",
+		"short_message": "request failed",
+		"full_message": "request failed\nstack trace",
+		"timestamp": 1700000000.5,
+		"level": 3,
+		"_user_id": 42,
+		"_level": "shadowed",
+		"_": "empty"
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "web-1", msg.host)
+	assert.Equal(t, time.Unix(1700000000, 500*int64(time.Millisecond)), msg.timestamp)
+	assert.Equal(t, map[string]any{
+		"short_message": "request failed",
+		"full_message":  "request failed\nstack trace",
+		"level":         3,
+		"user_id":       float64(42),
+	}, msg.fields)
+
+	_, err = decodeMessage([]byte(`{"host":"web-1"}`))
+	assert.EqualError(t, err, "invalid gelf message: missing short_message")
+	_, err = decodeMessage([]byte(`not json`))
+	assert.ErrorContains(t, err, "invalid gelf message")
+}
+
+// chunk returns a chunk of the message with the id
+func chunk(id byte, seq, count int, data string) []byte {
+	header := []byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count)}
+	return append(header, data...)
+}
+
+func TestAssembler(t *testing.T) {
+	now := time.Now()
+	a := newAssembler(time.Second, 10, 10)
+
+	// Out of order and with a duplicate
+	payload, _, err := a.add(chunk(1, 1, 3, "b"), now)
+	require.NoError(t, err)
+	assert.Nil(t, payload)
+	payload, _, _ = a.add(chunk(1, 1, 3, "b"), now)
+	assert.Nil(t, payload)
+	payload, _, _ = a.add(chunk(1, 0, 3, "a"), now)
+	assert.Nil(t, payload)
+	payload, _, err = a.add(chunk(1, 2, 3, "c"), now)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), payload)
+	assert.Empty(t, a.pending)
+
+	_, _, err = a.add(chunk(2, 3, 3, "a"), now)
+	assert.ErrorIs(t, err, errInvalidChunk)
+	_, _, err = a.add(chunk(2, 0, 129, "a"), now)
+	assert.ErrorIs(t, err, errInvalidChunk)
+	_, _, err = a.add([]byte{0x1e, 0x0f, 1}, now)
+	assert.ErrorIs(t, err, errInvalidChunk)
+
+	// A message larger than the limit is discarded with its later chunks
+	_, _, err = a.add(chunk(3, 0, 3, "123456"), now)
+	require.NoError(t, err)
+	_, _, err = a.add(chunk(3, 1, 3, "123456"), now)
+	assert.ErrorIs(t, err, errMessageTooLarge)
+	payload, _, err = a.add(chunk(3, 2, 3, "1"), now)
+	assert.NoError(t, err)
+	assert.Nil(t, payload)
+
+	// Only the incomplete message counts as expired
+	_, _, err = a.add(chunk(4, 0, 2, "a"), now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, a.expire(now.Add(time.Second/2)))
+	assert.Equal(t, 1, a.expire(now.Add(time.Second)))
+	assert.Empty(t, a.pending)
+}
+
+func TestAssembler_DropsOldestPendingMessage(t *testing.T) {
+	a := newAssembler(time.Second, 10, 2)
+	now := time.Now()
+
+	_, evicted, err := a.add(chunk(1, 0, 2, "a"), now)
+	require.NoError(t, err)
+	assert.False(t, evicted)
+	_, evicted, _ = a.add(chunk(2, 0, 2, "b"), now)
+	assert.False(t, evicted)
+
+	// A third message drops the first one
+	_, evicted, _ = a.add(chunk(3, 0, 2, "c"), now)
+	assert.True(t, evicted)
+	assert.Len(t, a.pending, 2)
+	assert.Equal(t, 2, a.order.Len())
+
+	// The late chunk of the first message starts it again, dropping the second
+	payload, evicted, _ := a.add(chunk(1, 1, 2, "x"), now)
+	assert.True(t, evicted)
+	assert.Nil(t, payload)
+
+	payload, evicted, err = a.add(chunk(3, 1, 2, "d"), now)
+	require.NoError(t, err)
+	assert.False(t, evicted)
+	assert.Equal(t, []byte("cd"), payload)
+	assert.Len(t, a.pending, 1)
+	assert.Equal(t, 1, a.order.Len())
+}
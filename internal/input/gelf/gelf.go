@@ -0,0 +1,327 @@
+package inputgelf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ModeUDP = "udp"
+	ModeTCP = "tcp"
+
+	// maxDatagramSize is the largest payload of a udp datagram
+	maxDatagramSize = 65535
+)
+
+// Config is the configuration of the gelf input
+type Config struct {
+	Name               string          `config:"Name" default:"gelf" desc:"The name of the input instance."`
+	Tag                string          `config:"Tag" default:"gelf" desc:"A tag associated with the log events."`
+	Mode               string          `config:"Mode" default:"udp" enum:"udp,tcp" desc:"The transport the gelf messages are received with."`
+	ListenAddr         string          `config:"ListenAddr" default:"0.0.0.0" desc:"The address on which the gelf input should listen on."`
+	Port               int             `config:"Port" default:"12201" min:"1" max:"65535" desc:"The port on which the gelf input should listen on."`
+	MaxMessageSize     schema.ByteSize `config:"MaxMessageSize" default:"1MiB" min:"1" desc:"The maximum size of a message after reassembling and decompressing it. Larger messages are dropped."`
+	ChunkTimeout       time.Duration   `config:"ChunkTimeout" default:"5s" desc:"How long the chunks of a chunked message are waited for in the udp mode. Incomplete messages are dropped."`
+	MaxChunkedMessages int             `config:"MaxChunkedMessages" default:"1000" min:"1" desc:"The maximum number of chunked messages waited for at once in the udp mode. The oldest one is dropped when a further one arrives."`
+	Timeout            time.Duration   `config:"Timeout" default:"10m" desc:"Tcp connections without data for this long are closed."`
+	MaxConnections     int             `config:"MaxConnections" default:"50" min:"1" desc:"The maximum number of concurrent tcp connections, further connections are rejected."`
+}
+
+type GELF struct {
+	name           string
+	tag            string
+	mode           string
+	addr           string
+	maxMessageSize int
+	chunkTimeout   time.Duration
+	maxChunked     int
+	timeout        time.Duration
+	maxConnections int
+	assembler      *assembler
+	listener       net.Listener
+	packetConn     net.PacketConn
+	conns          map[net.Conn]struct{}
+	connsMu        sync.Mutex
+	wg             sync.WaitGroup
+	ctx            context.Context
+	cancel         context.CancelFunc
+	output         chan<- internal.Event
+}
+
+func (g *GELF) Name() string {
+	return g.name
+}
+
+func (g *GELF) Tag() string {
+	return g.tag
+}
+
+func (g *GELF) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+
+	g.name = cfg.Name
+	g.tag = cfg.Tag
+	g.mode = cfg.Mode
+	g.addr = net.JoinHostPort(cfg.ListenAddr, fmt.Sprint(cfg.Port))
+	g.maxMessageSize = int(cfg.MaxMessageSize)
+	g.chunkTimeout = cfg.ChunkTimeout
+	g.maxChunked = cfg.MaxChunkedMessages
+	g.timeout = cfg.Timeout
+	g.maxConnections = cfg.MaxConnections
+
+	return nil
+}
+
+func (g *GELF) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	g.ctx, g.cancel = context.WithCancel(parentCtx)
+	g.output = output
+	g.conns = map[net.Conn]struct{}{}
+
+	var err error
+	switch g.mode {
+	case ModeTCP:
+		if g.listener, err = net.Listen("tcp", g.addr); err != nil {
+			return fmt.Errorf("couldn't start gelf input: %w", err)
+		}
+		g.wg.Add(1)
+		go g.accept(parentCtx)
+	case ModeUDP:
+		if g.packetConn, err = net.ListenPacket("udp", g.addr); err != nil {
+			return fmt.Errorf("couldn't start gelf input: %w", err)
+		}
+		g.assembler = newAssembler(g.chunkTimeout, g.maxMessageSize, g.maxChunked)
+		g.wg.Add(2)
+		go g.readPackets(parentCtx)
+		go g.expireChunks()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"mode": g.mode,
+		"addr": g.addr,
+	}).Info("Starting gelf input")
+	return nil
+}
+
+// readPackets handles the udp mode, where a datagram is a whole message or
+// a chunk of one
+func (g *GELF) readPackets(parentCtx context.Context) {
+	defer g.wg.Done()
+
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := g.packetConn.ReadFrom(buffer)
+		if err != nil {
+			if g.ctx.Err() != nil {
+				return
+			}
+			input.ReportError(parentCtx, fmt.Errorf("gelf socket closed: %w", err))
+			return
+		}
+
+		payload := buffer[:n]
+		if isChunk(payload) {
+			var evicted bool
+			payload, evicted, err = g.assembler.add(payload, time.Now())
+			if evicted {
+				droppedMessages.WithLabelValues(g.name, "too_many_chunked").Inc()
+			}
+			if err != nil {
+				g.drop(err, addr.String())
+				continue
+			}
+			if payload == nil {
+				// Waiting for further chunks
+				continue
+			}
+		}
+		g.handle(payload, addr.String())
+	}
+}
+
+// expireChunks drops the chunked messages that weren't completed in time
+func (g *GELF) expireChunks() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(max(g.chunkTimeout/5, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if expired := g.assembler.expire(now); expired > 0 {
+				droppedMessages.WithLabelValues(g.name, "chunk_timeout").Add(expired)
+				logrus.WithField("name", g.name).Debugf("Dropped %d incomplete chunked gelf messages", expired)
+			}
+		}
+	}
+}
+
+func (g *GELF) accept(parentCtx context.Context) {
+	defer g.wg.Done()
+	for {
+		conn, err := g.listener.Accept()
+		if err != nil {
+			if g.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				input.ReportError(parentCtx, fmt.Errorf("gelf listener closed: %w", err))
+				return
+			}
+			logrus.WithError(err).Error("could not accept gelf connection")
+			if !input.WaitAcceptRetry(g.ctx) {
+				return
+			}
+			continue
+		}
+
+		g.connsMu.Lock()
+		if len(g.conns) >= g.maxConnections {
+			g.connsMu.Unlock()
+			rejectedConnections.WithLabelValues(g.name).Inc()
+			logrus.WithFields(logrus.Fields{
+				"remote_addr":     conn.RemoteAddr().String(),
+				"max_connections": g.maxConnections,
+			}).Warn("Maximum gelf connection limit reached, rejecting connection")
+			conn.Close()
+			continue
+		}
+		g.conns[conn] = struct{}{}
+		g.connsMu.Unlock()
+
+		g.wg.Add(1)
+		go g.handleConnection(conn)
+	}
+}
+
+// handleConnection reads the messages of a tcp connection, which end with
+// a null byte
+func (g *GELF) handleConnection(conn net.Conn) {
+	defer g.wg.Done()
+	defer func() {
+		g.connsMu.Lock()
+		delete(g.conns, conn)
+		g.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	remoteAddr := conn.RemoteAddr().String()
+	logrus.WithField("remote_addr", remoteAddr).Debug("New gelf connection established")
+
+	reader := bufio.NewReader(input.NewIdleTimeoutReader(conn, g.timeout))
+	for {
+		frame, err := readFrame(reader, g.maxMessageSize)
+		if errors.Is(err, input.ErrRecordTooLarge) {
+			g.drop(errMessageTooLarge, remoteAddr)
+			continue
+		}
+		if err != nil {
+			if err != io.EOF && g.ctx.Err() == nil {
+				logrus.WithField("remote_addr", remoteAddr).WithError(err).Debug("Closing gelf connection")
+			}
+			return
+		}
+		frame = bytes.TrimSpace(frame)
+		if len(frame) == 0 {
+			continue
+		}
+		g.handle(frame, remoteAddr)
+	}
+}
+
+// readFrame reads the next null byte terminated message of a tcp stream
+var readFrame = input.Delimited(0)
+
+// handle decodes a message and hands it to the engine
+func (g *GELF) handle(payload []byte, source string) {
+	data, err := decompress(payload, g.maxMessageSize)
+	if err != nil {
+		g.drop(err, source)
+		return
+	}
+	msg, err := decodeMessage(data)
+	if err != nil {
+		g.drop(err, source)
+		return
+	}
+
+	event := internal.Event{
+		Timestamp:  time.Now(),
+		RawData:    string(data),
+		ParsedData: msg.fields,
+		Metadata: internal.Metadata{
+			Source: source,
+		},
+	}
+	input.AddMetadata(&event, g)
+	event.Metadata.Host = input.SenderHost(source)
+	if msg.host != "" {
+		event.Metadata.Host = msg.host
+	}
+	if !msg.timestamp.IsZero() {
+		event.Timestamp = msg.timestamp
+	}
+
+	select {
+	case g.output <- event:
+	case <-g.ctx.Done():
+	default:
+		droppedMessages.WithLabelValues(g.name, "pipeline_full").Inc()
+		logrus.WithField("source", source).Warn("gelf event channel full, dropping message")
+	}
+}
+
+// drop counts a message that couldn't be handled
+func (g *GELF) drop(err error, source string) {
+	reason := "invalid"
+	if errors.Is(err, errMessageTooLarge) {
+		reason = "too_large"
+	}
+	droppedMessages.WithLabelValues(g.name, reason).Inc()
+	logrus.WithField("source", source).WithError(err).Debug("Coundnt handle gelf message")
+}
+
+func (g *GELF) Exit() error {
+	logrus.WithField("name", g.name).Info("Stopping gelf input")
+	if g.cancel != nil {
+		g.cancel()
+	}
+
+	if g.listener != nil {
+		if err := g.listener.Close(); err != nil {
+			logrus.WithError(err).Error("could not close gelf listener")
+		}
+	}
+	if g.packetConn != nil {
+		if err := g.packetConn.Close(); err != nil {
+			logrus.WithError(err).Error("could not close gelf socket")
+		}
+	}
+
+	g.connsMu.Lock()
+	for conn := range g.conns {
+		conn.Close()
+	}
+	g.connsMu.Unlock()
+
+	g.wg.Wait()
+	g.listener = nil
+	g.packetConn = nil
+	return nil
+}
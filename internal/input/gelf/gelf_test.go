@@ -0,0 +1,133 @@
+package inputgelf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	outputgelf "github.com/MuchTitan/go-log-forwarder/internal/output/gelf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGELF_Init(t *testing.T) {
+	g := &GELF{}
+	require.NoError(t, g.Init(map[string]any{}))
+	assert.Equal(t, "gelf", g.name)
+	assert.Equal(t, ModeUDP, g.mode)
+	assert.Equal(t, "0.0.0.0:12201", g.addr)
+	assert.Equal(t, 1<<20, g.maxMessageSize)
+	assert.Equal(t, 5*time.Second, g.chunkTimeout)
+
+	assert.Error(t, g.Init(map[string]any{"Mode": "http"}))
+}
+
+// TestGELF_RoundTrip sends events with the gelf output to the input
+func TestGELF_RoundTrip(t *testing.T) {
+	// Random data doesn't compress, so the message is sent in chunks
+	random := make([]byte, 4000)
+	rand.Read(random)
+	long := hex.EncodeToString(random)
+
+	for _, mode := range []string{ModeUDP, ModeTCP} {
+		t.Run(mode, func(t *testing.T) {
+			port := inputtest.FreePort(t, "tcp")
+			output := inputtest.Start(t, &GELF{}, map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "Mode": mode, "Tag": "apps"})
+
+			out := &outputgelf.GELF{}
+			require.NoError(t, out.Init(map[string]any{"Mode": mode, "Host": "127.0.0.1", "Port": port, "HostKey": "app-host"}))
+			defer out.Exit()
+
+			sent := time.Unix(1700000000, 0)
+			require.NoError(t, out.Write([]internal.Event{
+				{RawData: "short line", Timestamp: sent, Metadata: internal.Metadata{Tag: "app"}},
+				{ParsedData: map[string]any{"msg": long}, Timestamp: sent, Metadata: internal.Metadata{Tag: "app"}},
+			}))
+
+			event := inputtest.Receive(t, output)
+			assert.Equal(t, "short line", event.ParsedData["short_message"])
+			assert.Equal(t, 6, event.ParsedData["level"])
+			assert.Equal(t, "app-host", event.Metadata.Host)
+			assert.Equal(t, "apps", event.Metadata.Tag)
+			assert.Equal(t, sent, event.Timestamp)
+
+			event = inputtest.Receive(t, output)
+			var short map[string]any
+			require.NoError(t, json.Unmarshal([]byte(event.ParsedData["short_message"].(string)), &short))
+			assert.Equal(t, long, short["msg"])
+		})
+	}
+}
+
+func TestGELF_ChunkTimeout(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	output := inputtest.Start(t, &GELF{}, map[string]any{"Name": "gelf-chunk-timeout", "ListenAddr": "127.0.0.1", "Port": port, "ChunkTimeout": "50ms"})
+	counter := droppedMessages.WithLabelValues("gelf-chunk-timeout", "chunk_timeout")
+	before := counter.Value()
+
+	conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", fmt.Sprint(port)))
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.Write(chunk(7, 0, 2, `{"short_message":`))
+
+	assert.Eventually(t, func() bool { return counter.Value() == before+1 }, 2*time.Second, 10*time.Millisecond)
+
+	// The second chunk starts a new message after the timeout
+	conn.Write(chunk(7, 1, 2, `"late"}`))
+	conn.Write([]byte(`{"short_message":"next"}`))
+	assert.Equal(t, "next", inputtest.Receive(t, output).ParsedData["short_message"])
+}
+
+func TestGELF_TCPFraming(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	output := inputtest.Start(t, &GELF{}, map[string]any{"Name": "gelf-tcp-framing", "ListenAddr": "127.0.0.1", "Port": port, "Mode": ModeTCP, "MaxMessageSize": 64})
+	tooLargeCounter := droppedMessages.WithLabelValues("gelf-tcp-framing", "too_large")
+	invalidCounter := droppedMessages.WithLabelValues("gelf-tcp-framing", "invalid")
+	tooLargeBefore, invalidBefore := tooLargeCounter.Value(), invalidCounter.Value()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(port)))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	tooLarge := `{"short_message":"` + strings.Repeat("x", 100) + `"}`
+	conn.Write([]byte(`{"short_message":"one","host":"h"}` + "\x00" + tooLarge + "\x00\x00" + `not json` + "\x00" + `{"short_message":"two"}`))
+	conn.(*net.TCPConn).CloseWrite()
+
+	event := inputtest.Receive(t, output)
+	assert.Equal(t, "one", event.ParsedData["short_message"])
+	assert.Equal(t, "h", event.Metadata.Host)
+
+	event = inputtest.Receive(t, output)
+	assert.Equal(t, "two", event.ParsedData["short_message"])
+	assert.Equal(t, "127.0.0.1", event.Metadata.Host)
+	assert.Equal(t, tooLargeBefore+1, tooLargeCounter.Value())
+	assert.Equal(t, invalidBefore+1, invalidCounter.Value())
+}
+
+func TestGELF_MaxConnections(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	output := inputtest.Start(t, &GELF{}, map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "Mode": ModeTCP, "MaxConnections": 1})
+
+	first, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = first.Write([]byte(`{"version":"1.1","host":"app","short_message":"first"}` + "\x00"))
+	require.NoError(t, err)
+	inputtest.Receive(t, output)
+
+	// The second connection is closed right away
+	second, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
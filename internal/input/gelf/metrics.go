@@ -0,0 +1,10 @@
+package inputgelf
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	droppedMessages = metrics.NewCounterVec("forwarder_gelf_dropped_messages_total",
+		"Messages a gelf input dropped, by reason.", "input", "reason")
+	rejectedConnections = metrics.NewCounterVec("forwarder_gelf_rejected_connections_total",
+		"Connections a gelf input rejected because of the connection limit.", "input")
+)
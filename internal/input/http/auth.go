@@ -0,0 +1,228 @@
+package inputhttp
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthConfig configures how requests authenticate. Without any method every
+// request is accepted, otherwise a request has to pass one of them.
+type AuthConfig struct {
+	Tokens     []TokenConfig `config:"Tokens" desc:"Static bearer tokens, sent as Authorization: Bearer <token>."`
+	TokensFile string        `config:"TokensFile" desc:"A file with one bearer token per line, optionally followed by a space and the tag of the token. Lines starting with # are ignored."`
+	Users      []UserConfig  `config:"Users" desc:"Users for HTTP Basic authentication."`
+	UsersFile  string        `config:"UsersFile" desc:"A htpasswd file with further users for HTTP Basic authentication, one user:bcrypt-hash per line."`
+	HMAC       HMACConfig    `config:"HMAC" desc:"Requests signed with a shared secret."`
+}
+
+type TokenConfig struct {
+	Token string `config:"Token,required" desc:"The bearer token."`
+	Tag   string `config:"Tag" desc:"The tag of events sent with this token instead of the tag of the input."`
+}
+
+type UserConfig struct {
+	Username     string `config:"Username,required" desc:"The name of the user."`
+	PasswordHash string `config:"PasswordHash,required" desc:"The bcrypt hash of the password, e.g. created with htpasswd -nbB."`
+	Tag          string `config:"Tag" desc:"The tag of events sent by this user instead of the tag of the input."`
+}
+
+type HMACConfig struct {
+	Secret     string `config:"Secret" desc:"The shared secret. Enables HMAC signatures when set."`
+	SecretFile string `config:"SecretFile" desc:"A file holding the shared secret, instead of Secret."`
+	Header     string `config:"Header" default:"X-Signature" desc:"The header with the hex encoded HMAC-SHA256 of the body, optionally prefixed with sha256=."`
+	Tag        string `config:"Tag" desc:"The tag of events of signed requests instead of the tag of the input."`
+}
+
+type user struct {
+	hash []byte
+	tag  string
+}
+
+// authenticator checks the credentials of requests
+type authenticator struct {
+	tokens     map[string]string // Token to tag
+	users      map[string]user
+	hmacSecret []byte
+	hmacHeader string
+	hmacTag    string
+	// verified caches the password checks that passed, as bcrypt is slow on purpose
+	verified sync.Map
+}
+
+func newAuthenticator(cfg AuthConfig) (*authenticator, error) {
+	a := &authenticator{
+		tokens:     map[string]string{},
+		users:      map[string]user{},
+		hmacHeader: cfg.HMAC.Header,
+		hmacTag:    cfg.HMAC.Tag,
+	}
+
+	for _, token := range cfg.Tokens {
+		a.tokens[token.Token] = token.Tag
+	}
+	if cfg.TokensFile != "" {
+		if err := a.readTokensFile(cfg.TokensFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, u := range cfg.Users {
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("Auth.Users[%d].PasswordHash: not a bcrypt hash", i)
+		}
+		a.users[u.Username] = user{hash: []byte(u.PasswordHash), tag: u.Tag}
+	}
+	if cfg.UsersFile != "" {
+		if err := a.readUsersFile(cfg.UsersFile); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case cfg.HMAC.Secret != "" && cfg.HMAC.SecretFile != "":
+		return nil, errors.New("Auth.HMAC: only one of Secret and SecretFile can be set")
+	case cfg.HMAC.Secret != "":
+		a.hmacSecret = []byte(cfg.HMAC.Secret)
+	case cfg.HMAC.SecretFile != "":
+		secret, err := os.ReadFile(cfg.HMAC.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("cant read HMAC secret file: %w", err)
+		}
+		if a.hmacSecret = []byte(strings.TrimSpace(string(secret))); len(a.hmacSecret) == 0 {
+			return nil, fmt.Errorf("HMAC secret file %s is empty", cfg.HMAC.SecretFile)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *authenticator) readTokensFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cant read tokens file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		token, tag, _ := strings.Cut(line, " ")
+		a.tokens[token] = strings.TrimSpace(tag)
+	}
+	return scanner.Err()
+}
+
+// readUsersFile reads the users of a htpasswd file with bcrypt hashes
+func (a *authenticator) readUsersFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cant read users file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, _ := strings.Cut(line, ":")
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("%s: line %d: not a bcrypt hash", path, lineNum)
+		}
+		if _, exists := a.users[username]; !exists {
+			a.users[username] = user{hash: []byte(hash)}
+		}
+	}
+	return scanner.Err()
+}
+
+// enabled reports whether requests have to authenticate
+func (a *authenticator) enabled() bool {
+	return len(a.tokens) > 0 || len(a.users) > 0 || a.hmacSecret != nil
+}
+
+// authenticate checks the credentials of a request with the given body. It
+// returns the tag of the credentials, which is empty if the tag of the
+// input is kept.
+func (a *authenticator) authenticate(r *http.Request, body []byte) (string, bool) {
+	if token, ok := authToken(r); ok && len(a.tokens) > 0 {
+		// Compare with every token, so the time taken doesn't tell which one matched
+		tag, found := "", false
+		for candidate, candidateTag := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+				tag, found = candidateTag, true
+			}
+		}
+		if found {
+			return tag, true
+		}
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		if u, exists := a.users[username]; exists && a.checkPassword(username, password, u) {
+			return u.tag, true
+		}
+	}
+
+	if signature := r.Header.Get(a.hmacHeader); signature != "" && a.hmacSecret != nil {
+		given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err == nil {
+			mac := hmac.New(sha256.New, a.hmacSecret)
+			mac.Write(body)
+			if hmac.Equal(given, mac.Sum(nil)) {
+				return a.hmacTag, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// authToken returns the token of an Authorization header of the Bearer
+// scheme, or of the Splunk scheme HEC clients use
+func authToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return token, true
+	}
+	return strings.CutPrefix(header, "Splunk ")
+}
+
+func (a *authenticator) checkPassword(username, password string, u user) bool {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	key := string(sum[:])
+	if cached, ok := a.verified.Load(key); ok && cached.(string) == string(u.hash) {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword(u.hash, []byte(password)) != nil {
+		return false
+	}
+	a.verified.Store(key, string(u.hash))
+	return true
+}
+
+// challenge sets the WWW-Authenticate header of a 401 response
+func (a *authenticator) challenge(w http.ResponseWriter) {
+	if len(a.users) > 0 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="log-forwarder"`)
+	} else if len(a.tokens) > 0 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+}
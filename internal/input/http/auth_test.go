@@ -0,0 +1,118 @@
+package inputhttp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestInHTTP_Auth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokensFile, []byte("# team tokens\nfile-token billing\nplain-token\n"), 0600))
+
+	usersFile := filepath.Join(t.TempDir(), "htpasswd")
+	require.NoError(t, os.WriteFile(usersFile, []byte("carl:"+string(hash)+"\n"), 0600))
+
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{
+		"Tag": "http",
+		"Auth": map[string]any{
+			"Tokens":     []any{map[string]any{"Token": "abc", "Tag": "team-a"}},
+			"TokensFile": tokensFile,
+			"Users":      []any{map[string]any{"Username": "anna", "PasswordHash": string(hash), "Tag": "team-b"}},
+			"UsersFile":  usersFile,
+			"HMAC":       map[string]any{"Secret": "shared", "Tag": "signed"},
+		},
+	}))
+
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte("shared"))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		basicAuth  []string
+		wantStatus int
+		wantTag    string
+	}{
+		{"bearer token", "Authorization", "Bearer abc", nil, http.StatusOK, "team-a"},
+		{"token from file", "Authorization", "Bearer file-token", nil, http.StatusOK, "billing"},
+		{"token from file without tag", "Authorization", "Bearer plain-token", nil, http.StatusOK, "http"},
+		{"wrong token", "Authorization", "Bearer abd", nil, http.StatusUnauthorized, ""},
+		{"basic auth", "", "", []string{"anna", "s3cret"}, http.StatusOK, "team-b"},
+		{"basic auth cached", "", "", []string{"anna", "s3cret"}, http.StatusOK, "team-b"},
+		{"user from file", "", "", []string{"carl", "s3cret"}, http.StatusOK, "http"},
+		{"wrong password", "", "", []string{"anna", "secret"}, http.StatusUnauthorized, ""},
+		{"unknown user", "", "", []string{"ben", "s3cret"}, http.StatusUnauthorized, ""},
+		{"hmac signature", "X-Signature", sign("line"), nil, http.StatusOK, "signed"},
+		{"hmac signature of other body", "X-Signature", sign("other"), nil, http.StatusUnauthorized, ""},
+		{"no credentials", "", "", nil, http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := make(chan internal.Event, 1)
+			h.outputCh = output
+			h.wg = &sync.WaitGroup{}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("line"))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if tt.basicAuth != nil {
+				req.SetBasicAuth(tt.basicAuth[0], tt.basicAuth[1])
+			}
+			rr := httptest.NewRecorder()
+			h.handleReq(rr, req)
+			h.wg.Wait()
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.Equal(t, `Basic realm="log-forwarder"`, rr.Header().Get("WWW-Authenticate"))
+				assert.Empty(t, output)
+				return
+			}
+			event := <-output
+			assert.Equal(t, tt.wantTag, event.Metadata.Tag)
+		})
+	}
+}
+
+func TestInHTTP_AuthInitErrors(t *testing.T) {
+	tests := []struct {
+		auth    map[string]any
+		wantErr string
+	}{
+		{map[string]any{"Users": []any{map[string]any{"Username": "anna", "PasswordHash": "s3cret"}}}, "Auth.Users[0].PasswordHash: not a bcrypt hash"},
+		{map[string]any{"Tokens": []any{map[string]any{"Tag": "a"}}}, "Auth.Tokens[0].Token: missing required field"},
+		{map[string]any{"TokensFile": "/does/not/exist"}, "cant read tokens file"},
+		{map[string]any{"UsersFile": "/does/not/exist"}, "cant read users file"},
+		{map[string]any{"HMAC": map[string]any{"Secret": "a", "SecretFile": "b"}}, "Auth.HMAC: only one of Secret and SecretFile can be set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantErr, func(t *testing.T) {
+			err := (&InHTTP{}).Init(map[string]any{"Auth": tt.auth})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package inputhttp
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errBodyTooLarge        = errors.New("request body too large")
+)
+
+// wildcardRegex matches the wildcards of a path pattern like /logs/{tag}
+var wildcardRegex = regexp.MustCompile(`\{([^}]*)\}`)
+
+// checkPaths checks the paths requests are accepted on. A mux panics on
+// invalid or conflicting patterns, which is turned into an error here.
+func checkPaths(paths []string) (err error) {
+	mux := http.NewServeMux()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Paths: %v", r)
+		}
+	}()
+
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("Paths: '%s' has to start with /", path)
+		}
+		for _, match := range wildcardRegex.FindAllStringSubmatch(path, -1) {
+			if match[1] != "tag" {
+				return fmt.Errorf("Paths: unsupported wildcard '%s' in '%s', only {tag} is supported", match[0], path)
+			}
+		}
+		mux.HandleFunc(path, func(http.ResponseWriter, *http.Request) {})
+	}
+	return nil
+}
+
+// splitLines returns the non empty lines of a request body
+func splitLines(body []byte) []string {
+	var lines []string
+	for _, line := range bytes.Split(body, []byte{'\n'}) {
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// isJSON reports whether a request has a JSON body
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+type jsonObject struct {
+	raw  []byte
+	data map[string]any
+}
+
+// jsonObjects returns the objects of a JSON body, which holds an array of
+// objects, a single object or several of them after each other
+func jsonObjects(body []byte) ([]jsonObject, error) {
+	var objects []jsonObject
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %w", err)
+		}
+
+		elements := []json.RawMessage{value}
+		if bytes.HasPrefix(value, []byte("[")) {
+			elements = nil
+			if err := json.Unmarshal(value, &elements); err != nil {
+				return nil, fmt.Errorf("invalid JSON body: %w", err)
+			}
+		}
+
+		for _, element := range elements {
+			var data map[string]any
+			if !bytes.HasPrefix(element, []byte("{")) || json.Unmarshal(element, &data) != nil {
+				return nil, fmt.Errorf("invalid JSON body: element %d is no object", len(objects))
+			}
+			var compact bytes.Buffer
+			json.Compact(&compact, element)
+			objects = append(objects, jsonObject{raw: compact.Bytes(), data: data})
+		}
+	}
+}
+
+// readBody reads the body of a request, which may not be larger than limit.
+// The limit applies to chunked requests without a Content-Length as well.
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	if r.ContentLength > limit {
+		return nil, errBodyTooLarge
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return nil, errBodyTooLarge
+	}
+	return body, err
+}
+
+// decodeBody decompresses a request body sent with the given
+// Content-Encoding. The decompressed body may not be larger than limit.
+func decodeBody(encoding string, body []byte, limit int64) ([]byte, error) {
+	var reader io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("cant decompress gzip body: %w", err)
+		}
+		reader = gz
+	case "deflate":
+		// deflate is zlib wrapped, but some clients send the bare stream
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			zr = flate.NewReader(bytes.NewReader(body))
+		}
+		reader = zr
+	default:
+		return nil, fmt.Errorf("%w '%s'", errUnsupportedEncoding, encoding)
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("cant decompress body: %w", err)
+	}
+	if int64(len(decoded)) > limit {
+		return nil, errBodyTooLarge
+	}
+	return decoded, nil
+}
+
+// decodeStatus returns the status code of a request decodeBody failed for
+func decodeStatus(err error) int {
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,277 @@
+package inputhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+)
+
+// The status codes of the Splunk HTTP Event Collector the clients know
+const (
+	hecSuccess              = 0
+	hecTokenRequired        = 2
+	hecInvalidAuthorization = 3
+	hecInvalidToken         = 4
+	hecNoData               = 5
+	hecInvalidDataFormat    = 6
+	hecEventRequired        = 12
+	hecEventBlank           = 13
+	hecHealthy              = 17
+)
+
+// Metadata fields the sourcetype and index of an HEC event are recorded in
+const (
+	SourcetypeField = "sourcetype"
+	IndexField      = "index"
+)
+
+// hecResponse is the body of every response of the HEC endpoints
+type hecResponse struct {
+	Text               string `json:"text"`
+	Code               int    `json:"code"`
+	InvalidEventNumber *int   `json:"invalid-event-number,omitempty"`
+}
+
+// hecEvent is an event sent to the event endpoint, as the splunk output
+// writes it
+type hecEvent struct {
+	Event      any             `json:"event"`
+	Time       json.RawMessage `json:"time"`
+	Host       string          `json:"host"`
+	Source     string          `json:"source"`
+	Sourcetype string          `json:"sourcetype"`
+	Index      string          `json:"index"`
+	Fields     map[string]any  `json:"fields"`
+}
+
+// hecParser turns the decoded body of a request into events. A failure is
+// returned as the response to send instead.
+type hecParser func(r *http.Request, body []byte) ([]internal.Event, *hecResponse)
+
+func (h *InHTTP) registerHEC(mux *http.ServeMux) {
+	event := h.countRequests(h.handleHEC(h.parseHECEvents))
+	raw := h.countRequests(h.handleHEC(h.parseHECRaw))
+	health := h.countRequests(handleHECHealth)
+
+	for _, path := range []string{"/services/collector", "/services/collector/event", "/services/collector/event/1.0"} {
+		mux.HandleFunc(path, event)
+	}
+	for _, path := range []string{"/services/collector/raw", "/services/collector/raw/1.0"} {
+		mux.HandleFunc(path, raw)
+	}
+	for _, path := range []string{"/services/collector/health", "/services/collector/health/1.0"} {
+		mux.HandleFunc(path, health)
+	}
+}
+
+func writeHEC(w http.ResponseWriter, status int, response hecResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// invalidEvent is the response for the event with the given number, which
+// starts at 0 like the one of Splunk
+func invalidEvent(code int, text string, number int) *hecResponse {
+	return &hecResponse{Text: text, Code: code, InvalidEventNumber: &number}
+}
+
+func handleHECHealth(w http.ResponseWriter, r *http.Request) {
+	writeHEC(w, http.StatusOK, hecResponse{Text: "HEC is healthy", Code: hecHealthy})
+}
+
+// handleHEC authenticates and decodes a request to an HEC endpoint and
+// passes the events parse returns on
+func (h *InHTTP) handleHEC(parse hecParser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := readBody(w, r, h.bufferSize)
+		if errors.Is(err, errBodyTooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error reading request body", http.StatusInternalServerError)
+			return
+		}
+
+		// The tag of the token overrides the one of the input
+		var tag string
+		if h.auth != nil {
+			var ok bool
+			if tag, ok = h.auth.authenticate(r, body); !ok {
+				authFailures.WithLabelValues(h.name).Inc()
+				if r.Header.Get("Authorization") == "" {
+					w.Header().Set("WWW-Authenticate", "Splunk")
+					writeHEC(w, http.StatusUnauthorized, hecResponse{Text: "Token is required", Code: hecTokenRequired})
+				} else if _, ok := authToken(r); !ok {
+					w.Header().Set("WWW-Authenticate", "Splunk")
+					writeHEC(w, http.StatusUnauthorized, hecResponse{Text: "Invalid authorization", Code: hecInvalidAuthorization})
+				} else {
+					writeHEC(w, http.StatusForbidden, hecResponse{Text: "Invalid token", Code: hecInvalidToken})
+				}
+				return
+			}
+		}
+
+		body, err = decodeBody(r.Header.Get("Content-Encoding"), body, h.bufferSize)
+		if status := decodeStatus(err); err != nil && status != http.StatusBadRequest {
+			http.Error(w, err.Error(), status)
+			return
+		} else if err != nil {
+			writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecInvalidDataFormat})
+			return
+		}
+
+		events, failure := parse(r, body)
+		if failure != nil {
+			writeHEC(w, http.StatusBadRequest, *failure)
+			return
+		}
+		if len(events) == 0 {
+			writeHEC(w, http.StatusBadRequest, hecResponse{Text: "No data", Code: hecNoData})
+			return
+		}
+
+		if tag != "" {
+			for i := range events {
+				events[i].Metadata.Tag = tag
+			}
+		}
+		h.send(events)
+
+		writeHEC(w, http.StatusOK, hecResponse{Text: "Success", Code: hecSuccess})
+	}
+}
+
+// parseHECEvents parses the concatenated JSON events of the event endpoint.
+// A request with an invalid event is rejected as a whole.
+func (h *InHTTP) parseHECEvents(r *http.Request, body []byte) ([]internal.Event, *hecResponse) {
+	fields := h.requestFields(r)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+
+	var events []internal.Event
+	for number := 0; ; number++ {
+		var hec hecEvent
+		if err := decoder.Decode(&hec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, invalidEvent(hecInvalidDataFormat, "Invalid data format", number)
+		}
+
+		event := h.newEvent(r, fields, number+1, time.Time{})
+		switch data := hec.Event.(type) {
+		case nil:
+			return nil, invalidEvent(hecEventRequired, "Event field is required", number)
+		case string:
+			if strings.TrimSpace(data) == "" {
+				return nil, invalidEvent(hecEventBlank, "Event field cannot be blank", number)
+			}
+			event.RawData = data
+		case map[string]any:
+			if len(data) == 0 {
+				return nil, invalidEvent(hecEventBlank, "Event field cannot be blank", number)
+			}
+			raw, _ := json.Marshal(data)
+			event.RawData = string(raw)
+			event.ParsedData = data
+		default:
+			raw, _ := json.Marshal(data)
+			event.RawData = string(raw)
+		}
+
+		timestamp, ok := parseHECTime(hec.Time)
+		if !ok {
+			return nil, invalidEvent(hecInvalidDataFormat, "Invalid data format", number)
+		}
+		event.Timestamp = timestamp
+
+		for key, value := range hec.Fields {
+			setField(&event.Metadata, key, fieldString(value))
+		}
+		applyHECMetadata(&event.Metadata, hec.Host, hec.Source, hec.Sourcetype, hec.Index)
+
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// parseHECRaw turns every line of a request to the raw endpoint into an
+// event. The metadata is taken from the query parameters.
+func (h *InHTTP) parseHECRaw(r *http.Request, body []byte) ([]internal.Event, *hecResponse) {
+	fields := h.requestFields(r)
+	query := r.URL.Query()
+	currTime := time.Now()
+
+	var events []internal.Event
+	for i, line := range splitLines(body) {
+		event := h.newEvent(r, fields, i+1, currTime)
+		event.RawData = line
+		applyHECMetadata(&event.Metadata, query.Get("host"), query.Get("source"), query.Get("sourcetype"), query.Get("index"))
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// applyHECMetadata sets the metadata an HEC client sent, empty values keep
+// the one of the input
+func applyHECMetadata(metadata *internal.Metadata, host, source, sourcetype, index string) {
+	if host != "" {
+		metadata.Host = host
+	}
+	if source != "" {
+		metadata.Source = source
+	}
+	if sourcetype != "" {
+		setField(metadata, SourcetypeField, sourcetype)
+	}
+	if index != "" {
+		setField(metadata, IndexField, index)
+	}
+}
+
+func setField(metadata *internal.Metadata, key, value string) {
+	if metadata.Fields == nil {
+		metadata.Fields = map[string]string{}
+	}
+	metadata.Fields[key] = value
+}
+
+// fieldString returns the value of an indexed field as string, values that
+// are no strings are kept as JSON
+func fieldString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	raw, _ := json.Marshal(value)
+	return string(raw)
+}
+
+// parseHECTime parses the time of an HEC event, the seconds since the epoch
+// as number or string with an optional fraction. Without a time the current
+// one is used.
+func parseHECTime(raw json.RawMessage) (time.Time, bool) {
+	value := strings.Trim(string(raw), `"`)
+	if value == "" || value == "null" {
+		return time.Now(), true
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(math.Round(fraction*1e3))*int64(time.Millisecond)), true
+}
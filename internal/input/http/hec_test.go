@@ -0,0 +1,251 @@
+package inputhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/tlstest"
+	outputsplunk "github.com/MuchTitan/go-log-forwarder/internal/output/splunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hecRequest sends a request to the HEC endpoints of h and returns the
+// response and the events it produced
+func hecRequest(t *testing.T, h *InHTTP, req *http.Request) (*httptest.ResponseRecorder, []internal.Event) {
+	t.Helper()
+	output := make(chan internal.Event, 100)
+	h.outputCh = output
+	h.wg = &sync.WaitGroup{}
+
+	mux := http.NewServeMux()
+	h.registerHEC(mux)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	h.wg.Wait()
+	close(output)
+
+	var events []internal.Event
+	for event := range output {
+		events = append(events, event)
+	}
+	return rr, events
+}
+
+func TestInHTTP_HECEvents(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Tag": "hec", "Mode": "splunk-hec"}))
+
+	body := `{"event":"plain line","time":1700000000.25,"host":"web-1","source":"/var/log/app.log","sourcetype":"app","index":"main"}` +
+		`{"event":{"level":"info","msg":"started"},"time":"1700000001","fields":{"team":"billing","shard":3}}`
+	rr, events := hecRequest(t, h, httptest.NewRequest(http.MethodPost, "/services/collector/event", bytes.NewBufferString(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"text":"Success","code":0}`, rr.Body.String())
+	require.Len(t, events, 2)
+
+	assert.Equal(t, "plain line", events[0].RawData)
+	assert.Nil(t, events[0].ParsedData)
+	assert.Equal(t, time.Unix(1700000000, 250*int64(time.Millisecond)), events[0].Timestamp)
+	assert.Equal(t, "web-1", events[0].Metadata.Host)
+	assert.Equal(t, "/var/log/app.log", events[0].Metadata.Source)
+	assert.Equal(t, "hec", events[0].Metadata.Tag)
+	assert.Equal(t, map[string]string{"sourcetype": "app", "index": "main"}, events[0].Metadata.Fields)
+
+	assert.Equal(t, map[string]any{"level": "info", "msg": "started"}, events[1].ParsedData)
+	assert.JSONEq(t, `{"level":"info","msg":"started"}`, events[1].RawData)
+	assert.Equal(t, time.Unix(1700000001, 0), events[1].Timestamp)
+	assert.Equal(t, map[string]string{"team": "billing", "shard": "3"}, events[1].Metadata.Fields)
+	assert.Equal(t, 2, events[1].Metadata.LineNum)
+}
+
+func TestInHTTP_HECErrors(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Mode": "splunk-hec"}))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"no data", "", http.StatusBadRequest, `{"text":"No data","code":5}`},
+		{"invalid json", `{"event":"a"}{"event":`, http.StatusBadRequest, `{"text":"Invalid data format","code":6,"invalid-event-number":1}`},
+		{"array", `[{"event":"a"}]`, http.StatusBadRequest, `{"text":"Invalid data format","code":6,"invalid-event-number":0}`},
+		{"missing event", `{"event":"a"}{"host":"b"}`, http.StatusBadRequest, `{"text":"Event field is required","code":12,"invalid-event-number":1}`},
+		{"blank event", `{"event":" "}`, http.StatusBadRequest, `{"text":"Event field cannot be blank","code":13,"invalid-event-number":0}`},
+		{"invalid time", `{"event":"a","time":"yesterday"}`, http.StatusBadRequest, `{"text":"Invalid data format","code":6,"invalid-event-number":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, events := hecRequest(t, h, httptest.NewRequest(http.MethodPost, "/services/collector", bytes.NewBufferString(tt.body)))
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.JSONEq(t, tt.wantBody, rr.Body.String())
+			assert.Empty(t, events)
+		})
+	}
+}
+
+func TestInHTTP_HECChunkedBodyTooLarge(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Mode": "splunk-hec", "BufferSize": "1KiB"}))
+
+	// A chunked request has no Content-Length to check up front
+	req := httptest.NewRequest(http.MethodPost, "/services/collector/raw", bytes.NewReader(make([]byte, 2048)))
+	req.ContentLength = -1
+	rr, events := hecRequest(t, h, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Empty(t, events)
+}
+
+func TestInHTTP_HECAuth(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{
+		"Mode": "splunk-hec",
+		"Auth": map[string]any{"Tokens": []any{map[string]any{"Token": "abc", "Tag": "team-a"}}},
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"splunk token", "Splunk abc", http.StatusOK, `{"text":"Success","code":0}`},
+		{"no token", "", http.StatusUnauthorized, `{"text":"Token is required","code":2}`},
+		{"other scheme", "Basic YTpi", http.StatusUnauthorized, `{"text":"Invalid authorization","code":3}`},
+		{"wrong token", "Splunk abd", http.StatusForbidden, `{"text":"Invalid token","code":4}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/services/collector/event", bytes.NewBufferString(`{"event":"a"}`))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr, events := hecRequest(t, h, req)
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.JSONEq(t, tt.wantBody, rr.Body.String())
+			if tt.wantStatus == http.StatusOK {
+				require.Len(t, events, 1)
+				assert.Equal(t, "team-a", events[0].Metadata.Tag)
+			} else {
+				assert.Empty(t, events)
+			}
+		})
+	}
+}
+
+func TestInHTTP_HECRawAndGzip(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Mode": "splunk-hec"}))
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("first line\nsecond line\n"))
+	require.NoError(t, gz.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/services/collector/raw?host=db-1&sourcetype=postgres&channel=abc", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	rr, events := hecRequest(t, h, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, events, 2)
+	assert.Equal(t, "second line", events[1].RawData)
+	assert.Equal(t, "db-1", events[1].Metadata.Host)
+	assert.Equal(t, map[string]string{"sourcetype": "postgres"}, events[1].Metadata.Fields)
+
+	req = httptest.NewRequest(http.MethodPost, "/services/collector/raw", bytes.NewBufferString("line"))
+	req.Header.Set("Content-Encoding", "br")
+	rr, _ = hecRequest(t, h, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+
+	rr, _ = hecRequest(t, h, httptest.NewRequest(http.MethodGet, "/services/collector/health", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"text":"HEC is healthy","code":17}`, rr.Body.String())
+}
+
+func TestInHTTP_HECFromSplunkOutput(t *testing.T) {
+	files := tlstest.New(t)
+	port := inputtest.FreePort(t, "tcp")
+
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{
+		"ListenAddr": "localhost",
+		"Port":       port,
+		"Mode":       "splunk-hec",
+		"TLS":        map[string]any{"CertFile": files.CertFile, "KeyFile": files.KeyFile},
+		"Auth":       map[string]any{"Tokens": []any{map[string]any{"Token": "hec-token"}}},
+	}))
+	output := make(chan internal.Event, 10)
+	require.NoError(t, h.Start(context.Background(), output))
+	defer h.Exit()
+
+	splunk := &outputsplunk.Splunk{}
+	require.NoError(t, splunk.Init(map[string]any{
+		"Token":      "hec-token",
+		"EventIndex": "main",
+		"Host":       "localhost",
+		"Port":       port,
+		"Compress":   true,
+		"EventHost":  "forwarder-1",
+	}))
+
+	sent := time.Unix(1700000000, 0)
+	require.NoError(t, splunk.Write([]internal.Event{
+		{ParsedData: map[string]any{"msg": "a"}, Timestamp: sent, Metadata: internal.Metadata{Tag: "app"}},
+		{ParsedData: map[string]any{"msg": "b"}, Timestamp: sent, Metadata: internal.Metadata{Tag: "app"}},
+	}))
+
+	for _, msg := range []string{"a", "b"} {
+		event := <-output
+		assert.Equal(t, msg, event.ParsedData["msg"])
+		assert.Equal(t, sent, event.Timestamp)
+		assert.Equal(t, "forwarder-1", event.Metadata.Source)
+		assert.Equal(t, "main", event.Metadata.Fields[IndexField])
+		assert.Equal(t, "JSON", event.Metadata.Fields[SourcetypeField])
+
+		raw, err := json.Marshal(event.ParsedData)
+		require.NoError(t, err)
+		assert.JSONEq(t, event.RawData, string(raw))
+	}
+}
+
+func TestInHTTP_HECExitWithUnreadOutput(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "Mode": "splunk-hec"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Nothing reads the output, like after the engine stopped
+	require.NoError(t, h.Start(ctx, make(chan internal.Event)))
+
+	body := `{"event":"first"}{"event":"second"}`
+	resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/services/collector/event", port), "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	exited := make(chan struct{})
+	go func() {
+		h.Exit()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exit blocked on the unread output")
+	}
+}
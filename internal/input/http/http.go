@@ -1,37 +1,58 @@
 package inputhttp
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"io"
+	"maps"
+	"net"
 	"net/http"
-	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/input"
-	"github.com/MuchTitan/go-log-forwarder/internal/util"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/sirupsen/logrus"
 )
 
 const DefaultHttpBufferSize int64 = 5 << 20 // 5MB
 
+// Config is the configuration of the http input
+type Config struct {
+	Name        string          `config:"Name" default:"http" desc:"The name of the input instance."`
+	Tag         string          `config:"Tag" default:"http" desc:"A tag associated with the log events."`
+	ListenAddr  string          `config:"ListenAddr" default:"0.0.0.0" desc:"The address on which the http input should listen on."`
+	Port        int             `config:"Port" default:"8080" min:"1" max:"65535" desc:"The port on which the http input should listen on."`
+	BufferSize  schema.ByteSize `config:"BufferSize" default:"5MiB" min:"1" desc:"The maximum size of a request body."`
+	TLS         input.TLSConfig `config:"TLS" desc:"Serves https instead of http."`
+	Auth        AuthConfig      `config:"Auth" desc:"Requires requests to authenticate, see [Authentication](#authentication)."`
+	Mode        string          `config:"Mode" default:"lines" enum:"lines,splunk-hec,otlp" desc:"lines turns every line of a request into an event, splunk-hec serves the Splunk HTTP Event Collector API, see [Splunk HEC](#splunk-hec), otlp the logs endpoint of OTLP/HTTP, see [OpenTelemetry](#opentelemetry)."`
+	Paths       []string        `config:"Paths" default:"/" desc:"The paths requests are accepted on. A {tag} segment sets the tag of the events, e.g. /logs/{tag}, see [Routes](#routes). Not used by splunk-hec and otlp."`
+	QueryParams bool            `config:"QueryParams" desc:"Copies the query parameters of a request into the metadata fields of its events, named query_<name>."`
+	Headers     []string        `config:"Headers" desc:"Request headers copied into the metadata fields of the events, named header_<name> in lower case with - replaced by _."`
+}
+
 type InHTTP struct {
-	name       string
-	tag        string
-	addr       string
-	listenAddr string
-	inputTag   string
-	port       int
-	bufferSize int64
-	verifyTLS  bool
-	server     *http.Server
-	wg         *sync.WaitGroup
-	ctx        context.Context
-	outputCh   chan<- internal.Event
+	name        string
+	tag         string
+	addr        string
+	listenAddr  string
+	inputTag    string
+	port        int
+	bufferSize  int64
+	mode        string
+	paths       []string
+	queryParams bool
+	headers     []string
+	tlsConfig   *tls.Config
+	auth        *authenticator // nil if requests don't authenticate
+	server      *http.Server
+	wg          *sync.WaitGroup
+	ctx         context.Context
+	outputCh    chan<- internal.Event
 }
 
 func (h *InHTTP) Name() string {
@@ -43,47 +64,46 @@ func (h *InHTTP) Tag() string {
 }
 
 func (h *InHTTP) Init(config map[string]any) error {
-	h.name = util.MustString(config["Name"])
-	if h.name == "" {
-		h.name = "http"
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	h.tag = util.MustString(config["Tag"])
-	if h.tag == "" {
-		h.tag = "http"
+	h.name = cfg.Name
+	h.tag = cfg.Tag
+	h.listenAddr = cfg.ListenAddr
+	h.port = cfg.Port
+	h.bufferSize = int64(cfg.BufferSize)
+	h.mode = cfg.Mode
+	h.queryParams = cfg.QueryParams
+	h.headers = cfg.Headers
+	if err := checkPaths(cfg.Paths); err != nil {
+		return err
 	}
-
-	h.listenAddr = util.MustString(config["ListenAddr"])
-	if h.listenAddr == "" {
-		h.listenAddr = "0.0.0.0"
+	h.paths = cfg.Paths
+	if len(h.paths) == 0 {
+		h.paths = []string{"/"}
 	}
+	h.addr = fmt.Sprintf("%s:%d", h.listenAddr, h.port)
+	h.wg = &sync.WaitGroup{}
+	h.ctx = context.Background()
 
-	if port, exists := config["Port"]; exists {
-		var ok bool
-		if h.port, ok = port.(int); !ok {
-			return errors.New("cant convert port to int")
-		}
-	} else {
-		h.port = 8080
+	auth, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return err
+	}
+	h.auth = nil
+	if auth.enabled() {
+		h.auth = auth
 	}
 
-	if bufferSize, exists := config["BufferSize"]; exists {
-		var ok bool
-		if h.bufferSize, ok = bufferSize.(int64); !ok {
-			return errors.New("cant convert bufferSize to int")
+	h.tlsConfig = nil
+	if cfg.TLS.Enabled() {
+		var err error
+		if h.tlsConfig, err = cfg.TLS.ServerConfig(); err != nil {
+			return err
 		}
 	}
-	if h.bufferSize == 0 {
-		h.bufferSize = DefaultHttpBufferSize
-	}
-	h.addr = fmt.Sprintf("%s:%d", h.listenAddr, h.port)
-	h.server = &http.Server{
-		Addr:        h.addr,
-		Handler:     http.DefaultServeMux,
-		ReadTimeout: time.Second * 30,
-	}
-
-	h.wg = &sync.WaitGroup{}
 
 	return nil
 }
@@ -95,68 +115,186 @@ func (h *InHTTP) handleReq(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check Content-Length header
-	if r.ContentLength > h.bufferSize {
+	body, err := readBody(w, r, h.bufferSize)
+	if errors.Is(err, errBodyTooLarge) {
 		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
 		return
 	}
-
-	// Read the entire body
-	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 
-	logLines := make(chan internal.Event, 1000)
-	defer close(logLines)
-
-	h.wg.Add(1)
-
-	go func() {
-		defer h.wg.Done()
-		for event := range logLines {
-			h.outputCh <- event
+	// The tag of the credentials overrides the one of the path, which
+	// overrides the one of the input
+	tag := r.PathValue("tag")
+	if h.auth != nil {
+		credentialsTag, ok := h.auth.authenticate(r, body)
+		if !ok {
+			authFailures.WithLabelValues(h.name).Inc()
+			h.auth.challenge(w)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
-	}()
+		if credentialsTag != "" {
+			tag = credentialsTag
+		}
+	}
 
-	linenumber := 0
+	body, err = decodeBody(r.Header.Get("Content-Encoding"), body, h.bufferSize)
+	if err != nil {
+		http.Error(w, err.Error(), decodeStatus(err))
+		return
+	}
 
-	lines := bytes.Split(body, []byte{'\n'})
+	var events []internal.Event
+	fields := h.requestFields(r)
 	currTime := time.Now()
-
-	for _, line := range lines {
-		line = bytes.TrimSuffix(line, []byte{'\r'})
-		// Check if there is an empty line
-		if slices.Equal(line, []byte{}) {
-			continue
+	unit := "lines"
+	if isJSON(r) {
+		unit = "events"
+		objects, err := jsonObjects(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		linenumber++
-		event := internal.Event{
-			RawData:   string(line),
-			Timestamp: currTime,
-			Metadata: internal.Metadata{
-				Source:  r.RemoteAddr,
-				LineNum: linenumber,
-			},
+		for i, object := range objects {
+			event := h.newEvent(r, fields, i+1, currTime)
+			event.RawData = string(object.raw)
+			event.ParsedData = object.data
+			events = append(events, event)
 		}
+	} else {
+		for i, line := range splitLines(body) {
+			event := h.newEvent(r, fields, i+1, currTime)
+			event.RawData = line
+			events = append(events, event)
+		}
+	}
 
-		input.AddMetadata(&event, h)
-		logLines <- event
+	if tag != "" {
+		for i := range events {
+			events[i].Metadata.Tag = tag
+		}
 	}
+	h.send(events)
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Successfully processed %d lines", linenumber)
+	fmt.Fprintf(w, "Successfully processed %d %s", len(events), unit)
+}
+
+// newEvent returns an event of a request with the metadata of the input
+func (h *InHTTP) newEvent(r *http.Request, fields map[string]string, lineNum int, timestamp time.Time) internal.Event {
+	event := internal.Event{
+		Timestamp: timestamp,
+		Metadata: internal.Metadata{
+			Source:  r.RemoteAddr,
+			LineNum: lineNum,
+			Fields:  maps.Clone(fields),
+		},
+	}
+	input.AddMetadata(&event, h)
+	return event
+}
+
+// requestFields returns the metadata fields every event of a request gets
+func (h *InHTTP) requestFields(r *http.Request) map[string]string {
+	fields := map[string]string{}
+	if cn := input.ClientCN(r.TLS); cn != "" {
+		fields[input.ClientCNField] = cn
+	}
+	if h.queryParams {
+		for name, values := range r.URL.Query() {
+			fields["query_"+name] = strings.Join(values, ",")
+		}
+	}
+	for _, header := range h.headers {
+		if values := r.Header.Values(header); len(values) > 0 {
+			fields[headerField(header)] = strings.Join(values, ",")
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// headerField returns the name of the metadata field of a header, e.g.
+// header_x_request_id for X-Request-Id
+func headerField(header string) string {
+	return "header_" + strings.ReplaceAll(strings.ToLower(header), "-", "_")
+}
+
+// send passes the events of a request on to the output. The handler only
+// waits for the output once more than 1000 events are pending. Once the
+// engine stopped reading the output the events are dropped, so neither the
+// handler nor Exit wait for it forever.
+func (h *InHTTP) send(events []internal.Event) {
+	pending := make(chan internal.Event, 1000)
+	defer close(pending)
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		dropped := 0
+		for event := range pending {
+			if dropped > 0 {
+				dropped++
+				continue
+			}
+			select {
+			case h.outputCh <- event:
+			case <-h.ctx.Done():
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			logrus.WithField("events", dropped).Warn("Http input stopped before the events of a request were handed over, dropping them")
+		}
+	}()
+
+	for _, event := range events {
+		pending <- event
+	}
 }
 
 func (h *InHTTP) Start(ctx context.Context, output chan<- internal.Event) error {
 	h.outputCh = output
 	h.ctx = ctx
-	http.HandleFunc("/", h.handleReq)
+
+	// Bind before returning, so a port that is already in use fails the start
+	listener, err := net.Listen("tcp", h.addr)
+	if err != nil {
+		return fmt.Errorf("couldn't start http input: %w", err)
+	}
+	if h.tlsConfig != nil {
+		listener = tls.NewListener(listener, h.tlsConfig)
+	}
+
+	// A server that was shut down can't be started again, so every start
+	// gets its own server and mux
+	mux := http.NewServeMux()
+	switch h.mode {
+	case "splunk-hec":
+		h.registerHEC(mux)
+	case "otlp":
+		h.registerOTLP(mux)
+	default:
+		for _, path := range h.paths {
+			mux.HandleFunc(path, h.countRequests(h.handleReq))
+		}
+	}
+	h.server = &http.Server{
+		Addr:        h.addr,
+		Handler:     mux,
+		ReadTimeout: time.Second * 30,
+	}
+
 	go func() {
-		logrus.WithField("Addr", h.addr).Info("Starting Http Input")
-		if err := h.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.WithFields(logrus.Fields{"Addr": h.addr, "tls": h.tlsConfig != nil}).Info("Starting Http Input")
+		if err := h.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			logrus.WithField("Addr", h.addr).WithError(err).Error("error during http input")
+			input.ReportError(ctx, err)
 		}
 	}()
 	return nil
@@ -164,6 +302,9 @@ func (h *InHTTP) Start(ctx context.Context, output chan<- internal.Event) error
 
 func (h *InHTTP) Exit() error {
 	logrus.Info("Stopping Http Input")
+	if h.server == nil {
+		return nil
+	}
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer shutdownCancel()
 	if err := h.server.Shutdown(shutdownCtx); err != nil {
@@ -2,7 +2,11 @@ package inputhttp
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -11,7 +15,10 @@ import (
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/tlstest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInHTTP_Init(t *testing.T) {
@@ -137,6 +144,7 @@ func TestInHTTP_HandleReq(t *testing.T) {
 			h := &InHTTP{
 				bufferSize: DefaultHttpBufferSize,
 				wg:         &sync.WaitGroup{},
+				ctx:        context.Background(),
 			}
 
 			// Create output channel
@@ -218,6 +226,23 @@ func TestInHTTP_StartAndExit(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestInHTTP_StartFailsOnBoundPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	h := &InHTTP{}
+	err = h.Init(map[string]any{
+		"ListenAddr": "localhost",
+		"Port":       listener.Addr().(*net.TCPAddr).Port,
+	})
+	assert.NoError(t, err)
+
+	err = h.Start(context.Background(), make(chan internal.Event))
+	assert.ErrorContains(t, err, "couldn't start http input")
+	assert.NoError(t, h.Exit())
+}
+
 func TestAddMetadata(t *testing.T) {
 	event := &internal.Event{}
 	inputContent := &InHTTP{
@@ -230,3 +255,215 @@ func TestAddMetadata(t *testing.T) {
 	assert.Equal(t, "test_http", event.Metadata.InputSource)
 	assert.Equal(t, "test_tag", event.Metadata.Tag)
 }
+
+func TestInHTTP_MutualTLS(t *testing.T) {
+	files := tlstest.New(t)
+	listener, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	h := &InHTTP{}
+	err = h.Init(map[string]any{
+		"ListenAddr": "localhost",
+		"Port":       port,
+		"TLS": map[string]any{
+			"CertFile":   files.CertFile,
+			"KeyFile":    files.KeyFile,
+			"CAFile":     files.CAFile,
+			"ClientAuth": "require-and-verify",
+		},
+	})
+	assert.NoError(t, err)
+
+	output := make(chan internal.Event, 10)
+	assert.NoError(t, h.Start(context.Background(), output))
+	defer h.Exit()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: files.ClientConfig(t, "billing")}}
+	resp, err := client.Post("https://"+h.addr, "text/plain", bytes.NewBufferString("test"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	event := <-output
+	assert.Equal(t, "billing", event.Metadata.Fields[input.ClientCNField])
+
+	// Without a client certificate the handshake fails
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: files.ClientConfig(t, "")}}
+	_, err = client.Post("https://"+h.addr, "text/plain", bytes.NewBufferString("test"))
+	assert.Error(t, err)
+}
+
+func TestInHTTP_Routes(t *testing.T) {
+	output := make(chan internal.Event, 10)
+
+	// Two inputs in one process don't share their routes
+	routed := &InHTTP{}
+	require.NoError(t, routed.Init(map[string]any{
+		"ListenAddr": "localhost",
+		"Port":       inputtest.FreePort(t, "tcp"),
+		"Tag":        "routed",
+		"Paths":      []any{"/logs/{tag}", "/ingest"},
+	}))
+	require.NoError(t, routed.Start(context.Background(), output))
+	defer routed.Exit()
+
+	other := &InHTTP{}
+	require.NoError(t, other.Init(map[string]any{"ListenAddr": "localhost", "Port": inputtest.FreePort(t, "tcp"), "Tag": "other"}))
+	require.NoError(t, other.Start(context.Background(), output))
+	defer other.Exit()
+
+	tests := []struct {
+		addr       string
+		path       string
+		wantStatus int
+		wantTag    string
+	}{
+		{routed.addr, "/logs/billing", http.StatusOK, "billing"},
+		{routed.addr, "/ingest", http.StatusOK, "routed"},
+		{routed.addr, "/logs", http.StatusNotFound, ""},
+		{routed.addr, "/", http.StatusNotFound, ""},
+		{other.addr, "/logs/billing", http.StatusOK, "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr+tt.path, func(t *testing.T) {
+			resp, err := http.Post("http://"+tt.addr+tt.path, "text/plain", bytes.NewBufferString("line"))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantTag, (<-output).Metadata.Tag)
+			}
+		})
+	}
+}
+
+func TestInHTTP_PathsErrors(t *testing.T) {
+	tests := []struct {
+		paths   []any
+		wantErr string
+	}{
+		{[]any{"logs"}, "Paths: 'logs' has to start with /"},
+		{[]any{"/logs/{host}"}, "Paths: unsupported wildcard '{host}' in '/logs/{host}', only {tag} is supported"},
+		{[]any{"/logs", "/logs"}, "Paths: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantErr, func(t *testing.T) {
+			err := (&InHTTP{}).Init(map[string]any{"Paths": tt.paths})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestInHTTP_Bodies(t *testing.T) {
+	compress := func(encoding, body string) *bytes.Buffer {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		}
+		w.Write([]byte(body))
+		w.Close()
+		return &buf
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        io.Reader
+		wantStatus  int
+		wantRaw     []string
+		wantParsed  []map[string]any
+	}{
+		{"lines", "text/plain", "", bytes.NewBufferString("a\nb"), http.StatusOK, []string{"a", "b"}, []map[string]any{nil, nil}},
+		{"gzip lines", "text/plain", "gzip", compress("gzip", "a\nb"), http.StatusOK, []string{"a", "b"}, []map[string]any{nil, nil}},
+		{"deflate lines", "text/plain", "deflate", compress("deflate", "a"), http.StatusOK, []string{"a"}, []map[string]any{nil}},
+		{
+			"json array", "application/json; charset=utf-8", "", bytes.NewBufferString(`[{"msg": "a"}, {"msg": "b", "n": 1}]`), http.StatusOK,
+			[]string{`{"msg":"a"}`, `{"msg":"b","n":1}`}, []map[string]any{{"msg": "a"}, {"msg": "b", "n": float64(1)}},
+		},
+		{"json object", "application/json", "gzip", compress("gzip", `{"msg":"a"}`), http.StatusOK, []string{`{"msg":"a"}`}, []map[string]any{{"msg": "a"}}},
+		{"json array of strings", "application/json", "", bytes.NewBufferString(`["a"]`), http.StatusBadRequest, nil, nil},
+		{"invalid json", "application/json", "", bytes.NewBufferString(`{"msg":`), http.StatusBadRequest, nil, nil},
+		{"unsupported encoding", "text/plain", "br", bytes.NewBufferString("a"), http.StatusUnsupportedMediaType, nil, nil},
+		{"broken gzip", "text/plain", "gzip", bytes.NewBufferString("a"), http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := make(chan internal.Event, 10)
+			h := &InHTTP{bufferSize: DefaultHttpBufferSize, wg: &sync.WaitGroup{}, ctx: context.Background(), outputCh: output}
+
+			req := httptest.NewRequest(http.MethodPost, "/", tt.body)
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rr := httptest.NewRecorder()
+			h.handleReq(rr, req)
+			h.wg.Wait()
+			close(output)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			var raw []string
+			var parsed []map[string]any
+			for event := range output {
+				raw = append(raw, event.RawData)
+				parsed = append(parsed, event.ParsedData)
+			}
+			assert.Equal(t, tt.wantRaw, raw)
+			assert.Equal(t, tt.wantParsed, parsed)
+		})
+	}
+}
+
+func TestInHTTP_BodyTooLargeDecompressed(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(bytes.Repeat([]byte("a"), 1024))
+	gz.Close()
+
+	h := &InHTTP{bufferSize: 512, wg: &sync.WaitGroup{}, ctx: context.Background()}
+	req := httptest.NewRequest(http.MethodPost, "/", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.handleReq(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestInHTTP_ChunkedBodyTooLarge(t *testing.T) {
+	h := &InHTTP{bufferSize: 512, wg: &sync.WaitGroup{}, ctx: context.Background()}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(make([]byte, 1024)))
+	req.ContentLength = -1
+	rr := httptest.NewRecorder()
+	h.handleReq(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestInHTTP_RequestFields(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"QueryParams": true, "Headers": []any{"X-Request-Id", "User-Agent"}}))
+	output := make(chan internal.Event, 1)
+	h.outputCh = output
+
+	req := httptest.NewRequest(http.MethodPost, "/?env=prod&team=a&team=b", bytes.NewBufferString("line"))
+	req.Header.Set("X-Request-Id", "42")
+	req.Header.Set("X-Other", "ignored")
+	rr := httptest.NewRecorder()
+	h.handleReq(rr, req)
+	h.wg.Wait()
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]string{
+		"query_env":           "prod",
+		"query_team":          "a,b",
+		"header_x_request_id": "42",
+	}, (<-output).Metadata.Fields)
+}
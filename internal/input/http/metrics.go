@@ -0,0 +1,35 @@
+package inputhttp
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/metrics"
+)
+
+var (
+	requests = metrics.NewCounterVec("forwarder_http_requests_total",
+		"Requests an http input handled, by status code.", "input", "code")
+	authFailures = metrics.NewCounterVec("forwarder_http_auth_failures_total",
+		"Requests an http input rejected because they didn't authenticate.", "input")
+)
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// countRequests counts the requests handled by next per status code
+func (h *InHTTP) countRequests(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next(recorder, r)
+		requests.WithLabelValues(h.name, strconv.Itoa(recorder.code)).Inc()
+	}
+}
@@ -0,0 +1,230 @@
+package inputhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/protobuf"
+)
+
+// ServiceNameField is the metadata field the service.name of the resource
+// of an OTLP log record is recorded in
+const ServiceNameField = "service_name"
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// rpcCodes are the codes of the google.rpc.Status of failed requests
+var rpcCodes = map[int]int{
+	http.StatusBadRequest:            3,  // INVALID_ARGUMENT
+	http.StatusUnauthorized:          16, // UNAUTHENTICATED
+	http.StatusRequestEntityTooLarge: 8,  // RESOURCE_EXHAUSTED
+	http.StatusUnsupportedMediaType:  3,  // INVALID_ARGUMENT
+}
+
+func (h *InHTTP) registerOTLP(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/logs", h.countRequests(h.handleOTLP))
+}
+
+// handleOTLP accepts an ExportLogsServiceRequest of OTLP/HTTP in the JSON or
+// protobuf encoding. Every log record becomes an event.
+func (h *InHTTP) handleOTLP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, fmt.Sprintf("unsupported content type '%s', expected %s or %s", contentType, contentTypeProtobuf, contentTypeJSON), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readBody(w, r, h.bufferSize)
+	if errors.Is(err, errBodyTooLarge) {
+		writeOTLPStatus(w, contentType, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	// The tag of the credentials overrides the service name
+	var tag string
+	if h.auth != nil {
+		var ok bool
+		if tag, ok = h.auth.authenticate(r, body); !ok {
+			authFailures.WithLabelValues(h.name).Inc()
+			h.auth.challenge(w)
+			writeOTLPStatus(w, contentType, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+	}
+
+	body, err = decodeBody(r.Header.Get("Content-Encoding"), body, h.bufferSize)
+	if err != nil {
+		writeOTLPStatus(w, contentType, decodeStatus(err), err.Error())
+		return
+	}
+
+	var resources []otlpResourceLogs
+	if contentType == contentTypeJSON {
+		resources, err = decodeOTLPJSON(body)
+	} else {
+		resources, err = decodeOTLPProtobuf(body)
+	}
+	if err != nil {
+		writeOTLPStatus(w, contentType, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, rejected := h.otlpEvents(r, resources)
+	if tag != "" {
+		for i := range events {
+			events[i].Metadata.Tag = tag
+		}
+	}
+	h.send(events)
+
+	errorMessage := ""
+	if rejected > 0 {
+		errorMessage = fmt.Sprintf("%d log records without body and attributes were rejected", rejected)
+	}
+	writeOTLPResponse(w, contentType, rejected, errorMessage)
+}
+
+// otlpEvents flattens the log records of the resources into events. Empty
+// log records are left out and counted as rejected.
+func (h *InHTTP) otlpEvents(r *http.Request, resources []otlpResourceLogs) ([]internal.Event, int) {
+	fields := h.requestFields(r)
+	now := time.Now()
+
+	var events []internal.Event
+	rejected := 0
+	for _, resource := range resources {
+		serviceName, _ := resource.resource["service.name"].(string)
+		hostName, _ := resource.resource["host.name"].(string)
+
+		for _, scope := range resource.scopes {
+			for _, record := range scope.records {
+				if record.body == nil && len(record.attributes) == 0 && record.eventName == "" {
+					rejected++
+					continue
+				}
+
+				event := h.newEvent(r, fields, len(events)+1, now)
+				event.ParsedData = otlpData(record, resource, scope)
+				raw, _ := json.Marshal(event.ParsedData)
+				event.RawData = string(raw)
+				switch {
+				case record.time != 0:
+					event.Timestamp = time.Unix(0, int64(record.time))
+				case record.observedTime != 0:
+					event.Timestamp = time.Unix(0, int64(record.observedTime))
+				}
+				if serviceName != "" {
+					event.Metadata.Tag = serviceName
+					setField(&event.Metadata, ServiceNameField, serviceName)
+				}
+				if hostName != "" {
+					event.Metadata.Host = hostName
+				}
+				events = append(events, event)
+			}
+		}
+	}
+	return events, rejected
+}
+
+// otlpData returns the parsed data of a log record. The attributes of the
+// resource are copied, as filters may change the data of an event.
+func otlpData(record otlpLogRecord, resource otlpResourceLogs, scope otlpScopeLogs) map[string]any {
+	data := map[string]any{}
+	if record.body != nil {
+		data["body"] = record.body
+	}
+	if record.severityText != "" {
+		data["severity_text"] = record.severityText
+	}
+	if record.severityNumber != 0 {
+		data["severity_number"] = record.severityNumber
+	}
+	if len(record.attributes) > 0 {
+		data["attributes"] = record.attributes
+	}
+	if len(resource.resource) > 0 {
+		data["resource"] = maps.Clone(resource.resource)
+	}
+	if scope.name != "" {
+		data["scope"] = map[string]any{"name": scope.name, "version": scope.version}
+	}
+	if record.traceID != "" {
+		data["trace_id"] = record.traceID
+	}
+	if record.spanID != "" {
+		data["span_id"] = record.spanID
+	}
+	if record.flags != 0 {
+		data["flags"] = int64(record.flags)
+	}
+	if record.eventName != "" {
+		data["event_name"] = record.eventName
+	}
+	return data
+}
+
+// writeOTLPResponse writes an ExportLogsServiceResponse, which holds the
+// partial success if log records were rejected
+func writeOTLPResponse(w http.ResponseWriter, contentType string, rejected int, errorMessage string) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	if contentType == contentTypeJSON {
+		response := map[string]any{}
+		if rejected > 0 {
+			response["partialSuccess"] = map[string]any{
+				// 64 bit integers are strings in the JSON encoding
+				"rejectedLogRecords": strconv.Itoa(rejected),
+				"errorMessage":       errorMessage,
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var response []byte
+	if rejected > 0 {
+		var partialSuccess []byte
+		partialSuccess = protobuf.AppendVarint(partialSuccess, 1, uint64(rejected))
+		partialSuccess = protobuf.AppendString(partialSuccess, 2, errorMessage)
+		response = protobuf.AppendBytes(response, 1, partialSuccess)
+	}
+	w.Write(response)
+}
+
+// writeOTLPStatus writes the google.rpc.Status of a failed request
+func writeOTLPStatus(w http.ResponseWriter, contentType string, status int, message string) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+
+	if contentType == contentTypeJSON {
+		json.NewEncoder(w).Encode(map[string]any{"code": rpcCodes[status], "message": message})
+		return
+	}
+
+	var response []byte
+	response = protobuf.AppendVarint(response, 1, uint64(rpcCodes[status]))
+	response = protobuf.AppendString(response, 2, message)
+	w.Write(response)
+}
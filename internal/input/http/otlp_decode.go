@@ -0,0 +1,477 @@
+package inputhttp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/protobuf"
+)
+
+// otlpResourceLogs are the log records of a resource of an
+// ExportLogsServiceRequest, decoded from JSON or protobuf
+type otlpResourceLogs struct {
+	resource map[string]any
+	scopes   []otlpScopeLogs
+}
+
+type otlpScopeLogs struct {
+	name    string
+	version string
+	records []otlpLogRecord
+}
+
+type otlpLogRecord struct {
+	time           uint64
+	observedTime   uint64
+	severityNumber int64
+	severityText   string
+	body           any // nil without a body
+	attributes     map[string]any
+	traceID        string
+	spanID         string
+	flags          uint32
+	eventName      string
+}
+
+// The JSON encoding of OTLP, which uses lowerCamelCase field names, strings
+// for 64 bit integers and hex for trace and span ids
+
+type jsonLogsRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scope"`
+			LogRecords []jsonLogRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type jsonLogRecord struct {
+	TimeUnixNano         jsonInt        `json:"timeUnixNano"`
+	ObservedTimeUnixNano jsonInt        `json:"observedTimeUnixNano"`
+	SeverityNumber       int64          `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 *jsonAnyValue  `json:"body"`
+	Attributes           []jsonKeyValue `json:"attributes"`
+	TraceID              string         `json:"traceId"`
+	SpanID               string         `json:"spanId"`
+	Flags                uint32         `json:"flags"`
+	EventName            string         `json:"eventName"`
+}
+
+type jsonKeyValue struct {
+	Key   string       `json:"key"`
+	Value jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string    `json:"stringValue"`
+	BoolValue   *bool      `json:"boolValue"`
+	IntValue    *jsonInt   `json:"intValue"`
+	DoubleValue *jsonFloat `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue []byte `json:"bytesValue"`
+}
+
+// jsonInt is a 64 bit integer, which is sent as string or number
+type jsonInt int64
+
+func (i *jsonInt) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" {
+		return nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*i = jsonInt(n)
+		return nil
+	}
+	// Times beyond 2262 don't fit into an int64
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	*i = jsonInt(n)
+	return nil
+}
+
+// jsonFloat is a double, which may be sent as NaN or Infinity string
+type jsonFloat float64
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	switch value := strings.Trim(string(data), `"`); value {
+	case "NaN":
+		*f = jsonFloat(math.NaN())
+	case "Infinity":
+		*f = jsonFloat(math.Inf(1))
+	case "-Infinity":
+		*f = jsonFloat(math.Inf(-1))
+	default:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid double %s", data)
+		}
+		*f = jsonFloat(n)
+	}
+	return nil
+}
+
+func decodeOTLPJSON(body []byte) ([]otlpResourceLogs, error) {
+	var request jsonLogsRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON: %w", err)
+	}
+
+	var resources []otlpResourceLogs
+	for _, rl := range request.ResourceLogs {
+		resource := otlpResourceLogs{resource: jsonKeyValues(rl.Resource.Attributes)}
+		for _, sl := range rl.ScopeLogs {
+			scope := otlpScopeLogs{name: sl.Scope.Name, version: sl.Scope.Version}
+			for _, lr := range sl.LogRecords {
+				record := otlpLogRecord{
+					time:           uint64(lr.TimeUnixNano),
+					observedTime:   uint64(lr.ObservedTimeUnixNano),
+					severityNumber: lr.SeverityNumber,
+					severityText:   lr.SeverityText,
+					attributes:     jsonKeyValues(lr.Attributes),
+					traceID:        strings.ToLower(lr.TraceID),
+					spanID:         strings.ToLower(lr.SpanID),
+					flags:          lr.Flags,
+					eventName:      lr.EventName,
+				}
+				if lr.Body != nil {
+					record.body = lr.Body.value()
+				}
+				scope.records = append(scope.records, record)
+			}
+			resource.scopes = append(resource.scopes, scope)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+func jsonKeyValues(kvs []jsonKeyValue) map[string]any {
+	if len(kvs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.value()
+	}
+	return m
+}
+
+// value returns the Go value of an AnyValue, bytes are kept as base64
+func (v jsonAnyValue) value() any {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return float64(*v.DoubleValue)
+	case v.ArrayValue != nil:
+		values := make([]any, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			values = append(values, item.value())
+		}
+		return values
+	case v.KvlistValue != nil:
+		m := jsonKeyValues(v.KvlistValue.Values)
+		if m == nil {
+			m = map[string]any{}
+		}
+		return m
+	case v.BytesValue != nil:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	}
+	return nil
+}
+
+// maxOTLPDepth limits the nesting of the arrays and key value lists of a
+// protobuf request
+const maxOTLPDepth = 100
+
+// The protobuf encoding of OTLP, the field numbers are the ones of
+// opentelemetry/proto/collector/logs/v1/logs_service.proto and the messages
+// it uses
+
+func decodeOTLPProtobuf(body []byte) ([]otlpResourceLogs, error) {
+	var resources []otlpResourceLogs
+	err := eachField(body, func(r *protobuf.Reader, field, wireType int) error {
+		if field != 1 || wireType != protobuf.Bytes {
+			return r.Skip(wireType)
+		}
+		data, err := r.Bytes()
+		if err != nil {
+			return err
+		}
+		resource, err := decodeResourceLogs(data)
+		resources = append(resources, resource)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP protobuf: %w", err)
+	}
+	return resources, nil
+}
+
+// eachField calls fn with the key of every field of a message, fn has to
+// read or skip the value
+func eachField(data []byte, fn func(r *protobuf.Reader, field, wireType int) error) error {
+	r := protobuf.NewReader(data)
+	for {
+		field, wireType, ok, err := r.Next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := fn(r, field, wireType); err != nil {
+			return err
+		}
+	}
+}
+
+// embedded reads a length delimited field and decodes it with fn
+func embedded(r *protobuf.Reader, wireType int, fn func(data []byte) error) error {
+	if wireType != protobuf.Bytes {
+		return fmt.Errorf("unexpected wire type %d", wireType)
+	}
+	data, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return fn(data)
+}
+
+func protoString(r *protobuf.Reader, wireType int) (string, error) {
+	var s string
+	err := embedded(r, wireType, func(data []byte) error {
+		s = string(data)
+		return nil
+	})
+	return s, err
+}
+
+func protoVarint(r *protobuf.Reader, wireType int) (uint64, error) {
+	if wireType != protobuf.Varint {
+		return 0, fmt.Errorf("unexpected wire type %d", wireType)
+	}
+	return r.Varint()
+}
+
+func decodeResourceLogs(data []byte) (otlpResourceLogs, error) {
+	var resource otlpResourceLogs
+	err := eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+		switch field {
+		case 1: // resource
+			return embedded(r, wireType, func(data []byte) error {
+				return eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+					if field == 1 { // attributes
+						return decodeKeyValue(r, wireType, &resource.resource, 0)
+					}
+					return r.Skip(wireType)
+				})
+			})
+		case 2: // scope_logs
+			return embedded(r, wireType, func(data []byte) error {
+				scope, err := decodeScopeLogs(data)
+				resource.scopes = append(resource.scopes, scope)
+				return err
+			})
+		}
+		return r.Skip(wireType)
+	})
+	return resource, err
+}
+
+func decodeScopeLogs(data []byte) (otlpScopeLogs, error) {
+	var scope otlpScopeLogs
+	err := eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+		switch field {
+		case 1: // scope
+			return embedded(r, wireType, func(data []byte) error {
+				return eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+					var err error
+					switch field {
+					case 1:
+						scope.name, err = protoString(r, wireType)
+					case 2:
+						scope.version, err = protoString(r, wireType)
+					default:
+						err = r.Skip(wireType)
+					}
+					return err
+				})
+			})
+		case 2: // log_records
+			return embedded(r, wireType, func(data []byte) error {
+				record, err := decodeLogRecord(data)
+				scope.records = append(scope.records, record)
+				return err
+			})
+		}
+		return r.Skip(wireType)
+	})
+	return scope, err
+}
+
+func decodeLogRecord(data []byte) (otlpLogRecord, error) {
+	var record otlpLogRecord
+	err := eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+		var err error
+		switch {
+		case (field == 1 || field == 11) && wireType == protobuf.Fixed64:
+			var t uint64
+			t, err = r.Fixed64()
+			if field == 1 {
+				record.time = t
+			} else {
+				record.observedTime = t
+			}
+		case field == 2:
+			var n uint64
+			n, err = protoVarint(r, wireType)
+			record.severityNumber = int64(n)
+		case field == 3:
+			record.severityText, err = protoString(r, wireType)
+		case field == 5:
+			err = embedded(r, wireType, func(data []byte) error {
+				var err error
+				record.body, err = decodeAnyValue(data, 0)
+				return err
+			})
+		case field == 6:
+			err = decodeKeyValue(r, wireType, &record.attributes, 0)
+		case field == 8 && wireType == protobuf.Fixed32:
+			record.flags, err = r.Fixed32()
+		case field == 9 || field == 10:
+			err = embedded(r, wireType, func(data []byte) error {
+				if field == 9 {
+					record.traceID = hex.EncodeToString(data)
+				} else {
+					record.spanID = hex.EncodeToString(data)
+				}
+				return nil
+			})
+		case field == 12:
+			record.eventName, err = protoString(r, wireType)
+		default:
+			err = r.Skip(wireType)
+		}
+		return err
+	})
+	return record, err
+}
+
+// decodeKeyValue reads a KeyValue field into m
+func decodeKeyValue(r *protobuf.Reader, wireType int, m *map[string]any, depth int) error {
+	return embedded(r, wireType, func(data []byte) error {
+		var key string
+		var value any
+		err := eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+			var err error
+			switch field {
+			case 1:
+				key, err = protoString(r, wireType)
+			case 2:
+				err = embedded(r, wireType, func(data []byte) error {
+					var err error
+					value, err = decodeAnyValue(data, depth)
+					return err
+				})
+			default:
+				err = r.Skip(wireType)
+			}
+			return err
+		})
+		if *m == nil {
+			*m = map[string]any{}
+		}
+		(*m)[key] = value
+		return err
+	})
+}
+
+// decodeAnyValue returns the Go value of an AnyValue, bytes are kept as
+// base64
+func decodeAnyValue(data []byte, depth int) (any, error) {
+	if depth > maxOTLPDepth {
+		return nil, errors.New("values nested too deep")
+	}
+	var value any
+	err := eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+		var err error
+		switch field {
+		case 1:
+			value, err = protoString(r, wireType)
+		case 2:
+			var n uint64
+			n, err = protoVarint(r, wireType)
+			value = n != 0
+		case 3:
+			var n uint64
+			n, err = protoVarint(r, wireType)
+			value = int64(n)
+		case 4:
+			if wireType != protobuf.Fixed64 {
+				return fmt.Errorf("unexpected wire type %d", wireType)
+			}
+			var bits uint64
+			bits, err = r.Fixed64()
+			value = math.Float64frombits(bits)
+		case 5:
+			values := []any{}
+			err = embedded(r, wireType, func(data []byte) error {
+				return eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+					if field != 1 {
+						return r.Skip(wireType)
+					}
+					return embedded(r, wireType, func(data []byte) error {
+						item, err := decodeAnyValue(data, depth+1)
+						values = append(values, item)
+						return err
+					})
+				})
+			})
+			value = values
+		case 6:
+			m := map[string]any{}
+			err = embedded(r, wireType, func(data []byte) error {
+				return eachField(data, func(r *protobuf.Reader, field, wireType int) error {
+					if field != 1 {
+						return r.Skip(wireType)
+					}
+					return decodeKeyValue(r, wireType, &m, depth+1)
+				})
+			})
+			value = m
+		case 7:
+			err = embedded(r, wireType, func(data []byte) error {
+				value = base64.StdEncoding.EncodeToString(data)
+				return nil
+			})
+		default:
+			err = r.Skip(wireType)
+		}
+		return err
+	})
+	return value, err
+}
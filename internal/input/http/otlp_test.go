@@ -0,0 +1,293 @@
+package inputhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/MuchTitan/go-log-forwarder/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otlpRequest sends a request to the OTLP endpoint of h and returns the
+// response and the events it produced
+func otlpRequest(t *testing.T, h *InHTTP, contentType string, body []byte) (*httptest.ResponseRecorder, []internal.Event) {
+	t.Helper()
+	output := make(chan internal.Event, 100)
+	h.outputCh = output
+	h.wg = &sync.WaitGroup{}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	mux := http.NewServeMux()
+	h.registerOTLP(mux)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	h.wg.Wait()
+	close(output)
+
+	var events []internal.Event
+	for event := range output {
+		events = append(events, event)
+	}
+	return rr, events
+}
+
+const otlpJSONBody = `{
+  "resourceLogs": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "checkout"}},
+      {"key": "host.name", "value": {"stringValue": "node-1"}}
+    ]},
+    "scopeLogs": [{
+      "scope": {"name": "app.logger", "version": "1.0"},
+      "logRecords": [
+        {
+          "timeUnixNano": "1700000000123456789",
+          "severityNumber": 9,
+          "severityText": "INFO",
+          "body": {"stringValue": "order placed"},
+          "attributes": [
+            {"key": "order.id", "value": {"intValue": "42"}},
+            {"key": "amount", "value": {"doubleValue": 9.5}},
+            {"key": "paid", "value": {"boolValue": true}},
+            {"key": "items", "value": {"arrayValue": {"values": [{"stringValue": "book"}]}}},
+            {"key": "customer", "value": {"kvlistValue": {"values": [{"key": "tier", "value": {"stringValue": "gold"}}]}}},
+            {"key": "raw", "value": {"bytesValue": "AQI="}}
+          ],
+          "traceId": "5B8EFFF798038103D269B633813FC60C",
+          "spanId": "EEE19B7EC3C1B174"
+        },
+        {"observedTimeUnixNano": 1700000001000000000}
+      ]
+    }]
+  }, {
+    "scopeLogs": [{"logRecords": [{"body": {"kvlistValue": {"values": [{"key": "msg", "value": {"stringValue": "no service"}}]}}}]}]
+  }]
+}`
+
+func TestInHTTP_OTLPJSON(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Tag": "otel", "Mode": "otlp"}))
+
+	rr, events := otlpRequest(t, h, "application/json", []byte(otlpJSONBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"1 log records without body and attributes were rejected"}}`, rr.Body.String())
+	require.Len(t, events, 2)
+
+	event := events[0]
+	assert.Equal(t, "checkout", event.Metadata.Tag)
+	assert.Equal(t, "node-1", event.Metadata.Host)
+	assert.Equal(t, map[string]string{ServiceNameField: "checkout"}, event.Metadata.Fields)
+	assert.Equal(t, time.Unix(1700000000, 123456789), event.Timestamp)
+	assert.Equal(t, map[string]any{
+		"body":            "order placed",
+		"severity_text":   "INFO",
+		"severity_number": int64(9),
+		"attributes": map[string]any{
+			"order.id": int64(42),
+			"amount":   9.5,
+			"paid":     true,
+			"items":    []any{"book"},
+			"customer": map[string]any{"tier": "gold"},
+			"raw":      "AQI=",
+		},
+		"resource": map[string]any{"service.name": "checkout", "host.name": "node-1"},
+		"scope":    map[string]any{"name": "app.logger", "version": "1.0"},
+		"trace_id": "5b8efff798038103d269b633813fc60c",
+		"span_id":  "eee19b7ec3c1b174",
+	}, event.ParsedData)
+	assert.Contains(t, event.RawData, `"body":"order placed"`)
+
+	// Without a service name the tag of the input is kept
+	assert.Equal(t, "otel", events[1].Metadata.Tag)
+	assert.Equal(t, map[string]any{"msg": "no service"}, events[1].ParsedData["body"])
+	assert.Nil(t, events[1].Metadata.Fields)
+}
+
+// otlpProtobufBody encodes a request with a resource and one log record in
+// the protobuf encoding
+func otlpProtobufBody() []byte {
+	keyValue := func(key string, value []byte) []byte {
+		kv := protobuf.AppendString(nil, 1, key)
+		return protobuf.AppendBytes(kv, 2, value)
+	}
+
+	var resource []byte
+	resource = protobuf.AppendBytes(resource, 1, keyValue("service.name", protobuf.AppendString(nil, 1, "payments")))
+
+	var record []byte
+	record = protobuf.AppendFixed64(record, 1, uint64(time.Unix(1700000000, 5).UnixNano()))
+	record = protobuf.AppendVarint(record, 2, 17)
+	record = protobuf.AppendString(record, 3, "ERROR")
+	record = protobuf.AppendBytes(record, 5, protobuf.AppendString(nil, 1, "card declined"))
+	record = protobuf.AppendBytes(record, 6, keyValue("retries", protobuf.AppendVarint(nil, 3, 3)))
+	record = protobuf.AppendBytes(record, 6, keyValue("ratio", protobuf.AppendFixed64(nil, 4, math.Float64bits(0.5))))
+	record = protobuf.AppendBytes(record, 6, keyValue("tags", protobuf.AppendBytes(nil, 5,
+		protobuf.AppendBytes(nil, 1, protobuf.AppendString(nil, 1, "a")))))
+	record = protobuf.AppendFixed32(record, 8, 1)
+	record = protobuf.AppendBytes(record, 9, []byte{0x01, 0xab})
+	record = protobuf.AppendVarint(record, 99, 1) // An unknown field
+
+	var scope []byte
+	scope = protobuf.AppendBytes(scope, 1, protobuf.AppendString(nil, 1, "payments.logger"))
+	scope = protobuf.AppendBytes(scope, 2, record)
+	scope = protobuf.AppendBytes(scope, 2, nil) // An empty log record
+
+	var resourceLogs []byte
+	resourceLogs = protobuf.AppendBytes(resourceLogs, 1, resource)
+	resourceLogs = protobuf.AppendBytes(resourceLogs, 2, scope)
+	return protobuf.AppendBytes(nil, 1, resourceLogs)
+}
+
+func TestInHTTP_OTLPProtobuf(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Mode": "otlp"}))
+
+	rr, events := otlpRequest(t, h, "application/x-protobuf", otlpProtobufBody())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-protobuf", rr.Header().Get("Content-Type"))
+	require.Len(t, events, 1)
+
+	event := events[0]
+	assert.Equal(t, "payments", event.Metadata.Tag)
+	assert.Equal(t, time.Unix(1700000000, 5), event.Timestamp)
+	assert.Equal(t, map[string]any{
+		"body":            "card declined",
+		"severity_text":   "ERROR",
+		"severity_number": int64(17),
+		"attributes":      map[string]any{"retries": int64(3), "ratio": 0.5, "tags": []any{"a"}},
+		"resource":        map[string]any{"service.name": "payments"},
+		"scope":           map[string]any{"name": "payments.logger", "version": ""},
+		"trace_id":        "01ab",
+		"flags":           int64(1),
+	}, event.ParsedData)
+
+	// The response holds the partial success with the rejected empty record
+	r := protobuf.NewReader(rr.Body.Bytes())
+	field, _, ok, err := r.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 1, field)
+	partialSuccess, err := r.Bytes()
+	require.NoError(t, err)
+	r = protobuf.NewReader(partialSuccess)
+	r.Next()
+	rejected, _ := r.Varint()
+	assert.Equal(t, uint64(1), rejected)
+}
+
+func TestInHTTP_OTLPErrors(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Mode": "otlp", "Auth": map[string]any{"Tokens": []any{map[string]any{"Token": "abc", "Tag": "team-a"}}}}))
+
+	// Requests without a token are rejected in the encoding of the request
+	rr, events := otlpRequest(t, h, "application/json", []byte(otlpJSONBody))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"code":16,"message":"Unauthorized"}`, rr.Body.String())
+	assert.Empty(t, events)
+
+	h.auth = nil
+	rr, _ = otlpRequest(t, h, "application/json", []byte(`{"resourceLogs": [{"scopeLogs": 1}]}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":3`)
+
+	rr, _ = otlpRequest(t, h, "application/x-protobuf", []byte{0x0a, 0x05, 0x01})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr, _ = otlpRequest(t, h, "text/plain", []byte("line"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+
+	// The body of a chunked request is limited as well
+	h.bufferSize = 64
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(make([]byte, 128)))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	mux := http.NewServeMux()
+	h.registerOTLP(mux)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":8`)
+	h.bufferSize = 5 << 20
+
+	// Values nested too deep
+	value := protobuf.AppendString(nil, 1, "leaf")
+	for range maxOTLPDepth + 1 {
+		value = protobuf.AppendBytes(nil, 5, protobuf.AppendBytes(nil, 1, value))
+	}
+	record := protobuf.AppendBytes(nil, 5, value)
+	body := protobuf.AppendBytes(nil, 1, protobuf.AppendBytes(nil, 2, protobuf.AppendBytes(nil, 2, record)))
+	rr, _ = otlpRequest(t, h, "application/x-protobuf", body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestInHTTP_OTLPAuthAndGzip(t *testing.T) {
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"Mode": "otlp", "Auth": map[string]any{"Tokens": []any{map[string]any{"Token": "abc", "Tag": "team-a"}}}}))
+	output := make(chan internal.Event, 10)
+	h.outputCh = output
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(otlpProtobufBody())
+	require.NoError(t, gz.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", &compressed)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Authorization", "Bearer abc")
+	mux := http.NewServeMux()
+	h.registerOTLP(mux)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	h.wg.Wait()
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	event := <-output
+	// The tag of the token overrides the service name
+	assert.Equal(t, "team-a", event.Metadata.Tag)
+	assert.Equal(t, "payments", event.Metadata.Fields[ServiceNameField])
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/logs", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestInHTTP_OTLPExitWithUnreadOutput(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	h := &InHTTP{}
+	require.NoError(t, h.Init(map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "Mode": "otlp"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Nothing reads the output, like after the engine stopped
+	require.NoError(t, h.Start(ctx, make(chan internal.Event)))
+
+	resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/v1/logs", port), "application/json", bytes.NewBufferString(otlpJSONBody))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	exited := make(chan struct{})
+	go func() {
+		h.Exit()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exit blocked on the unread output")
+	}
+}
@@ -5,14 +5,62 @@ import (
 	"os"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/sirupsen/logrus"
 )
 
+// Plugin is an input of the forwarder. Start must return once the input is
+// running; errors that stop a running input are reported with ReportError.
+// An input has to support being started again after Exit, the engine
+// restarts inputs that failed.
 type Plugin interface {
 	internal.Plugin
 	Start(ctx context.Context, output chan<- internal.Event) error
 	Tag() string
 }
 
+type errorChannelKey struct{}
+
+// WithErrorChannel returns a context that lets the input started with it
+// report fatal errors to errCh
+func WithErrorChannel(ctx context.Context, errCh chan<- error) context.Context {
+	return context.WithValue(ctx, errorChannelKey{}, errCh)
+}
+
+// ReportError reports an error that stopped the input to the engine, which
+// restarts the input. ctx is the context the input was started with.
+func ReportError(ctx context.Context, err error) {
+	errCh, ok := ctx.Value(errorChannelKey{}).(chan<- error)
+	if !ok {
+		logrus.WithError(err).Error("Input stopped because of an error")
+		return
+	}
+
+	select {
+	case errCh <- err:
+	default:
+		// An error is already pending, the input gets restarted anyway
+	}
+}
+
+type shutdownKey struct{}
+
+// WithShutdown returns a context that lets the input started with it shut
+// the forwarder down by calling shutdown
+func WithShutdown(ctx context.Context, shutdown func()) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// RequestShutdown asks the forwarder to shut down gracefully, e.g. once an
+// input read all of its data. ctx is the context the input was started with.
+func RequestShutdown(ctx context.Context) {
+	shutdown, ok := ctx.Value(shutdownKey{}).(func())
+	if !ok {
+		logrus.Warn("Input requested a shutdown, but doesn't run in an engine")
+		return
+	}
+	shutdown()
+}
+
 func AddMetadata(event *internal.Event, in Plugin) {
 	hostname, _ := os.Hostname()
 	event.Metadata.InputSource = in.Name()
@@ -0,0 +1,61 @@
+// Package inputtest has helpers for the tests of the inputs
+package inputtest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+)
+
+// FreePort returns a port on 127.0.0.1 that is free for network, which is
+// either tcp or udp
+func FreePort(t *testing.T, network string) int {
+	t.Helper()
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Start initializes and starts plugin and returns the channel its events are
+// sent to. The plugin is stopped when the test finished.
+func Start(t *testing.T, plugin input.Plugin, config map[string]any) chan internal.Event {
+	t.Helper()
+	if err := plugin.Init(config); err != nil {
+		t.Fatal(err)
+	}
+
+	output := make(chan internal.Event, 10)
+	if err := plugin.Start(context.Background(), output); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { plugin.Exit() })
+	return output
+}
+
+// Receive returns the next event of output
+func Receive(t *testing.T, output <-chan internal.Event) internal.Event {
+	t.Helper()
+	select {
+	case event := <-output:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return internal.Event{}
+	}
+}
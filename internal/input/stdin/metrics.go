@@ -0,0 +1,6 @@
+package inputstdin
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var oversizedLines = metrics.NewCounterVec("forwarder_stdin_oversized_lines_total",
+	"Lines a stdin input dropped because they were larger than MaxLineSize.", "input")
@@ -0,0 +1,199 @@
+package inputstdin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the stdin input
+type Config struct {
+	Name          string          `config:"Name" default:"stdin" desc:"The name of the input instance."`
+	Tag           string          `config:"Tag" default:"stdin" desc:"A tag associated with the log events."`
+	ShutdownOnEOF bool            `config:"ShutdownOnEOF" default:"false" desc:"Shuts the forwarder down once stdin is closed. The events that were read are still delivered."`
+	MaxLineSize   schema.ByteSize `config:"MaxLineSize" default:"1MiB" min:"1" desc:"The maximum size of a line. Longer lines are dropped."`
+}
+
+// Stdin reads the lines of the standard input of the process, every line
+// becomes one event
+type Stdin struct {
+	name          string
+	tag           string
+	shutdownOnEOF bool
+	lines         *lineReader
+	linenumber    int
+	pending       *internal.Event
+	wg            sync.WaitGroup
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+var readLine = input.Delimited('\n')
+
+// stdinLines reads os.Stdin. A blocked read on stdin can't be interrupted,
+// so the reader outlives restarts of the input and is shared by all of them.
+var stdinLines = sync.OnceValue(func() *lineReader {
+	return &lineReader{reader: os.Stdin}
+})
+
+func (s *Stdin) Name() string {
+	return s.name
+}
+
+func (s *Stdin) Tag() string {
+	return s.tag
+}
+
+func (s *Stdin) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+
+	s.name = cfg.Name
+	s.tag = cfg.Tag
+	s.shutdownOnEOF = cfg.ShutdownOnEOF
+	if s.lines == nil {
+		s.lines = stdinLines()
+	}
+	// The reader is shared, the size of the last loaded config applies
+	s.lines.maxSize.Store(int64(cfg.MaxLineSize))
+	return nil
+}
+
+func (s *Stdin) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	s.ctx, s.cancel = context.WithCancel(parentCtx)
+	lines := s.lines.start()
+
+	logrus.WithField("shutdown_on_eof", s.shutdownOnEOF).Info("Starting stdin input")
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		// An event that couldn't be passed on before the last Exit
+		if s.pending != nil && !s.send(output, *s.pending) {
+			return
+		}
+		for {
+			var line readResult
+			var ok bool
+			select {
+			case line, ok = <-lines:
+			case <-s.ctx.Done():
+				return
+			}
+			if !ok {
+				s.closed(parentCtx)
+				return
+			}
+			if errors.Is(line.err, input.ErrRecordTooLarge) {
+				oversizedLines.WithLabelValues(s.name).Inc()
+				logrus.Warn("Line on stdin larger than MaxLineSize, dropping it")
+				continue
+			}
+			if line.err != nil {
+				logrus.WithError(line.err).Error("Coundnt read from stdin")
+				continue
+			}
+
+			s.linenumber++
+			event := internal.Event{
+				Timestamp: time.Now(),
+				RawData:   string(line.line),
+				Metadata: internal.Metadata{
+					Source:  "stdin",
+					LineNum: s.linenumber,
+				},
+			}
+			input.AddMetadata(&event, s)
+			if !s.send(output, event) {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// send passes event on. Stdin is read as fast as the pipeline accepts the
+// events, the writing process waits in the meantime. An event that can't be
+// passed on before Exit is kept for the next start, as stdin can't be read
+// again.
+func (s *Stdin) send(output chan<- internal.Event, event internal.Event) bool {
+	select {
+	case output <- event:
+		s.pending = nil
+		return true
+	case <-s.ctx.Done():
+		s.pending = &event
+		return false
+	}
+}
+
+// closed handles the end of stdin
+func (s *Stdin) closed(parentCtx context.Context) {
+	if s.shutdownOnEOF {
+		logrus.Info("stdin closed, shutting down")
+		input.RequestShutdown(parentCtx)
+		return
+	}
+	logrus.Info("stdin closed, no more events will be read")
+}
+
+func (s *Stdin) Exit() error {
+	logrus.Info("Stopping stdin input")
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// lineReader reads the lines of reader into a channel, which is closed at
+// the end of the data
+type lineReader struct {
+	reader  io.Reader
+	maxSize atomic.Int64
+	once    sync.Once
+	lines   chan readResult
+}
+
+// readResult is a line or the error reading it
+type readResult struct {
+	line []byte
+	err  error
+}
+
+// start starts reading on the first call and returns the lines
+func (l *lineReader) start() <-chan readResult {
+	l.once.Do(func() {
+		l.lines = make(chan readResult)
+		go l.read()
+	})
+	return l.lines
+}
+
+func (l *lineReader) read() {
+	defer close(l.lines)
+	reader := bufio.NewReader(l.reader)
+	for {
+		line, err := readLine(reader, int(l.maxSize.Load()))
+		if err == io.EOF {
+			return
+		}
+		l.lines <- readResult{line: line, err: err}
+		if err != nil && !errors.Is(err, input.ErrRecordTooLarge) {
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package inputstdin
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStdin returns an input that reads data instead of os.Stdin
+func newStdin(t *testing.T, data string, config map[string]any) *Stdin {
+	t.Helper()
+	s := &Stdin{lines: &lineReader{reader: strings.NewReader(data)}}
+	require.NoError(t, s.Init(config))
+	return s
+}
+
+func TestStdin_ReadsLines(t *testing.T) {
+	s := newStdin(t, "first\r\n"+strings.Repeat("x", 20)+"\nlast", map[string]any{"Tag": "shell", "MaxLineSize": 10})
+	before := oversizedLines.WithLabelValues("stdin").Value()
+
+	output := make(chan internal.Event, 10)
+	require.NoError(t, s.Start(context.Background(), output))
+	defer s.Exit()
+
+	first := <-output
+	assert.Equal(t, "first", first.RawData)
+	assert.Equal(t, "shell", first.Metadata.Tag)
+	assert.Equal(t, "stdin", first.Metadata.Source)
+	assert.Equal(t, 1, first.Metadata.LineNum)
+
+	last := <-output
+	assert.Equal(t, "last", last.RawData)
+	assert.Equal(t, 2, last.Metadata.LineNum)
+	assert.Equal(t, before+1, oversizedLines.WithLabelValues("stdin").Value())
+}
+
+func TestStdin_ShutdownOnEOF(t *testing.T) {
+	s := newStdin(t, "only line\n", map[string]any{"ShutdownOnEOF": true})
+
+	shutdown := make(chan struct{})
+	ctx := input.WithShutdown(context.Background(), func() { close(shutdown) })
+	output := make(chan internal.Event, 10)
+	require.NoError(t, s.Start(ctx, output))
+	defer s.Exit()
+
+	select {
+	case <-shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown was not requested at EOF")
+	}
+	// The events read before EOF are passed on first
+	assert.Equal(t, "only line", (<-output).RawData)
+}
+
+func TestStdin_Restart(t *testing.T) {
+	s := newStdin(t, "one\ntwo\n", nil)
+
+	// The lines are kept for the next start while the input is stopped
+	blocked := make(chan internal.Event)
+	require.NoError(t, s.Start(context.Background(), blocked))
+	require.NoError(t, s.Exit())
+
+	output := make(chan internal.Event, 10)
+	require.NoError(t, s.Start(context.Background(), output))
+	defer s.Exit()
+	assert.Equal(t, "one", (<-output).RawData)
+	assert.Equal(t, "two", (<-output).RawData)
+}
@@ -0,0 +1,12 @@
+package inputsyslog
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	droppedMessages = metrics.NewCounterVec("forwarder_syslog_dropped_messages_total",
+		"Messages a syslog input dropped, by reason.", "input", "reason")
+	parseErrors = metrics.NewCounterVec("forwarder_syslog_parse_errors_total",
+		"Messages a syslog input passed on unparsed because they aren't valid syslog.", "input")
+	rejectedConnections = metrics.NewCounterVec("forwarder_syslog_rejected_connections_total",
+		"Connections a syslog input rejected because of the connection limit.", "input")
+)
@@ -0,0 +1,283 @@
+package inputsyslog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nilValue = "-"
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// message is a parsed syslog message
+type message struct {
+	priority       int
+	version        int // 0 for RFC 3164 messages
+	timestamp      time.Time
+	hostname       string
+	appName        string
+	procID         string
+	msgID          string
+	structuredData map[string]map[string]string
+	text           string
+}
+
+// fields returns the message as the ParsedData of an event. Fields the
+// message doesn't have are left out.
+func (m *message) fields() map[string]any {
+	fields := map[string]any{
+		"priority": m.priority,
+		"facility": facilities[m.priority/8],
+		"severity": severities[m.priority%8],
+		"message":  m.text,
+	}
+	if m.version > 0 {
+		fields["version"] = m.version
+	}
+	optional := map[string]string{
+		"hostname": m.hostname,
+		"appname":  m.appName,
+		"procid":   m.procID,
+		"msgid":    m.msgID,
+	}
+	for key, value := range optional {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if len(m.structuredData) > 0 {
+		structuredData := make(map[string]any, len(m.structuredData))
+		for id, params := range m.structuredData {
+			element := make(map[string]any, len(params))
+			for name, value := range params {
+				element[name] = value
+			}
+			structuredData[id] = element
+		}
+		fields["structured_data"] = structuredData
+	}
+	return fields
+}
+
+// parse parses a syslog message in the given format, auto detects RFC 5424
+// messages by their version. now is used to complete the year of RFC 3164
+// timestamps, loc is their time zone.
+func parse(line string, format string, now time.Time, loc *time.Location) (*message, error) {
+	priority, rest, err := parsePriority(line)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatRFC5424:
+		return parseRFC5424(priority, rest)
+	case FormatRFC3164:
+		return parseRFC3164(priority, rest, now, loc), nil
+	}
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && (rest[1] == ' ' || (rest[1] >= '0' && rest[1] <= '9')) {
+		if msg, err := parseRFC5424(priority, rest); err == nil {
+			return msg, nil
+		}
+	}
+	return parseRFC3164(priority, rest, now, loc), nil
+}
+
+func parsePriority(line string) (int, string, error) {
+	if !strings.HasPrefix(line, "<") {
+		return 0, "", errors.New("cant find the priority of the syslog message")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", errors.New("cant find the priority of the syslog message")
+	}
+	priority, err := strconv.Atoi(line[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, "", fmt.Errorf("invalid syslog priority '%s'", line[1:end])
+	}
+	return priority, line[end+1:], nil
+}
+
+// parseRFC5424 parses the part of a RFC 5424 message after the priority
+func parseRFC5424(priority int, rest string) (*message, error) {
+	msg := &message{priority: priority}
+
+	// VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID, each followed by a space
+	var header [6]string
+	for i := range header {
+		var ok bool
+		header[i], rest, ok = strings.Cut(rest, " ")
+		if !ok || header[i] == "" {
+			return nil, errors.New("incomplete RFC 5424 header")
+		}
+	}
+
+	version, err := strconv.Atoi(header[0])
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("invalid RFC 5424 version '%s'", header[0])
+	}
+	msg.version = version
+
+	if header[1] != nilValue {
+		if msg.timestamp, err = time.Parse(time.RFC3339Nano, header[1]); err != nil {
+			return nil, fmt.Errorf("invalid RFC 5424 timestamp '%s'", header[1])
+		}
+	}
+	msg.hostname = nilToEmpty(header[2])
+	msg.appName = nilToEmpty(header[3])
+	msg.procID = nilToEmpty(header[4])
+	msg.msgID = nilToEmpty(header[5])
+
+	if msg.structuredData, rest, err = parseStructuredData(rest); err != nil {
+		return nil, err
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	msg.text = strings.TrimPrefix(rest, "\ufeff")
+	return msg, nil
+}
+
+// parseStructuredData parses the STRUCTURED-DATA of a RFC 5424 message and
+// returns the rest of the message
+func parseStructuredData(rest string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(rest, nilValue) {
+		return nil, rest[1:], nil
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return nil, "", errors.New("invalid RFC 5424 structured data")
+	}
+
+	data := map[string]map[string]string{}
+	for strings.HasPrefix(rest, "[") {
+		end := strings.IndexAny(rest, " ]")
+		if end < 2 {
+			return nil, "", errors.New("invalid RFC 5424 structured data")
+		}
+		id := rest[1:end]
+		params := map[string]string{}
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, " ") {
+			rest = rest[1:]
+			name, value, ok := strings.Cut(rest, "=\"")
+			if !ok || name == "" || strings.ContainsAny(name, " ]") {
+				return nil, "", fmt.Errorf("invalid parameter in structured data element '%s'", id)
+			}
+			value, rest, ok = unescapeParamValue(value)
+			if !ok {
+				return nil, "", fmt.Errorf("unterminated parameter value in structured data element '%s'", id)
+			}
+			params[name] = value
+		}
+
+		if !strings.HasPrefix(rest, "]") {
+			return nil, "", fmt.Errorf("unterminated structured data element '%s'", id)
+		}
+		rest = rest[1:]
+		data[id] = params
+	}
+	return data, rest, nil
+}
+
+// unescapeParamValue reads a parameter value up to the closing quote and
+// returns it with the rest after the quote
+func unescapeParamValue(s string) (string, string, bool) {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			// Only ", \ and ] are escaped, other backslashes are kept
+			if i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) != -1 {
+				i++
+			}
+		case '"':
+			return builder.String(), s[i+1:], true
+		}
+		builder.WriteByte(s[i])
+	}
+	return "", "", false
+}
+
+// rfc3164Layouts are the timestamps found at the start of RFC 3164 messages.
+// Many senders use RFC 3339 timestamps instead of the BSD format.
+var rfc3164Layouts = []string{time.StampMicro, time.Stamp, time.RFC3339Nano}
+
+// parseRFC3164 parses the part of a RFC 3164 message after the priority. The
+// format is loosely defined, anything that can't be recognized ends up in
+// the message text.
+func parseRFC3164(priority int, rest string, now time.Time, loc *time.Location) *message {
+	msg := &message{priority: priority}
+
+	if timestamp, remaining, ok := parseBSDTimestamp(rest, now, loc); ok {
+		msg.timestamp = timestamp
+		rest = remaining
+
+		// The hostname follows the timestamp, unless the sender left it out
+		// and the tag follows directly
+		if host, remaining, ok := strings.Cut(rest, " "); ok && !isTag(host) {
+			msg.hostname = host
+			rest = remaining
+		}
+	}
+
+	if tag, remaining, ok := strings.Cut(rest, " "); ok && isTag(tag) {
+		tag = strings.TrimSuffix(tag, ":")
+		if name, pid, ok := strings.Cut(tag, "["); ok {
+			msg.appName = name
+			msg.procID = strings.TrimSuffix(pid, "]")
+		} else {
+			msg.appName = tag
+		}
+		rest = remaining
+	}
+
+	msg.text = rest
+	return msg
+}
+
+func parseBSDTimestamp(rest string, now time.Time, loc *time.Location) (time.Time, string, bool) {
+	for _, layout := range rfc3164Layouts {
+		var candidate string
+		if layout == time.RFC3339Nano {
+			candidate, _, _ = strings.Cut(rest, " ")
+		} else if len(rest) >= len(layout) {
+			candidate = rest[:len(layout)]
+		} else {
+			continue
+		}
+
+		timestamp, err := time.ParseInLocation(layout, candidate, loc)
+		if err != nil {
+			continue
+		}
+		if layout != time.RFC3339Nano {
+			// The BSD format has no year, a timestamp far in the future
+			// belongs to the last year
+			timestamp = timestamp.AddDate(now.In(loc).Year(), 0, 0)
+			if timestamp.After(now.AddDate(0, 1, 0)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+		}
+		return timestamp, strings.TrimPrefix(rest[len(candidate):], " "), true
+	}
+	return time.Time{}, rest, false
+}
+
+// isTag reports whether a word of a RFC 3164 message is the tag, e.g. sshd: or sshd[42]:
+func isTag(word string) bool {
+	return strings.HasSuffix(word, ":") || strings.HasSuffix(word, "]")
+}
+
+func nilToEmpty(value string) string {
+	if value == nilValue {
+		return ""
+	}
+	return value
+}
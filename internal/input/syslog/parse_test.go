@@ -0,0 +1,171 @@
+package inputsyslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		line       string
+		format     string
+		wantFields map[string]any
+		wantTime   time.Time
+	}{
+		{
+			name:   "rfc5424",
+			line:   `<165>1 2024-03-10T11:59:58.123Z mymachine.example.com evntslog 42 ID47 [exampleSDID@32473 iut="3" eventSource="Application"][meta sequence="1"] An application event`,
+			format: FormatAuto,
+			wantFields: map[string]any{
+				"priority": 165,
+				"facility": "local4",
+				"severity": "notice",
+				"version":  1,
+				"hostname": "mymachine.example.com",
+				"appname":  "evntslog",
+				"procid":   "42",
+				"msgid":    "ID47",
+				"structured_data": map[string]any{
+					"exampleSDID@32473": map[string]any{"iut": "3", "eventSource": "Application"},
+					"meta":              map[string]any{"sequence": "1"},
+				},
+				"message": "An application event",
+			},
+			wantTime: time.Date(2024, 3, 10, 11, 59, 58, 123000000, time.UTC),
+		},
+		{
+			name:   "rfc5424 with nil values and bom",
+			line:   "<34>1 - - su - - - \ufeff'su root' failed",
+			format: FormatRFC5424,
+			wantFields: map[string]any{
+				"priority": 34,
+				"facility": "auth",
+				"severity": "crit",
+				"version":  1,
+				"appname":  "su",
+				"message":  "'su root' failed",
+			},
+		},
+		{
+			name:   "rfc5424 escaped parameter values",
+			line:   `<14>1 - host app - - [x a="say \"hi\"" b="c:\\d" e="[1\]"]`,
+			format: FormatAuto,
+			wantFields: map[string]any{
+				"priority": 14,
+				"facility": "user",
+				"severity": "info",
+				"version":  1,
+				"hostname": "host",
+				"appname":  "app",
+				"structured_data": map[string]any{
+					"x": map[string]any{"a": `say "hi"`, "b": `c:\d`, "e": "[1]"},
+				},
+				"message": "",
+			},
+		},
+		{
+			name:   "rfc3164",
+			line:   "<38>Mar  9 22:14:15 mymachine sshd[1234]: Accepted publickey for root",
+			format: FormatAuto,
+			wantFields: map[string]any{
+				"priority": 38,
+				"facility": "auth",
+				"severity": "info",
+				"hostname": "mymachine",
+				"appname":  "sshd",
+				"procid":   "1234",
+				"message":  "Accepted publickey for root",
+			},
+			wantTime: time.Date(2024, 3, 9, 22, 14, 15, 0, time.UTC),
+		},
+		{
+			name:   "rfc3164 from last year",
+			line:   "<13>Dec 31 23:59:59 host cron: job done",
+			format: FormatRFC3164,
+			wantFields: map[string]any{
+				"priority": 13,
+				"facility": "user",
+				"severity": "notice",
+				"hostname": "host",
+				"appname":  "cron",
+				"message":  "job done",
+			},
+			wantTime: time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			name:   "rfc3164 without hostname",
+			line:   "<13>Mar 10 11:00:00 kernel: eth0 up",
+			format: FormatAuto,
+			wantFields: map[string]any{
+				"priority": 13,
+				"facility": "user",
+				"severity": "notice",
+				"appname":  "kernel",
+				"message":  "eth0 up",
+			},
+			wantTime: time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "rfc3164 with rfc3339 timestamp",
+			line:   "<30>2024-03-10T10:00:00+01:00 web nginx: started",
+			format: FormatAuto,
+			wantFields: map[string]any{
+				"priority": 30,
+				"facility": "daemon",
+				"severity": "info",
+				"hostname": "web",
+				"appname":  "nginx",
+				"message":  "started",
+			},
+			wantTime: time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "rfc3164 only message",
+			line:   "<0>something happened",
+			format: FormatAuto,
+			wantFields: map[string]any{
+				"priority": 0,
+				"facility": "kern",
+				"severity": "emerg",
+				"message":  "something happened",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := parse(tt.line, tt.format, now, time.UTC)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFields, msg.fields())
+			assert.True(t, tt.wantTime.Equal(msg.timestamp), "timestamp %s", msg.timestamp)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		line    string
+		format  string
+		wantErr string
+	}{
+		{"no priority", FormatAuto, "cant find the priority of the syslog message"},
+		{"<192>1 - - - - - -", FormatAuto, "invalid syslog priority '192'"},
+		{"<13>Mar 10 11:00:00 host app: msg", FormatRFC5424, "incomplete RFC 5424 header"},
+		{"<13>1 yesterday - - - - -", FormatRFC5424, "invalid RFC 5424 timestamp 'yesterday'"},
+		{`<13>1 - - - - - [id a="b"`, FormatRFC5424, "unterminated structured data element 'id'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := parse(tt.line, tt.format, now, time.UTC)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
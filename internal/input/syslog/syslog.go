@@ -0,0 +1,336 @@
+package inputsyslog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ModeUDP  = "udp"
+	ModeTCP  = "tcp"
+	ModeUnix = "unix"
+
+	FormatAuto    = "auto"
+	FormatRFC3164 = "rfc3164"
+	FormatRFC5424 = "rfc5424"
+)
+
+// Config is the configuration of the syslog input
+type Config struct {
+	Name           string          `config:"Name" default:"syslog" desc:"The name of the input instance."`
+	Tag            string          `config:"Tag" default:"syslog" desc:"A tag associated with the log events."`
+	Mode           string          `config:"Mode" default:"udp" enum:"udp,tcp,unix" desc:"The transport the syslog messages are received with. unix listens on a unix datagram socket."`
+	ListenAddr     string          `config:"ListenAddr" default:"0.0.0.0" desc:"The address on which the syslog input should listen on in the udp and tcp mode."`
+	Port           int             `config:"Port" default:"514" min:"1" max:"65535" desc:"The port on which the syslog input should listen on in the udp and tcp mode."`
+	Path           string          `config:"Path" desc:"The path of the unix socket, required in the unix mode. An existing socket file is replaced."`
+	Format         string          `config:"Format" default:"auto" enum:"auto,rfc3164,rfc5424" desc:"The format of the messages. auto detects RFC 5424 messages by their version and treats all others as RFC 3164."`
+	MaxMessageSize schema.ByteSize `config:"MaxMessageSize" default:"64KiB" min:"1" desc:"The maximum size of a message. Longer messages are dropped."`
+	Timeout        time.Duration   `config:"Timeout" default:"10m" desc:"Tcp connections without data for this long are closed."`
+	MaxConnections int             `config:"MaxConnections" default:"50" min:"1" desc:"The maximum number of concurrent tcp connections, further connections are rejected."`
+	Timezone       string          `config:"Timezone" default:"Local" desc:"The time zone of RFC 3164 timestamps, which have none, e.g. UTC or Europe/Berlin."`
+}
+
+type Syslog struct {
+	name           string
+	tag            string
+	mode           string
+	addr           string
+	path           string
+	format         string
+	maxMessageSize int
+	timeout        time.Duration
+	maxConnections int
+	location       *time.Location
+	listener       net.Listener
+	packetConn     net.PacketConn
+	conns          map[net.Conn]struct{}
+	connsMu        sync.Mutex
+	wg             sync.WaitGroup
+	ctx            context.Context
+	cancel         context.CancelFunc
+	output         chan<- internal.Event
+}
+
+func (s *Syslog) Name() string {
+	return s.name
+}
+
+func (s *Syslog) Tag() string {
+	return s.tag
+}
+
+func (s *Syslog) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+	if cfg.Mode == ModeUnix && cfg.Path == "" {
+		return errors.New("Path: missing required field for the unix mode")
+	}
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("Timezone: unknown time zone '%s'", cfg.Timezone)
+	}
+
+	s.name = cfg.Name
+	s.tag = cfg.Tag
+	s.mode = cfg.Mode
+	s.addr = fmt.Sprintf("%s:%d", cfg.ListenAddr, cfg.Port)
+	s.path = cfg.Path
+	s.format = cfg.Format
+	s.maxMessageSize = int(cfg.MaxMessageSize)
+	s.timeout = cfg.Timeout
+	s.maxConnections = cfg.MaxConnections
+	s.location = location
+
+	return nil
+}
+
+func (s *Syslog) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	s.ctx, s.cancel = context.WithCancel(parentCtx)
+	s.output = output
+	s.conns = map[net.Conn]struct{}{}
+
+	var err error
+	switch s.mode {
+	case ModeTCP:
+		if s.listener, err = net.Listen("tcp", s.addr); err != nil {
+			return fmt.Errorf("couldn't start syslog input: %w", err)
+		}
+		s.wg.Add(1)
+		go s.accept(parentCtx)
+	case ModeUDP:
+		if s.packetConn, err = net.ListenPacket("udp", s.addr); err != nil {
+			return fmt.Errorf("couldn't start syslog input: %w", err)
+		}
+		s.wg.Add(1)
+		go s.readPackets(parentCtx)
+	case ModeUnix:
+		// A socket file left behind by a crash would fail the bind
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("couldn't remove old syslog socket: %w", err)
+		}
+		if s.packetConn, err = net.ListenPacket("unixgram", s.path); err != nil {
+			return fmt.Errorf("couldn't start syslog input: %w", err)
+		}
+		s.wg.Add(1)
+		go s.readPackets(parentCtx)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"mode":   s.mode,
+		"addr":   s.listenAddr(),
+		"format": s.format,
+	}).Info("Starting syslog input")
+	return nil
+}
+
+func (s *Syslog) listenAddr() string {
+	if s.mode == ModeUnix {
+		return s.path
+	}
+	return s.addr
+}
+
+// readPackets handles the udp and unix mode, where every datagram is one message
+func (s *Syslog) readPackets(parentCtx context.Context) {
+	defer s.wg.Done()
+
+	// One extra byte tells messages that were cut off by the buffer
+	buffer := make([]byte, s.maxMessageSize+1)
+	for {
+		n, addr, err := s.packetConn.ReadFrom(buffer)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			input.ReportError(parentCtx, fmt.Errorf("syslog socket closed: %w", err))
+			return
+		}
+		if n > s.maxMessageSize {
+			droppedMessages.WithLabelValues(s.name, "too_large").Inc()
+			continue
+		}
+
+		source := s.path
+		if addr != nil && addr.String() != "" {
+			source = addr.String()
+		}
+		s.emit(string(buffer[:n]), source, 0)
+	}
+}
+
+func (s *Syslog) accept(parentCtx context.Context) {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				input.ReportError(parentCtx, fmt.Errorf("syslog listener closed: %w", err))
+				return
+			}
+			logrus.WithError(err).Error("could not accept syslog connection")
+			if !input.WaitAcceptRetry(s.ctx) {
+				return
+			}
+			continue
+		}
+
+		s.connsMu.Lock()
+		if len(s.conns) >= s.maxConnections {
+			s.connsMu.Unlock()
+			rejectedConnections.WithLabelValues(s.name).Inc()
+			logrus.WithFields(logrus.Fields{
+				"remote_addr":     conn.RemoteAddr().String(),
+				"max_connections": s.maxConnections,
+			}).Warn("Maximum syslog connection limit reached, rejecting connection")
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConnection(conn)
+	}
+}
+
+// handleConnection reads the messages of a tcp connection. Every message is
+// either octet counted or ends with a newline, as described in RFC 6587.
+func (s *Syslog) handleConnection(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	remoteAddr := conn.RemoteAddr().String()
+	logrus.WithField("remote_addr", remoteAddr).Debug("New syslog connection established")
+
+	reader := bufio.NewReader(input.NewIdleTimeoutReader(conn, s.timeout))
+	lineNum := 0
+	for {
+		frame, err := readFrame(reader, s.maxMessageSize)
+		if errors.Is(err, input.ErrRecordTooLarge) {
+			droppedMessages.WithLabelValues(s.name, "too_large").Inc()
+			continue
+		}
+		if err != nil {
+			if err != io.EOF && s.ctx.Err() == nil {
+				logrus.WithField("remote_addr", remoteAddr).WithError(err).Debug("Closing syslog connection")
+			}
+			return
+		}
+		if frame == "" {
+			continue
+		}
+		lineNum++
+		s.emit(frame, remoteAddr, lineNum)
+	}
+}
+
+// emit parses a message and hands it to the engine. Messages that can't be
+// parsed are passed on unparsed.
+func (s *Syslog) emit(line, source string, lineNum int) {
+	now := time.Now()
+	event := internal.Event{
+		Timestamp: now,
+		RawData:   line,
+		Metadata: internal.Metadata{
+			Source:  source,
+			LineNum: lineNum,
+		},
+	}
+	input.AddMetadata(&event, s)
+	event.Metadata.Host = input.SenderHost(source)
+
+	msg, err := parse(line, s.format, now, s.location)
+	if err != nil {
+		parseErrors.WithLabelValues(s.name).Inc()
+		logrus.WithField("source", source).WithError(err).Debug("Coundnt parse syslog message")
+	} else {
+		event.ParsedData = msg.fields()
+		if !msg.timestamp.IsZero() {
+			event.Timestamp = msg.timestamp
+		}
+		if msg.hostname != "" {
+			event.Metadata.Host = msg.hostname
+		}
+	}
+
+	select {
+	case s.output <- event:
+	case <-s.ctx.Done():
+	default:
+		droppedMessages.WithLabelValues(s.name, "pipeline_full").Inc()
+		logrus.WithField("source", source).Warn("syslog event channel full, dropping message")
+	}
+}
+
+func (s *Syslog) Exit() error {
+	logrus.WithField("name", s.name).Info("Stopping syslog input")
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	if s.listener != nil {
+		if err := s.listener.Close(); err != nil {
+			logrus.WithError(err).Error("could not close syslog listener")
+		}
+	}
+	if s.packetConn != nil {
+		if err := s.packetConn.Close(); err != nil {
+			logrus.WithError(err).Error("could not close syslog socket")
+		}
+	}
+
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsMu.Unlock()
+
+	s.wg.Wait()
+	s.listener = nil
+	s.packetConn = nil
+	if s.mode == ModeUnix {
+		os.Remove(s.path)
+	}
+	return nil
+}
+
+var readLine = input.Delimited('\n')
+
+// readFrame reads the next message of a tcp stream. Octet counted messages
+// start with their length, all others end with a newline.
+func readFrame(reader *bufio.Reader, maxSize int) (string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	var frame []byte
+	if first[0] >= '1' && first[0] <= '9' {
+		frame, err = input.ReadOctetCounted(reader, maxSize)
+	} else {
+		frame, err = readLine(reader, maxSize)
+	}
+	return string(frame), err
+}
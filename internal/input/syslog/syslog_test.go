@@ -0,0 +1,123 @@
+package inputsyslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslog_Init(t *testing.T) {
+	s := &Syslog{}
+	require.NoError(t, s.Init(map[string]any{}))
+	assert.Equal(t, "syslog", s.name)
+	assert.Equal(t, ModeUDP, s.mode)
+	assert.Equal(t, "0.0.0.0:514", s.addr)
+	assert.Equal(t, 64<<10, s.maxMessageSize)
+
+	assert.EqualError(t, s.Init(map[string]any{"Mode": "unix"}), "Path: missing required field for the unix mode")
+	assert.EqualError(t, s.Init(map[string]any{"Timezone": "Mars/Olympus"}), "Timezone: unknown time zone 'Mars/Olympus'")
+	assert.Error(t, s.Init(map[string]any{"Mode": "sctp"}))
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	output := inputtest.Start(t, &Syslog{}, map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "Tag": "net"})
+
+	conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("<165>1 2024-03-10T11:59:58Z router01 ifmgr - - - link down"))
+	require.NoError(t, err)
+
+	event := inputtest.Receive(t, output)
+	assert.Equal(t, "net", event.Metadata.Tag)
+	assert.Equal(t, "router01", event.Metadata.Host)
+	assert.Equal(t, conn.LocalAddr().String(), event.Metadata.Source)
+	assert.Equal(t, "link down", event.ParsedData["message"])
+	assert.Equal(t, time.Date(2024, 3, 10, 11, 59, 58, 0, time.UTC), event.Timestamp.UTC())
+}
+
+func TestSyslog_TCPFraming(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	output := inputtest.Start(t, &Syslog{}, map[string]any{"Mode": "tcp", "ListenAddr": "127.0.0.1", "Port": port, "MaxMessageSize": 100})
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	octetCounted := "<13>1 - host app - - - line\nwith newline"
+	_, err = fmt.Fprintf(conn, "<13>first message\r\n%d %s<13>%s\n<13>third", len(octetCounted), octetCounted, strings.Repeat("x", 200))
+	require.NoError(t, err)
+	conn.(*net.TCPConn).CloseWrite()
+
+	assert.Equal(t, "first message", inputtest.Receive(t, output).ParsedData["message"])
+	event := inputtest.Receive(t, output)
+	assert.Equal(t, "line\nwith newline", event.ParsedData["message"])
+	assert.Equal(t, "host", event.Metadata.Host)
+	// The message longer than MaxMessageSize is dropped
+	event = inputtest.Receive(t, output)
+	assert.Equal(t, "third", event.ParsedData["message"])
+	assert.Equal(t, "127.0.0.1", event.Metadata.Host)
+	assert.Equal(t, 3, event.Metadata.LineNum)
+}
+
+func TestSyslog_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	output := inputtest.Start(t, &Syslog{}, map[string]any{"Mode": "unix", "Path": path})
+
+	conn, err := net.Dial("unixgram", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("not syslog at all"))
+	require.NoError(t, err)
+
+	event := inputtest.Receive(t, output)
+	assert.Equal(t, "not syslog at all", event.RawData)
+	assert.Nil(t, event.ParsedData)
+	assert.Equal(t, path, event.Metadata.Source)
+}
+
+func TestSyslog_MaxConnections(t *testing.T) {
+	port := inputtest.FreePort(t, "tcp")
+	output := inputtest.Start(t, &Syslog{}, map[string]any{"Mode": "tcp", "ListenAddr": "127.0.0.1", "Port": port, "MaxConnections": 1})
+
+	first, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = first.Write([]byte("<13>first\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "first", inputtest.Receive(t, output).ParsedData["message"])
+
+	// The second connection is closed right away
+	second, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadFrame(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("5 <1>ab"+"<1>"+strings.Repeat("y", 40)+"\n<1>c"), 16)
+
+	frame, err := readFrame(reader, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "<1>ab", frame)
+
+	_, err = readFrame(reader, 10)
+	assert.ErrorIs(t, err, input.ErrRecordTooLarge)
+
+	frame, err = readFrame(reader, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "<1>c", frame)
+}
@@ -0,0 +1,110 @@
+package inputtail
+
+import (
+	"slices"
+	"sync"
+)
+
+// offsetTracker follows the lines of a file that were sent into the
+// pipeline and commits the highest offset up to which every line was
+// acknowledged. Lines are acknowledged out of order, so a committed offset
+// never skips a line that is still on its way to the outputs.
+type offsetTracker struct {
+	mu      sync.Mutex
+	path    string
+	inode   uint64
+	pending []*pendingLine
+	closed  bool
+	// held is set once a line wasn't delivered, the offset isn't committed
+	// past it anymore
+	held   bool
+	commit func(state fileState)
+}
+
+type pendingLine struct {
+	offset int64 // Offset right after the line
+	line   int
+	acked  bool
+}
+
+func newOffsetTracker(path string, inode uint64, commit func(state fileState)) *offsetTracker {
+	return &offsetTracker{
+		path:   path,
+		inode:  inode,
+		commit: commit,
+	}
+}
+
+// track registers a line ending at offset and returns the functions that
+// acknowledge it and that report it wasn't delivered
+func (o *offsetTracker) track(offset int64, line int) (ack, nack func()) {
+	p := &pendingLine{offset: offset, line: line}
+
+	o.mu.Lock()
+	if !o.held {
+		o.pending = append(o.pending, p)
+	}
+	o.mu.Unlock()
+
+	return func() { o.ack(p) }, func() { o.nack(p) }
+}
+
+// skip registers a line that is not sent into the pipeline, e.g. an empty one
+func (o *offsetTracker) skip(offset int64, line int) {
+	ack, _ := o.track(offset, line)
+	ack()
+}
+
+func (o *offsetTracker) ack(p *pendingLine) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed || p.acked {
+		return
+	}
+	p.acked = true
+
+	n := 0
+	for n < len(o.pending) && o.pending[n].acked {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+
+	last := o.pending[n-1]
+	o.pending = slices.Delete(o.pending, 0, n)
+	o.commit(fileState{
+		Path:         o.path,
+		Offset:       last.offset,
+		LastReadLine: last.line,
+		InodeNumber:  o.inode,
+	})
+}
+
+// nack holds the committed offset before a line that wasn't delivered, so
+// the line is read again after a restart. The lines after it are not
+// tracked anymore, only the ones before it are still committed.
+func (o *offsetTracker) nack(p *pendingLine) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed || p.acked {
+		return
+	}
+	i := slices.Index(o.pending, p)
+	if i < 0 {
+		return
+	}
+	o.pending = o.pending[:i]
+	o.held = true
+}
+
+// close stops committing, acknowledgements for a deleted or truncated
+// file must not overwrite the state of its successor
+func (o *offsetTracker) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.pending = nil
+}
@@ -0,0 +1,18 @@
+package inputtail
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var fileLag = metrics.NewGaugeVec("forwarder_tail_file_lag_bytes",
+	"Bytes of a tailed file that were not read yet (file size minus offset).", "input", "path")
+
+// updateLag sets the lag of a file from its current size and read offset
+func (t *Tail) updateLag(path string, size int64) {
+	t.mu.Lock()
+	var offset int64
+	if state, exists := t.state[path]; exists {
+		offset = state.Offset
+	}
+	t.mu.Unlock()
+
+	fileLag.WithLabelValues(t.name, path).Set(float64(max(size-offset, 0)))
+}
@@ -3,7 +3,6 @@ package inputtail
 import (
 	"bufio"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,10 +14,20 @@ import (
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/input"
-	"github.com/MuchTitan/go-log-forwarder/internal/util"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/sirupsen/logrus"
 )
 
+// Config is the configuration of the tail input
+type Config struct {
+	Name             string `config:"Name" default:"tail" desc:"The name of the input instance."`
+	Glob             string `config:"Glob,required" desc:"The file path pattern to watch, e.g. ./logs/*.log."`
+	Tag              string `config:"Tag" default:"tail" desc:"A tag associated with the log events."`
+	CleanUpThreshold int    `config:"CleanUpThreshold" default:"3" min:"0" desc:"Number of old database entries to keep."`
+	EnableDB         bool   `config:"EnableDB" desc:"If true, enables state persistence in an SQLite database."`
+	DBFile           string `config:"DBFile" desc:"Path to the SQLite database file for storing file states. If not provided, a default is generated based on the glob pattern."`
+}
+
 type Tail struct {
 	name               string
 	glob               string
@@ -26,15 +35,18 @@ type Tail struct {
 	tag                string
 	cleanUpThreshold   int
 	fileEventCh        chan fileEvent
-	fileStateCh        chan fileState
 	debounceTimers     map[string]*time.Timer
 	state              map[string]*fileState
+	trackers           map[string]*offsetTracker
+	commits            map[string]fileState
 	fileStats          map[string]fileInfo
 	wg                 sync.WaitGroup
 	mu                 sync.Mutex
+	commitMu           sync.Mutex
 	ctx                context.Context
 	cancel             context.CancelFunc
 	stateSavingEnabled bool
+	repositoryClosed   bool
 	repository         TailRepository
 }
 
@@ -47,38 +59,20 @@ func (t *Tail) Tag() string {
 }
 
 func (t *Tail) Init(config map[string]any) error {
-	t.glob = util.MustString(config["Glob"])
-	if t.glob == "" {
-		return fmt.Errorf("no glob provided for tail input")
-	}
-
-	t.name = util.MustString(config["Name"])
-	if t.name == "" {
-		t.name = "tail"
-	}
-
-	t.tag = util.MustString(config["Tag"])
-	if t.tag == "" {
-		t.tag = "tail"
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	if tmpThreshold, ok := config["CleanUpThreshold"].(int); ok {
-		t.cleanUpThreshold = tmpThreshold
-	} else {
-		t.cleanUpThreshold = 3
-	}
-
-	if colors, exists := config["EnableDB"]; exists {
-		var ok bool
-		if t.stateSavingEnabled, ok = colors.(bool); !ok {
-			return errors.New("cant convert EnableDB parameter to bool")
-		}
-	}
+	t.glob = cfg.Glob
+	t.name = cfg.Name
+	t.tag = cfg.Tag
+	t.cleanUpThreshold = cfg.CleanUpThreshold
+	t.stateSavingEnabled = cfg.EnableDB
 
 	if t.stateSavingEnabled {
-		if dbFile, ok := config["DBFile"].(string); ok {
-			t.dbFile = dbFile
-		} else {
+		t.dbFile = cfg.DBFile
+		if t.dbFile == "" {
 			t.dbFile = filepath.Join("./", fmt.Sprintf("%s-%s.db", t.tag, GetGlobRoot(t.glob)))
 		}
 	}
@@ -91,9 +85,9 @@ func (t *Tail) Init(config map[string]any) error {
 	}
 
 	t.state = make(map[string]*fileState)
+	t.trackers = make(map[string]*offsetTracker)
+	t.commits = make(map[string]fileState)
 	t.debounceTimers = make(map[string]*time.Timer)
-	t.fileEventCh = make(chan fileEvent, 300)
-	t.fileStateCh = make(chan fileState)
 	t.wg = sync.WaitGroup{}
 	t.mu = sync.Mutex{}
 	return nil
@@ -124,13 +118,24 @@ func getFileID(info os.FileInfo) (uint64, error) {
 }
 
 func (t *Tail) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	// The repository was closed by Exit when the input is restarted
+	if t.repositoryClosed && t.stateSavingEnabled {
+		t.repository = NewSQLiteTailRepository(t.dbFile)
+		if err := t.repository.CreateTables(); err != nil {
+			return err
+		}
+	}
+	t.repositoryClosed = false
+
+	t.ctx, t.cancel = context.WithCancel(parentCtx)
+	t.fileEventCh = make(chan fileEvent, 300)
+	fileEventCh := t.fileEventCh
+
 	t.wg.Add(1)
 	if t.stateSavingEnabled {
 		t.wg.Add(1)
 		t.persistStates()
 	}
-
-	t.ctx, t.cancel = context.WithCancel(parentCtx)
 	go t.fileStatLoop(t.ctx)
 	logrus.WithField("glob", t.glob).Info("Starting Tail Input")
 	go func() {
@@ -144,7 +149,7 @@ func (t *Tail) Start(parentCtx context.Context, output chan<- internal.Event) er
 				t.mu.Unlock()
 				return
 
-			case event, ok := <-t.fileEventCh:
+			case event, ok := <-fileEventCh:
 				if !ok {
 					return
 				}
@@ -166,8 +171,13 @@ func (t *Tail) Start(parentCtx context.Context, output chan<- internal.Event) er
 func (t *Tail) cleanupDeletedFile(path string, inode uint64) {
 	t.mu.Lock()
 	delete(t.state, path)
+	t.closeTracker(path)
 	t.mu.Unlock()
 
+	if !t.stateSavingEnabled {
+		return
+	}
+
 	if inode == 0 {
 		logrus.WithField("path", path).Error("no inode provided in db cleanup")
 		return
@@ -184,12 +194,22 @@ func (t *Tail) Exit() error {
 		t.cancel()
 	}
 	t.wg.Wait()
-	close(t.fileEventCh)
-	close(t.fileStateCh)
+	for path := range t.fileStats {
+		fileLag.DeleteLabelValues(t.name, path)
+	}
+	if t.fileEventCh != nil {
+		close(t.fileEventCh)
+		t.fileEventCh = nil
+	}
 
-	if !t.stateSavingEnabled {
+	if !t.stateSavingEnabled || t.repositoryClosed {
 		return nil
 	}
+	t.repositoryClosed = true
+
+	// The engine drains its outputs before the inputs are stopped, so the
+	// last acknowledgements arrive after the persist loop stopped
+	t.persistCommits()
 
 	if err := t.repository.Close(); err != nil {
 		logrus.WithError(err).Error("could not close db repostiory")
@@ -239,6 +259,8 @@ func (t *Tail) fileStatLoop(ctx context.Context) {
 			inode:   inode,
 		}
 
+		t.updateLag(absPath, currentInfo.size)
+
 		prevInfo, exists := t.fileStats[absPath]
 		if !exists {
 			// New file
@@ -254,6 +276,7 @@ func (t *Tail) fileStatLoop(ctx context.Context) {
 			// File was either recreated or truncated
 			t.mu.Lock()
 			delete(t.state, absPath) // Reset file state for recreated files
+			t.closeTracker(absPath)
 			t.mu.Unlock()
 
 			t.fileStats[absPath] = currentInfo
@@ -311,6 +334,7 @@ func (t *Tail) fileStatLoop(ctx context.Context) {
 					inode := t.fileStats[absPath].inode
 					sendFileEvent(fileEvent{path: absPath, eventType: FILEEVENT_DELETE, inode: inode})
 					delete(t.fileStats, absPath) // Remove from tracked files
+					fileLag.DeleteLabelValues(t.name, absPath)
 				}
 			}
 
@@ -375,12 +399,6 @@ func (t *Tail) readFile(path string, output chan<- internal.Event) error {
 		return fmt.Errorf("error getting file stats: %v", err)
 	}
 
-	sendFileState := func(state fileState) {
-		if t.stateSavingEnabled {
-			t.fileStateCh <- state
-		}
-	}
-
 	t.mu.Lock()
 	var currentFileState *fileState
 	if state, exists := t.state[path]; !exists {
@@ -409,24 +427,34 @@ func (t *Tail) readFile(path string, output chan<- internal.Event) error {
 	if currentFileState.Offset > fileInfo.Size() {
 		currentFileState.Offset = 0
 		currentFileState.LastReadLine = 0
-		if err := t.repository.DeleteFileState(currentFileState.Path, currentFileState.InodeNumber); err != nil {
-			logrus.WithError(err).Error("error during file state deleting")
+		if t.stateSavingEnabled {
+			t.mu.Lock()
+			t.closeTracker(path)
+			t.mu.Unlock()
+			if err := t.repository.DeleteFileState(currentFileState.Path, currentFileState.InodeNumber); err != nil {
+				logrus.WithError(err).Error("error during file state deleting")
+			}
 		}
 	}
 
+	tracker := t.tracker(currentFileState)
+
 	// Seek to the saved offset
-	file.Seek(currentFileState.Offset, io.SeekStart)
+	offset := currentFileState.Offset
+	file.Seek(offset, io.SeekStart)
 	reader := bufio.NewReader(file)
 
+	saveReadOffset := func() {
+		t.mu.Lock()
+		currentFileState.Offset = offset
+		t.state[path] = currentFileState
+		t.mu.Unlock()
+	}
+
 	for {
 		select {
 		case <-t.ctx.Done():
-			currOffset, _ := file.Seek(0, io.SeekCurrent)
-			t.mu.Lock()
-			currentFileState.Offset = currOffset
-			t.state[path] = currentFileState
-			sendFileState(*t.state[path])
-			t.mu.Unlock()
+			saveReadOffset()
 			return nil
 		default:
 		}
@@ -434,21 +462,21 @@ func (t *Tail) readFile(path string, output chan<- internal.Event) error {
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			if err == io.EOF {
-				currOffset, _ := file.Seek(0, io.SeekCurrent)
-				t.mu.Lock()
-				currentFileState.Offset = currOffset
-				t.state[path] = currentFileState
-				sendFileState(*t.state[path])
-				t.mu.Unlock()
+				// A partial line is read again once it is completed
+				saveReadOffset()
 				return nil
 			}
 			return fmt.Errorf("error reading file: %v", err)
 		}
 
+		offset += int64(len(line))
 		line = strings.TrimSpace(line)
 		currentFileState.LastReadLine++
 
 		if len(line) == 0 {
+			if tracker != nil {
+				tracker.skip(offset, currentFileState.LastReadLine)
+			}
 			continue
 		}
 
@@ -461,6 +489,11 @@ func (t *Tail) readFile(path string, output chan<- internal.Event) error {
 			},
 		}
 		input.AddMetadata(&event, t)
+		if tracker != nil {
+			// A line the engine gave up on holds back the offset, so it is
+			// read again after a restart
+			event.OnAck, event.OnNack = tracker.track(offset, currentFileState.LastReadLine)
+		}
 
 		select {
 		case output <- event:
@@ -470,9 +503,67 @@ func (t *Tail) readFile(path string, output chan<- internal.Event) error {
 	}
 }
 
+// tracker returns the offset tracker of a file, the offset of a file is
+// committed only for lines the engine acknowledged
+func (t *Tail) tracker(state *fileState) *offsetTracker {
+	if !t.stateSavingEnabled {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracker, exists := t.trackers[state.Path]
+	if !exists || tracker.inode != state.InodeNumber {
+		if exists {
+			tracker.close()
+		}
+		tracker = newOffsetTracker(state.Path, state.InodeNumber, t.commitState)
+		t.trackers[state.Path] = tracker
+	}
+	return tracker
+}
+
+// closeTracker has to be called with t.mu held
+func (t *Tail) closeTracker(path string) {
+	if tracker, exists := t.trackers[path]; exists {
+		tracker.close()
+		delete(t.trackers, path)
+	}
+}
+
+// commitState queues a file state for the next database write
+func (t *Tail) commitState(state fileState) {
+	t.commitMu.Lock()
+	defer t.commitMu.Unlock()
+
+	if t.commits == nil {
+		t.commits = make(map[string]fileState)
+	}
+	t.commits[state.Path] = state
+}
+
+// persistCommits writes all queued file states to the database
+func (t *Tail) persistCommits() {
+	t.commitMu.Lock()
+	if len(t.commits) == 0 {
+		t.commitMu.Unlock()
+		return
+	}
+	updates := make([]fileState, 0, len(t.commits))
+	for _, state := range t.commits {
+		updates = append(updates, state)
+	}
+	clear(t.commits)
+	t.commitMu.Unlock()
+
+	if err := t.repository.BatchUpsertFileStates(updates); err != nil {
+		logrus.WithError(err).Error("could not persist file states")
+	}
+}
+
 func (t *Tail) persistStates() {
 	ticker := time.NewTicker(time.Millisecond * 100)
-	var updates []fileState
 
 	go func() {
 		defer t.wg.Done()
@@ -480,17 +571,10 @@ func (t *Tail) persistStates() {
 		for {
 			select {
 			case <-t.ctx.Done():
-				if len(updates) > 0 {
-					t.repository.BatchUpsertFileStates(updates)
-				}
+				t.persistCommits()
 				return
-			case state := <-t.fileStateCh:
-				updates = append(updates, state)
 			case <-ticker.C:
-				if len(updates) > 0 {
-					t.repository.BatchUpsertFileStates(updates)
-					updates = updates[:0]
-				}
+				t.persistCommits()
 			}
 		}
 	}()
@@ -10,8 +10,10 @@ import (
 	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/engine"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockTailRepository implements TailRepository for testing
@@ -86,6 +88,7 @@ func createTempDir(t *testing.T, pattern string) (string, func()) {
 }
 
 func TestTail_Init(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "test.db")
 	tests := []struct {
 		name    string
 		config  map[string]any
@@ -112,7 +115,7 @@ func TestTail_Init(t *testing.T) {
 			config: map[string]any{
 				"Glob":     "*.log",
 				"EnableDB": true,
-				"DBFile":   "test.db",
+				"DBFile":   dbFile,
 			},
 			wantErr: false,
 		},
@@ -223,7 +226,6 @@ func TestTail_PersistStates(t *testing.T) {
 
 	tail := &Tail{
 		repository:         mockRepo,
-		fileStateCh:        make(chan fileState, 10),
 		stateSavingEnabled: true,
 	}
 
@@ -239,14 +241,14 @@ func TestTail_PersistStates(t *testing.T) {
 		tail.persistStates()
 	}()
 
-	// Send test states
+	// Commit test states
 	testStates := []fileState{
 		{Path: "test1.log", Offset: 100, LastReadLine: 10},
 		{Path: "test2.log", Offset: 200, LastReadLine: 20},
 	}
 
 	for _, state := range testStates {
-		tail.fileStateCh <- state
+		tail.commitState(state)
 	}
 
 	// Wait for processing
@@ -257,6 +259,98 @@ func TestTail_PersistStates(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestOffsetTracker_CommitsContiguousOffsets(t *testing.T) {
+	var commits []fileState
+	tracker := newOffsetTracker("test.log", 42, func(state fileState) {
+		commits = append(commits, state)
+	})
+
+	ack1, _ := tracker.track(6, 1)
+	ack2, _ := tracker.track(12, 2)
+	tracker.skip(13, 3)
+	ack4, _ := tracker.track(19, 4)
+
+	// Line 2 is acknowledged first, but line 1 is still in flight
+	ack2()
+	assert.Empty(t, commits)
+
+	ack1()
+	assert.Equal(t, []fileState{{Path: "test.log", Offset: 13, LastReadLine: 3, InodeNumber: 42}}, commits)
+
+	ack4()
+	ack4()
+	assert.Len(t, commits, 2)
+	assert.Equal(t, int64(19), commits[1].Offset)
+
+	tracker.close()
+	ack5, _ := tracker.track(25, 5)
+	ack5()
+	assert.Len(t, commits, 2)
+}
+
+func TestOffsetTracker_HoldsOffsetAtUndeliveredLine(t *testing.T) {
+	var commits []fileState
+	tracker := newOffsetTracker("test.log", 42, func(state fileState) {
+		commits = append(commits, state)
+	})
+
+	ack1, _ := tracker.track(6, 1)
+	_, nack2 := tracker.track(12, 2)
+	ack3, _ := tracker.track(18, 3)
+
+	// Line 1 is still committed, line 2 and everything after it is not
+	nack2()
+	ack3()
+	assert.Empty(t, commits)
+	ack1()
+	assert.Equal(t, []fileState{{Path: "test.log", Offset: 6, LastReadLine: 1, InodeNumber: 42}}, commits)
+
+	ack4, _ := tracker.track(24, 4)
+	ack4()
+	tracker.skip(25, 5)
+	assert.Len(t, commits, 1)
+	assert.Empty(t, tracker.pending)
+}
+
+func TestTail_CommitsOnlyAcknowledgedLines(t *testing.T) {
+	content := "line1\nline2\nline3\n"
+	tmpFile, cleanup := createTempFile(t, "", content)
+	defer cleanup()
+
+	mockRepo := new(MockTailRepository)
+	mockRepo.On("GetFileState", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("not found"))
+
+	tail := &Tail{
+		repository:         mockRepo,
+		state:              make(map[string]*fileState),
+		trackers:           make(map[string]*offsetTracker),
+		ctx:                context.Background(),
+		stateSavingEnabled: true,
+	}
+
+	output := make(chan internal.Event, 10)
+	tail.wg.Add(1)
+	assert.NoError(t, tail.readFile(tmpFile, output))
+
+	var events []internal.Event
+	for range 3 {
+		events = append(events, <-output)
+	}
+
+	// Nothing is committed before the engine acknowledged the lines
+	assert.Empty(t, tail.commits)
+
+	events[0].Ack()
+	events[2].Ack()
+	assert.Equal(t, int64(6), tail.commits[tmpFile].Offset)
+
+	// A line the engine gave up on holds back the offset
+	events[1].Nack()
+	assert.Equal(t, int64(6), tail.commits[tmpFile].Offset)
+	assert.Equal(t, 1, tail.commits[tmpFile].LastReadLine)
+	assert.Empty(t, tail.trackers[tmpFile].pending)
+}
+
 func TestTail_Integration(t *testing.T) {
 	tmpDir, cleanup := createTempDir(t, "test-tail-integration")
 	defer cleanup()
@@ -318,3 +412,80 @@ func TestTail_Integration(t *testing.T) {
 	err = tail.Exit()
 	assert.NoError(t, err)
 }
+
+// lineOutput fails to write the events of failLine and records the others
+type lineOutput struct {
+	failLine string
+	mu       sync.Mutex
+	lines    []string
+	failures int
+}
+
+func (l *lineOutput) Name() string                     { return "lines" }
+func (l *lineOutput) Init(config map[string]any) error { return nil }
+func (l *lineOutput) Exit() error                      { return nil }
+func (l *lineOutput) Flush() error                     { return nil }
+func (l *lineOutput) MatchTag(inputTag string) bool    { return true }
+
+func (l *lineOutput) Write(events []internal.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, event := range events {
+		if event.RawData == l.failLine {
+			l.failures++
+			return fmt.Errorf("cant write %s", event.RawData)
+		}
+	}
+	for _, event := range events {
+		l.lines = append(l.lines, event.RawData)
+	}
+	return nil
+}
+
+func (l *lineOutput) written() ([]string, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...), l.failures
+}
+
+func TestTail_ReplaysUndeliveredLines(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	config := map[string]any{
+		"Glob":     filepath.Join(dir, "*.log"),
+		"EnableDB": true,
+		"DBFile":   filepath.Join(dir, "tail.db"),
+	}
+
+	// run tails the file until want was written and returns what the
+	// output got
+	run := func(out *lineOutput, want func(lines []string, failures int) bool) []string {
+		tail := &Tail{}
+		require.NoError(t, tail.Init(config))
+		e := engine.NewEngine(engine.DefaultSettings())
+		e.RegisterInput(tail)
+		// The output fails without a dead letter file, so the engine gives
+		// up on the line
+		e.RegisterOutput(out, engine.OutputOptions{
+			Retry: engine.RetryPolicy{MaxAttempts: 1},
+			Batch: engine.BatchSettings{Size: 1},
+		})
+		require.NoError(t, e.Start())
+		assert.Eventually(t, func() bool { return want(out.written()) }, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, e.Stop())
+		lines, _ := out.written()
+		return lines
+	}
+
+	require.NoError(t, os.WriteFile(logFile, []byte("line1\nline2\nline3\n"), 0o644))
+	lines := run(&lineOutput{failLine: "line2"}, func(lines []string, failures int) bool {
+		return len(lines) == 2 && failures == 1
+	})
+	assert.Equal(t, []string{"line1", "line3"}, lines)
+
+	// After a restart the undelivered line and the ones after it are read again
+	lines = run(&lineOutput{}, func(lines []string, failures int) bool {
+		return len(lines) == 2
+	})
+	assert.Equal(t, []string{"line2", "line3"}, lines)
+}
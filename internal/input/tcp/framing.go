@@ -0,0 +1,17 @@
+package inputtcp
+
+import "github.com/MuchTitan/go-log-forwarder/internal/input"
+
+const (
+	FramingNewline        = "newline"
+	FramingOctetCounting  = "octet-counting"
+	FramingNullByte       = "null-byte"
+	FramingLengthPrefixed = "length-prefixed"
+)
+
+var framers = map[string]input.Framer{
+	FramingNewline:        input.Delimited('\n'),
+	FramingOctetCounting:  input.ReadOctetCounted,
+	FramingNullByte:       input.Delimited(0),
+	FramingLengthPrefixed: input.ReadLengthPrefixed,
+}
@@ -0,0 +1,56 @@
+package inputtcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCP_RecordsSplitAcrossReads(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	tcp := &TCP{}
+	require.NoError(t, tcp.Init(map[string]any{"ListenAddr": "127.0.0.1", "Port": port, "MaxConnections": 1}))
+	output := make(chan internal.Event, 10)
+	require.NoError(t, tcp.Start(context.Background(), output))
+	defer tcp.Exit()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A record split across writes and several records in one write
+	for _, chunk := range []string{"first li", "ne\nsecond\nthi", "rd\n"} {
+		_, err := conn.Write([]byte(chunk))
+		require.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for i, want := range []string{"first line", "second", "third"} {
+		select {
+		case event := <-output:
+			assert.Equal(t, want, event.RawData)
+			assert.Equal(t, i+1, event.Metadata.LineNum)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event for %q", want)
+		}
+	}
+
+	// The second connection exceeds MaxConnections and is closed right away
+	rejected, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer rejected.Close()
+	rejected.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = rejected.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,14 @@
+package inputtcp
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	activeConnections = metrics.NewGaugeVec("forwarder_tcp_active_connections",
+		"Open connections of a tcp input.", "input")
+	rejectedConnections = metrics.NewCounterVec("forwarder_tcp_rejected_connections_total",
+		"Connections a tcp input rejected because of the connection limit.", "input")
+	droppedEvents = metrics.NewCounterVec("forwarder_tcp_dropped_events_total",
+		"Events a tcp input dropped because the pipeline was full.", "input")
+	oversizedRecords = metrics.NewCounterVec("forwarder_tcp_oversized_records_total",
+		"Records a tcp input dropped because they were larger than MaxMessageSize.", "input")
+)
@@ -1,25 +1,36 @@
 package inputtcp
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/input"
-	"github.com/MuchTitan/go-log-forwarder/internal/util"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	defaultTCPBufferSize  = 64 << 10 // 64KB
-	defaultTCPTimeout     = 10       // 10 minute timeout
-	maxConnectionCountTCP = 50       // Maximum number of concurrent connections
-)
+// Config is the configuration of the tcp input
+type Config struct {
+	Name           string          `config:"Name" default:"tcp" desc:"The name of the input instance."`
+	Tag            string          `config:"Tag" default:"tcp" desc:"A tag associated with the log events."`
+	ListenAddr     string          `config:"ListenAddr" default:"0.0.0.0" desc:"The address on which the tcp input should listen on."`
+	Port           int             `config:"Port" default:"6666" min:"1" max:"65535" desc:"The port on which the tcp input should listen on."`
+	BufferSize     schema.ByteSize `config:"BufferSize" default:"64KiB" min:"1" desc:"The size of the read buffer."`
+	Timeout        time.Duration   `config:"Timeout" default:"10m" desc:"Connections without data for this long are closed."`
+	Framing        string          `config:"Framing" default:"newline" enum:"newline,octet-counting,null-byte,length-prefixed" desc:"How the records of a connection are separated, every record becomes one event. See [Framing](#framing)."`
+	MaxMessageSize schema.ByteSize `config:"MaxMessageSize" default:"1MiB" min:"1" desc:"The maximum size of a record. Longer records are dropped."`
+	MaxConnections int             `config:"MaxConnections" default:"50" min:"1" desc:"The maximum number of concurrent connections, further connections are rejected."`
+	TLS            input.TLSConfig `config:"TLS" desc:"Terminates tls on the connections."`
+}
 
 type TCP struct {
 	name           string
@@ -28,6 +39,11 @@ type TCP struct {
 	port           int
 	bufferSize     int64
 	timeout        time.Duration
+	framing        string
+	readRecord     input.Framer
+	maxMessageSize int
+	maxConnections int32
+	tlsConfig      *tls.Config
 	listener       net.Listener
 	activeConns    sync.Map
 	connCount      int32
@@ -46,46 +62,28 @@ func (t *TCP) Tag() string {
 }
 
 func (t *TCP) Init(config map[string]any) error {
-	t.listenAddr = util.MustString(config["ListenAddr"])
-	if t.listenAddr == "" {
-		t.listenAddr = "0.0.0.0"
-	}
-
-	if portStr, exists := config["Port"]; exists {
-		var ok bool
-		if t.port, ok = portStr.(int); !ok {
-			return errors.New("cant convert port to int")
-		}
-	} else {
-		t.port = 6666
-	}
-
-	if bufferSizeStr, exists := config["BufferSize"]; exists {
-		var ok bool
-		if t.bufferSize, ok = bufferSizeStr.(int64); !ok {
-			return errors.New("cant convert bufferSize to int")
-		}
-		t.bufferSize <<= 10 // Convert to KB
-	} else {
-		t.bufferSize = defaultTCPBufferSize
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	if timeoutStr, exists := config["Timeout"]; exists {
-		if _, err := fmt.Sscanf(timeoutStr.(string), "%d", &t.timeout); err != nil {
-			return fmt.Errorf("invalid timeout: %v", err)
+	t.name = cfg.Name
+	t.tag = cfg.Tag
+	t.listenAddr = cfg.ListenAddr
+	t.port = cfg.Port
+	t.bufferSize = int64(cfg.BufferSize)
+	t.timeout = cfg.Timeout
+	t.framing = cfg.Framing
+	t.readRecord = framers[cfg.Framing]
+	t.maxMessageSize = int(cfg.MaxMessageSize)
+	t.maxConnections = int32(cfg.MaxConnections)
+
+	t.tlsConfig = nil
+	if cfg.TLS.Enabled() {
+		var err error
+		if t.tlsConfig, err = cfg.TLS.ServerConfig(); err != nil {
+			return err
 		}
-	} else {
-		t.timeout = defaultTCPTimeout
-	}
-
-	t.name = util.MustString(config["Name"])
-	if t.name == "" {
-		t.name = "tcp"
-	}
-
-	t.tag = util.MustString(config["Tag"])
-	if t.tag == "" {
-		t.tag = "tcp"
 	}
 
 	return nil
@@ -95,11 +93,12 @@ func (t *TCP) incrementConnCount() bool {
 	t.connCountMutex.Lock()
 	defer t.connCountMutex.Unlock()
 
-	if t.connCount >= maxConnectionCountTCP {
+	if t.connCount >= t.maxConnections {
 		return false
 	}
 
 	t.connCount++
+	activeConnections.WithLabelValues(t.name).Set(float64(t.connCount))
 	return true
 }
 
@@ -110,8 +109,11 @@ func (t *TCP) decrementConnCount() {
 	if t.connCount > 0 {
 		t.connCount--
 	}
+	activeConnections.WithLabelValues(t.name).Set(float64(t.connCount))
 }
 
+// handleConnection reads the records of a connection until it is closed,
+// every record becomes one event
 func (t *TCP) handleConnection(cs *connState, output chan<- internal.Event) {
 	defer t.wg.Done()
 	defer cs.Close()
@@ -121,18 +123,24 @@ func (t *TCP) handleConnection(cs *connState, output chan<- internal.Event) {
 	conn := cs.conn
 	remoteAddr := conn.RemoteAddr().String()
 	linenumber := 0
+	logrus.WithField("remote_addr", remoteAddr).Debug("New tcp connection established")
 
-	if err := conn.SetDeadline(time.Now().Add(t.timeout * time.Minute)); err != nil {
-		logrus.WithField("remote_addr", remoteAddr).WithError(err).Error("Failed to set connection deadline")
-		return
+	var fields map[string]string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := t.handshake(tlsConn); err != nil {
+			logrus.WithField("remote_addr", remoteAddr).WithError(err).Warn("tls handshake failed")
+			return
+		}
+		state := tlsConn.ConnectionState()
+		if cn := input.ClientCN(&state); cn != "" {
+			fields = map[string]string{input.ClientCNField: cn}
+		}
 	}
 
-	buffer := make([]byte, t.bufferSize)
-	logrus.WithField("remote_addr", remoteAddr).Debug("New tcp connection established")
-
 	readCtx, cancel := context.WithCancel(t.ctx)
 	defer cancel()
 
+	// Closing the connection interrupts a blocked read on shutdown
 	go func() {
 		<-readCtx.Done()
 		if err := cs.Close(); err != nil {
@@ -140,74 +148,65 @@ func (t *TCP) handleConnection(cs *connState, output chan<- internal.Event) {
 		}
 	}()
 
+	reader := bufio.NewReaderSize(input.NewIdleTimeoutReader(conn, t.timeout), int(t.bufferSize))
 	for {
-		if cs.IsClosed() {
-			return
+		record, err := t.readRecord(reader, t.maxMessageSize)
+		if errors.Is(err, input.ErrRecordTooLarge) {
+			oversizedRecords.WithLabelValues(t.name).Inc()
+			logrus.WithField("remote_addr", remoteAddr).Warn("tcp record larger than MaxMessageSize, dropping it")
+			continue
 		}
-
-		select {
-		case <-readCtx.Done():
-			logrus.WithField("remote_addr", remoteAddr).Error("Connection closed due to shutdown")
-			return
-		default:
-			if err := conn.SetReadDeadline(time.Now().Add(1 * time.Second)); err != nil {
-				if !cs.IsClosed() {
-					logrus.WithField("remote_addr", remoteAddr).WithError(err).Error("Failed to reset deadline")
-				}
-				return
-			}
-
-			n, err := conn.Read(buffer)
-			if err != nil {
-				if err == io.EOF {
-					logrus.WithField("remote_addr", remoteAddr).Debug("Client closed tcp connection")
-					return
-				}
-
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					if readCtx.Err() != nil {
-						return
-					}
-					continue
-				}
-
-				if !cs.IsClosed() && t.ctx.Err() != nil {
+		if err != nil {
+			switch {
+			case err == io.EOF:
+				logrus.WithField("remote_addr", remoteAddr).Debug("Client closed tcp connection")
+			case readCtx.Err() != nil || cs.IsClosed():
+				logrus.WithField("remote_addr", remoteAddr).Debug("Connection closed due to shutdown")
+			default:
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					logrus.WithField("remote_addr", remoteAddr).Debug("Closing idle tcp connection")
+				} else {
 					logrus.WithField("remote_addr", remoteAddr).WithError(err).Error("Failed to read from tcp connection")
 				}
-				return
 			}
+			return
+		}
+		if len(record) == 0 {
+			continue
+		}
 
-			if err := conn.SetReadDeadline(time.Now().Add(t.timeout * time.Minute)); err != nil {
-				if !cs.IsClosed() {
-					logrus.WithField("remote_addr", remoteAddr).WithError(err).Error("Failed to reset deadline")
-				}
-				return
-			}
+		linenumber++
+		event := internal.Event{
+			Timestamp: time.Now(),
+			RawData:   string(record),
+			Metadata: internal.Metadata{
+				Source:  remoteAddr,
+				LineNum: linenumber,
+				Fields:  maps.Clone(fields),
+			},
+		}
+		input.AddMetadata(&event, t)
 
-			if n > 0 {
-				linenumber++
-				event := internal.Event{
-					Timestamp: time.Now(),
-					RawData:   string(buffer[:n]),
-					Metadata: internal.Metadata{
-						Source:  remoteAddr,
-						LineNum: linenumber,
-					},
-				}
-				input.AddMetadata(&event, t)
-
-				select {
-				case output <- event:
-				case <-t.ctx.Done():
-					return
-				default:
-					logrus.WithField("remote_addr", remoteAddr).Warn("tcp event channel full, dropping message")
-				}
-			}
+		select {
+		case output <- event:
+		case <-t.ctx.Done():
+			return
+		default:
+			droppedEvents.WithLabelValues(t.name).Inc()
+			logrus.WithField("remote_addr", remoteAddr).Warn("tcp event channel full, dropping message")
 		}
 	}
 }
 
+// handshake runs the tls handshake of a new connection, which has to
+// finish within the idle timeout
+func (t *TCP) handshake(conn *tls.Conn) error {
+	ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+	defer cancel()
+	return conn.HandshakeContext(ctx)
+}
+
 func (t *TCP) Start(parentCtx context.Context, output chan<- internal.Event) error {
 	var err error
 	addr := fmt.Sprintf("%s:%d", t.listenAddr, t.port)
@@ -217,12 +216,17 @@ func (t *TCP) Start(parentCtx context.Context, output chan<- internal.Event) err
 	if err != nil {
 		return fmt.Errorf("couldn't start tcp input: %w", err)
 	}
+	if t.tlsConfig != nil {
+		t.listener = tls.NewListener(t.listener, t.tlsConfig)
+	}
 
 	logrus.WithFields(logrus.Fields{
 		"addr":            addr,
 		"buffer_size":     t.bufferSize,
 		"timeout":         t.timeout,
-		"max_connections": maxConnectionCountTCP,
+		"max_connections": t.maxConnections,
+		"framing":         t.framing,
+		"tls":             t.tlsConfig != nil,
 	}).Info("Starting tcp input")
 
 	t.wg.Add(1)
@@ -236,15 +240,25 @@ func (t *TCP) Start(parentCtx context.Context, output chan<- internal.Event) err
 				conn, err := t.listener.Accept()
 				if err != nil {
 					if t.ctx.Err() != nil {
-						logrus.WithError(err).Error("could not accept tcp input connection")
+						return
+					}
+					if errors.Is(err, net.ErrClosed) {
+						// The listener is gone, let the engine restart the input
+						input.ReportError(parentCtx, fmt.Errorf("tcp listener closed: %w", err))
+						return
+					}
+					logrus.WithError(err).Error("could not accept tcp input connection")
+					if !input.WaitAcceptRetry(t.ctx) {
+						return
 					}
 					continue
 				}
 
 				if !t.incrementConnCount() {
+					rejectedConnections.WithLabelValues(t.name).Inc()
 					logrus.WithFields(logrus.Fields{
 						"remote_addr":     conn.RemoteAddr().String(),
-						"max_connections": maxConnectionCountTCP,
+						"max_connections": t.maxConnections,
 					}).Warn("Maximum tcp connection limit reached, rejecting connection")
 					conn.Close()
 					continue
@@ -0,0 +1,69 @@
+package inputtcp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCP_TLS(t *testing.T) {
+	files := tlstest.New(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	tcp := &TCP{}
+	require.NoError(t, tcp.Init(map[string]any{
+		"ListenAddr": "127.0.0.1",
+		"Port":       port,
+		"TLS": map[string]any{
+			"CertFile":   files.CertFile,
+			"KeyFile":    files.KeyFile,
+			"CAFile":     files.CAFile,
+			"ClientAuth": "request",
+		},
+	}))
+	output := make(chan internal.Event, 10)
+	require.NoError(t, tcp.Start(context.Background(), output))
+	defer tcp.Exit()
+
+	for _, cn := range []string{"payments", ""} {
+		conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), files.ClientConfig(t, cn))
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("hello\n"))
+		require.NoError(t, err)
+		conn.Close()
+
+		select {
+		case event := <-output:
+			assert.Equal(t, "hello", event.RawData)
+			assert.Equal(t, cn, event.Metadata.Fields[input.ClientCNField])
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+		}
+	}
+
+	// A plain tcp client fails the handshake and is disconnected
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.Write([]byte("hello\n"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(make([]byte, 1024))
+	assert.Error(t, err)
+	select {
+	case event := <-output:
+		t.Fatalf("unexpected event %q", event.RawData)
+	default:
+	}
+}
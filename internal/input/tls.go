@@ -0,0 +1,198 @@
+package input
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClientCNField is the metadata field the common name of a verified client
+// certificate is recorded in
+const ClientCNField = "tls_client_cn"
+
+// TLSConfig is the tls configuration of a network input. Tls is enabled
+// when CertFile is set.
+type TLSConfig struct {
+	CertFile       string        `config:"CertFile" desc:"The certificate of the server in PEM format. Enables tls when set."`
+	KeyFile        string        `config:"KeyFile" desc:"The private key of the certificate in PEM format."`
+	CAFile         string        `config:"CAFile" desc:"The CA certificates client certificates are verified with in PEM format."`
+	ClientAuth     string        `config:"ClientAuth" enum:"none,request,require-and-verify" desc:"Whether clients have to present a certificate. require-and-verify needs a CAFile. Defaults to request when a CAFile is set, none otherwise."`
+	MinVersion     string        `config:"MinVersion" default:"1.2" enum:"1.0,1.1,1.2,1.3" desc:"The minimum tls version clients have to use."`
+	CipherSuites   []string      `config:"CipherSuites" desc:"The cipher suites for tls 1.2 and older, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Empty uses the secure defaults of Go."`
+	ReloadInterval time.Duration `config:"ReloadInterval" default:"1m" desc:"How often the files are checked for changes. Changed files are loaded for new connections."`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+// Enabled reports whether the input terminates tls
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// ServerConfig loads the certificates and returns the tls config of a
+// server. The files are checked for changes every ReloadInterval, a file
+// that can't be loaded keeps the previous certificates in use.
+func (c TLSConfig) ServerConfig() (*tls.Config, error) {
+	if c.KeyFile == "" {
+		return nil, errors.New("TLS.KeyFile: missing required field when CertFile is set")
+	}
+	if c.ClientAuth == "require-and-verify" && c.CAFile == "" {
+		return nil, errors.New("TLS.CAFile: missing required field when ClientAuth is require-and-verify")
+	}
+
+	var cipherSuites []uint16
+	for _, name := range c.CipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("TLS.CipherSuites: unsupported cipher suite '%s'", name)
+		}
+		cipherSuites = append(cipherSuites, id)
+	}
+
+	reloader := &certReloader{
+		config:       c,
+		cipherSuites: cipherSuites,
+		modTimes:     map[string]time.Time{},
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         tlsVersions[c.MinVersion],
+		GetConfigForClient: reloader.configForClient,
+	}, nil
+}
+
+func cipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if strings.EqualFold(suite.Name, name) {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// ClientCN returns the common name of the verified client certificate of a
+// connection, empty if the client didn't present one
+func ClientCN(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
+
+// certReloader hands out the tls config of new connections and loads it
+// again once the certificate files changed
+type certReloader struct {
+	config       TLSConfig
+	cipherSuites []uint16
+	mu           sync.Mutex
+	current      *tls.Config
+	modTimes     map[string]time.Time
+	lastCheck    time.Time
+}
+
+func (r *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= r.config.ReloadInterval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				logrus.WithError(err).Error("Coundnt reload tls certificates, keeping the loaded ones")
+			} else {
+				logrus.WithField("cert_file", r.config.CertFile).Info("Reloaded tls certificates")
+			}
+		}
+	}
+	return r.current, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.CAFile != "" {
+		files = append(files, r.config.CAFile)
+	}
+	return files
+}
+
+func (r *certReloader) changed() bool {
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("cant read tls file: %w", err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cant load tls certificate: %w", err)
+	}
+
+	clientAuth := r.config.ClientAuth
+	if clientAuth == "" {
+		// A CA is only set to verify the certificates of clients
+		clientAuth = "none"
+		if r.config.CAFile != "" {
+			clientAuth = "request"
+		}
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuthTypes[clientAuth],
+		MinVersion:   tlsVersions[r.config.MinVersion],
+		CipherSuites: r.cipherSuites,
+	}
+	if r.config.CAFile != "" {
+		pem, err := os.ReadFile(r.config.CAFile)
+		if err != nil {
+			return fmt.Errorf("cant read tls CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.config.CAFile)
+		}
+		config.ClientCAs = pool
+		if config.ClientAuth == tls.RequestClientCert {
+			// Verify the certificates clients present, so only verified
+			// ones are recorded
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	r.current = config
+	r.modTimes = modTimes
+	return nil
+}
@@ -0,0 +1,145 @@
+package input
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal/input/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handshake connects a client with config to a server with serverConfig
+// and returns the connection state the server sees
+func handshake(t *testing.T, serverConfig, config *tls.Config) (tls.ConnectionState, error) {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	type result struct {
+		state tls.ConnectionState
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		err = tlsConn.Handshake()
+		results <- result{tlsConn.ConnectionState(), err}
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+	if err == nil {
+		// The server verifies the client certificate after the client finished
+		conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	r := <-results
+	return r.state, r.err
+}
+
+func TestTLSConfig_ServerConfig(t *testing.T) {
+	files := tlstest.New(t)
+
+	tests := []struct {
+		name    string
+		config  TLSConfig
+		client  string
+		wantCN  string
+		wantErr bool
+	}{
+		{"server only", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, ClientAuth: "none"}, "", "", false},
+		{"client certificate required", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CAFile: files.CAFile, ClientAuth: "require-and-verify"}, "team-a", "team-a", false},
+		{"client certificate missing", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CAFile: files.CAFile, ClientAuth: "require-and-verify"}, "", "", true},
+		{"client certificate requested", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CAFile: files.CAFile, ClientAuth: "request"}, "team-b", "team-b", false},
+		{"client certificate not verified", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, ClientAuth: "request"}, "team-c", "", false},
+		{"client certificate verified by default with a CA", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CAFile: files.CAFile}, "team-d", "team-d", false},
+		{"client certificate optional by default with a CA", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CAFile: files.CAFile}, "", "", false},
+		{"client certificate not requested", TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CAFile: files.CAFile, ClientAuth: "none"}, "team-e", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.MinVersion = "1.2"
+			tt.config.ReloadInterval = time.Minute
+			serverConfig, err := tt.config.ServerConfig()
+			require.NoError(t, err)
+
+			state, err := handshake(t, serverConfig, files.ClientConfig(t, tt.client))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCN, ClientCN(&state))
+		})
+	}
+}
+
+func TestTLSConfig_ServerConfigErrors(t *testing.T) {
+	files := tlstest.New(t)
+
+	tests := []struct {
+		config  TLSConfig
+		wantErr string
+	}{
+		{TLSConfig{CertFile: files.CertFile}, "TLS.KeyFile: missing required field when CertFile is set"},
+		{TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, ClientAuth: "require-and-verify"}, "TLS.CAFile: missing required field when ClientAuth is require-and-verify"},
+		{TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, CipherSuites: []string{"TLS_NULL"}}, "TLS.CipherSuites: unsupported cipher suite 'TLS_NULL'"},
+		{TLSConfig{CertFile: files.KeyFile, KeyFile: files.KeyFile}, "cant load tls certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantErr, func(t *testing.T) {
+			_, err := tt.config.ServerConfig()
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestTLSConfig_Reload(t *testing.T) {
+	files := tlstest.New(t)
+	serverConfig, err := TLSConfig{CertFile: files.CertFile, KeyFile: files.KeyFile, ClientAuth: "none", MinVersion: "1.3"}.ServerConfig()
+	require.NoError(t, err)
+
+	// The certificate the client got from the server
+	clientSeen := func() string {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+		require.NoError(t, err)
+		defer listener.Close()
+		go func() {
+			if conn, err := listener.Accept(); err == nil {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}()
+		conn, err := tls.Dial("tcp", listener.Addr().String(), files.ClientConfig(t, ""))
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	assert.Equal(t, "localhost", clientSeen())
+
+	files.WriteServerCert(t, "rotated")
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(files.CertFile, later, later))
+	assert.Equal(t, "rotated", clientSeen())
+
+	// A broken file keeps the loaded certificate
+	require.NoError(t, os.WriteFile(files.KeyFile, []byte("broken"), 0600))
+	assert.Equal(t, "rotated", clientSeen())
+}
+
+func TestClientCN_NoTLS(t *testing.T) {
+	assert.Empty(t, ClientCN(nil))
+	assert.Empty(t, ClientCN(&tls.ConnectionState{}))
+}
@@ -0,0 +1,136 @@
+// Package tlstest creates certificates for tests of inputs that terminate tls
+package tlstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Files are the PEM files of a CA and a server certificate signed by it
+type Files struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	ca       *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	caPool   *x509.CertPool
+}
+
+// New writes a CA and a server certificate for localhost and 127.0.0.1 to
+// a temporary directory
+func New(t *testing.T) *Files {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey := newKey(t)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Files{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server-key.pem"),
+		ca:       ca,
+		caKey:    caKey,
+		caPool:   x509.NewCertPool(),
+	}
+	f.caPool.AddCert(ca)
+	writePEM(t, f.CAFile, "CERTIFICATE", caDER)
+	f.WriteServerCert(t, "localhost")
+	return f
+}
+
+// WriteServerCert replaces the server certificate with one for cn
+func (f *Files) WriteServerCert(t *testing.T, cn string) {
+	t.Helper()
+	certDER, key := f.sign(t, cn, x509.ExtKeyUsageServerAuth)
+	writePEM(t, f.CertFile, "CERTIFICATE", certDER)
+	writePEM(t, f.KeyFile, "EC PRIVATE KEY", key)
+}
+
+// ClientConfig returns the tls config of a client that trusts the CA, with
+// a client certificate for cn unless cn is empty
+func (f *Files) ClientConfig(t *testing.T, cn string) *tls.Config {
+	t.Helper()
+	config := &tls.Config{RootCAs: f.caPool, ServerName: "localhost"}
+	if cn == "" {
+		return config
+	}
+
+	certDER, keyDER := f.sign(t, cn, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+	return config
+}
+
+func (f *Files) sign(t *testing.T, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, f.ca, &key.PublicKey, f.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certDER, keyDER
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, path, blockType string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
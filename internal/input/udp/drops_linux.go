@@ -0,0 +1,61 @@
+package inputudp
+
+import (
+	"encoding/binary"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// oobSize fits the control message with the drop counter
+var oobSize = unix.CmsgSpace(4)
+
+// enableDropCounter makes the kernel attach the number of datagrams it
+// dropped for the socket to every datagram (SO_RXQ_OVFL)
+func enableDropCounter(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RXQ_OVFL, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// kernelDrops returns the number of dropped datagrams of the control
+// messages of a datagram
+func kernelDrops(oob []byte) (uint32, bool) {
+	if len(oob) == 0 {
+		return 0, false
+	}
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, msg := range messages {
+		if msg.Header.Level == unix.SOL_SOCKET && msg.Header.Type == unix.SO_RXQ_OVFL && len(msg.Data) >= 4 {
+			return binary.NativeEndian.Uint32(msg.Data), true
+		}
+	}
+	return 0, false
+}
+
+// readBufferSize returns the receive buffer size the kernel granted. Linux
+// doubles the requested size for its bookkeeping, so half of it is returned.
+func readBufferSize(conn *net.UDPConn) (int, bool) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	size, sockErr := 0, error(nil)
+	if err := raw.Control(func(fd uintptr) {
+		size, sockErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF)
+	}); err != nil || sockErr != nil {
+		return 0, false
+	}
+	return size / 2, true
+}
@@ -0,0 +1,46 @@
+package inputudp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKernelDrops(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadBuffer(1))
+	require.NoError(t, enableDropCounter(conn))
+
+	// Nobody reads, so the tiny buffer overflows
+	sender, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer sender.Close()
+	for range 200 {
+		sender.Write(make([]byte, 1024))
+	}
+
+	// The datagrams carry the total at the time they were queued, so the
+	// queue is drained and the total is read from a later datagram
+	buffer := make([]byte, 2048)
+	oob := make([]byte, oobSize)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	for {
+		if _, _, _, _, err := conn.ReadMsgUDP(buffer, oob); err != nil {
+			break
+		}
+	}
+	_, err = sender.Write([]byte("later"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, oobn, _, _, err := conn.ReadMsgUDP(buffer, oob)
+	require.NoError(t, err)
+	drops, ok := kernelDrops(oob[:oobn])
+	assert.True(t, ok)
+	assert.Greater(t, drops, uint32(0))
+}
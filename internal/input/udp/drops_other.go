@@ -0,0 +1,20 @@
+//go:build !linux
+
+package inputudp
+
+import "net"
+
+// oobSize is 0 as only Linux reports dropped datagrams
+const oobSize = 0
+
+func enableDropCounter(*net.UDPConn) error {
+	return nil
+}
+
+func kernelDrops([]byte) (uint32, bool) {
+	return 0, false
+}
+
+func readBufferSize(*net.UDPConn) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,10 @@
+package inputudp
+
+import "github.com/MuchTitan/go-log-forwarder/internal/metrics"
+
+var (
+	droppedEvents = metrics.NewCounterVec("forwarder_udp_dropped_events_total",
+		"Events a udp input dropped, by reason. A datagram that is too large counts once.", "input", "reason")
+	kernelDropped = metrics.NewCounterVec("forwarder_udp_kernel_drops_total",
+		"Datagrams the kernel dropped because the receive buffer of a udp input was full. Only reported on Linux.", "input")
+)
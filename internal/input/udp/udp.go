@@ -0,0 +1,256 @@
+package inputudp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the udp input
+type Config struct {
+	Name               string          `config:"Name" default:"udp" desc:"The name of the input instance."`
+	Tag                string          `config:"Tag" default:"udp" desc:"A tag associated with the log events."`
+	ListenAddr         string          `config:"ListenAddr" default:"0.0.0.0" desc:"The address on which the udp input should listen on."`
+	Port               int             `config:"Port" default:"5170" min:"1" max:"65535" desc:"The port on which the udp input should listen on."`
+	ReceiveBufferSize  schema.ByteSize `config:"ReceiveBufferSize" desc:"The size of the receive buffer of the socket (SO_RCVBUF). Empty keeps the default of the OS, which caps the size, e.g. net.core.rmem_max on Linux."`
+	MaxDatagramSize    schema.ByteSize `config:"MaxDatagramSize" default:"64KiB" min:"1" max:"65536" desc:"The maximum size of a datagram. Larger datagrams are dropped."`
+	Readers            int             `config:"Readers" default:"2" min:"1" max:"64" desc:"The number of goroutines reading from the socket."`
+	SplitLines         bool            `config:"SplitLines" desc:"Turns every line of a datagram into an event instead of the whole datagram."`
+	MulticastGroup     string          `config:"MulticastGroup" desc:"A multicast group to join, e.g. 239.0.0.1. ListenAddr is not used then."`
+	MulticastInterface string          `config:"MulticastInterface" desc:"The name of the network interface the multicast group is joined on. Empty lets the OS choose."`
+}
+
+type UDP struct {
+	name              string
+	tag               string
+	addr              string
+	receiveBufferSize int
+	maxDatagramSize   int
+	readers           int
+	splitLines        bool
+	multicastGroup    net.IP
+	multicastIface    string
+	conn              *net.UDPConn
+	drops             *dropCounter
+	wg                sync.WaitGroup
+	ctx               context.Context
+	cancel            context.CancelFunc
+	output            chan<- internal.Event
+}
+
+func (u *UDP) Name() string {
+	return u.name
+}
+
+func (u *UDP) Tag() string {
+	return u.tag
+}
+
+func (u *UDP) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+
+	u.multicastGroup = nil
+	if cfg.MulticastGroup != "" {
+		if u.multicastGroup = net.ParseIP(cfg.MulticastGroup); u.multicastGroup == nil || !u.multicastGroup.IsMulticast() {
+			return fmt.Errorf("MulticastGroup: '%s' is no multicast address", cfg.MulticastGroup)
+		}
+	}
+
+	u.name = cfg.Name
+	u.tag = cfg.Tag
+	u.addr = net.JoinHostPort(cfg.ListenAddr, fmt.Sprint(cfg.Port))
+	u.receiveBufferSize = int(cfg.ReceiveBufferSize)
+	u.maxDatagramSize = int(cfg.MaxDatagramSize)
+	u.readers = cfg.Readers
+	u.splitLines = cfg.SplitLines
+	u.multicastIface = cfg.MulticastInterface
+
+	return nil
+}
+
+func (u *UDP) listen() (*net.UDPConn, error) {
+	if u.multicastGroup == nil {
+		addr, err := net.ResolveUDPAddr("udp", u.addr)
+		if err != nil {
+			return nil, err
+		}
+		return net.ListenUDP("udp", addr)
+	}
+
+	var iface *net.Interface
+	if u.multicastIface != "" {
+		var err error
+		if iface, err = net.InterfaceByName(u.multicastIface); err != nil {
+			return nil, fmt.Errorf("unknown multicast interface: %w", err)
+		}
+	}
+	_, port, _ := net.SplitHostPort(u.addr)
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(u.multicastGroup.String(), port))
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenMulticastUDP("udp", iface, addr)
+}
+
+func (u *UDP) Start(parentCtx context.Context, output chan<- internal.Event) error {
+	u.ctx, u.cancel = context.WithCancel(parentCtx)
+	u.output = output
+
+	conn, err := u.listen()
+	if err != nil {
+		return fmt.Errorf("couldn't start udp input: %w", err)
+	}
+	if u.receiveBufferSize > 0 {
+		if err := conn.SetReadBuffer(u.receiveBufferSize); err != nil {
+			conn.Close()
+			return fmt.Errorf("couldn't set udp receive buffer: %w", err)
+		}
+		if size, ok := readBufferSize(conn); ok && size < u.receiveBufferSize {
+			logrus.WithFields(logrus.Fields{
+				"name":      u.name,
+				"requested": u.receiveBufferSize,
+				"granted":   size,
+			}).Warn("The OS capped the udp receive buffer")
+		}
+	}
+	u.drops = &dropCounter{input: u.name}
+	if err := enableDropCounter(conn); err != nil {
+		logrus.WithError(err).Debug("Coundnt enable the kernel drop counter of the udp socket")
+	}
+	u.conn = conn
+
+	for range u.readers {
+		u.wg.Add(1)
+		go u.read(parentCtx)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":      u.name,
+		"addr":      conn.LocalAddr().String(),
+		"multicast": u.multicastGroup != nil,
+		"readers":   u.readers,
+	}).Info("Starting udp input")
+	return nil
+}
+
+// read reads datagrams until the socket is closed. The readers of an input
+// share the socket.
+func (u *UDP) read(parentCtx context.Context) {
+	defer u.wg.Done()
+
+	// One extra byte tells datagrams that were cut off by the buffer
+	buffer := make([]byte, u.maxDatagramSize+1)
+	oob := make([]byte, oobSize)
+	for {
+		n, oobn, _, addr, err := u.conn.ReadMsgUDP(buffer, oob)
+		if err != nil {
+			if u.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				input.ReportError(parentCtx, fmt.Errorf("udp socket closed: %w", err))
+				return
+			}
+			logrus.WithError(err).Debug("Coundnt read udp datagram")
+			continue
+		}
+		if drops, ok := kernelDrops(oob[:oobn]); ok {
+			u.drops.update(drops)
+		}
+		if n > u.maxDatagramSize {
+			droppedEvents.WithLabelValues(u.name, "too_large").Inc()
+			continue
+		}
+
+		source := ""
+		if addr != nil {
+			source = addr.String()
+		}
+		u.emit(buffer[:n], source)
+	}
+}
+
+// emit hands the datagram, or every line of it, to the engine
+func (u *UDP) emit(datagram []byte, source string) {
+	now := time.Now()
+	records := []string{strings.TrimRight(string(datagram), "\r\n")}
+	if u.splitLines {
+		records = strings.Split(string(datagram), "\n")
+	}
+
+	lineNum := 0
+	for _, record := range records {
+		record = strings.TrimSuffix(record, "\r")
+		if record == "" {
+			continue
+		}
+		if u.splitLines {
+			lineNum++
+		}
+		event := internal.Event{
+			Timestamp: now,
+			RawData:   record,
+			Metadata: internal.Metadata{
+				Source:  source,
+				LineNum: lineNum,
+			},
+		}
+		input.AddMetadata(&event, u)
+
+		select {
+		case u.output <- event:
+		case <-u.ctx.Done():
+			return
+		default:
+			droppedEvents.WithLabelValues(u.name, "pipeline_full").Inc()
+			logrus.WithField("source", source).Warn("udp event channel full, dropping event")
+		}
+	}
+}
+
+func (u *UDP) Exit() error {
+	logrus.WithField("name", u.name).Info("Stopping udp input")
+	if u.cancel != nil {
+		u.cancel()
+	}
+	if u.conn != nil {
+		if err := u.conn.Close(); err != nil {
+			logrus.WithError(err).Error("could not close udp socket")
+		}
+	}
+	u.wg.Wait()
+	u.conn = nil
+	return nil
+}
+
+// dropCounter turns the total of datagrams the kernel dropped, which every
+// datagram carries, into increments of the metric
+type dropCounter struct {
+	input string
+	mu    sync.Mutex
+	last  uint32
+}
+
+func (d *dropCounter) update(total uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// The total wraps around, the readers may see them out of order
+	delta := total - d.last
+	if delta == 0 || delta > 1<<31 {
+		return
+	}
+	d.last = total
+	kernelDropped.WithLabelValues(d.input).Add(int(delta))
+}
@@ -0,0 +1,115 @@
+package inputudp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/input/inputtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func send(t *testing.T, addr string, datagrams ...string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	for _, datagram := range datagrams {
+		_, err := conn.Write([]byte(datagram))
+		require.NoError(t, err)
+	}
+	return conn
+}
+
+func TestUDP_Init(t *testing.T) {
+	u := &UDP{}
+	require.NoError(t, u.Init(map[string]any{}))
+	assert.Equal(t, "udp", u.name)
+	assert.Equal(t, "0.0.0.0:5170", u.addr)
+	assert.Equal(t, 64<<10, u.maxDatagramSize)
+	assert.Equal(t, 2, u.readers)
+	assert.Nil(t, u.multicastGroup)
+
+	assert.EqualError(t, u.Init(map[string]any{"MulticastGroup": "10.0.0.1"}), "MulticastGroup: '10.0.0.1' is no multicast address")
+	assert.Error(t, u.Init(map[string]any{"MaxDatagramSize": "1MiB"}))
+	assert.Error(t, u.Init(map[string]any{"Readers": 0}))
+}
+
+func TestUDP_Datagrams(t *testing.T) {
+	u := &UDP{}
+	output := inputtest.Start(t, u, map[string]any{"ListenAddr": "127.0.0.1", "Port": inputtest.FreePort(t, "udp"), "Tag": "net", "ReceiveBufferSize": "1MiB"})
+	conn := send(t, u.conn.LocalAddr().String(), "first\nsecond\n", "third")
+
+	event := inputtest.Receive(t, output)
+	assert.Equal(t, "first\nsecond", event.RawData)
+	assert.Equal(t, "net", event.Metadata.Tag)
+	assert.Equal(t, conn.LocalAddr().String(), event.Metadata.Source)
+	assert.Equal(t, "third", inputtest.Receive(t, output).RawData)
+}
+
+func TestUDP_SplitLines(t *testing.T) {
+	u := &UDP{}
+	output := inputtest.Start(t, u, map[string]any{"ListenAddr": "127.0.0.1", "Port": inputtest.FreePort(t, "udp"), "SplitLines": true, "Readers": 1})
+	send(t, u.conn.LocalAddr().String(), "first\r\n\nsecond\n")
+
+	event := inputtest.Receive(t, output)
+	assert.Equal(t, "first", event.RawData)
+	assert.Equal(t, 1, event.Metadata.LineNum)
+	event = inputtest.Receive(t, output)
+	assert.Equal(t, "second", event.RawData)
+	assert.Equal(t, 2, event.Metadata.LineNum)
+}
+
+func TestUDP_TooLarge(t *testing.T) {
+	u := &UDP{}
+	output := inputtest.Start(t, u, map[string]any{"Name": "udp-too-large", "ListenAddr": "127.0.0.1", "Port": inputtest.FreePort(t, "udp"), "MaxDatagramSize": 8, "Readers": 1})
+	before := droppedEvents.WithLabelValues("udp-too-large", "too_large").Value()
+
+	send(t, u.conn.LocalAddr().String(), "123456789", "12345678")
+	assert.Equal(t, "12345678", inputtest.Receive(t, output).RawData)
+	assert.Equal(t, before+1, droppedEvents.WithLabelValues("udp-too-large", "too_large").Value())
+}
+
+func TestUDP_Multicast(t *testing.T) {
+	port := inputtest.FreePort(t, "udp")
+	u := &UDP{}
+	require.NoError(t, u.Init(map[string]any{"Port": port, "MulticastGroup": "239.255.42.99"}))
+	output := make(chan internal.Event, 10)
+	if err := u.Start(context.Background(), output); err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+	defer u.Exit()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP("239.255.42.99"), Port: port})
+	if err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("to the group")); err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+
+	select {
+	case event := <-output:
+		assert.Equal(t, "to the group", event.RawData)
+	case <-time.After(2 * time.Second):
+		t.Skip("multicast datagrams are not looped back on this host")
+	}
+}
+
+func TestDropCounter(t *testing.T) {
+	d := &dropCounter{input: "udp-drop-counter"}
+	counter := kernelDropped.WithLabelValues("udp-drop-counter")
+
+	d.update(3)
+	d.update(2) // Seen out of order by another reader
+	d.update(5)
+	assert.Equal(t, uint64(5), counter.Value())
+
+	d.last = 1<<32 - 1
+	d.update(1)
+	assert.Equal(t, uint64(7), counter.Value())
+}
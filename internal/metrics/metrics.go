@@ -0,0 +1,361 @@
+// Package metrics implements the counters, gauges and histograms of the
+// forwarder and exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultRegistry holds the metrics created with the package level constructors
+var DefaultRegistry = NewRegistry()
+
+// Registry is a set of metric families
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+type family interface {
+	write(w io.Writer) error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.families[name]; exists {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.families[name] = f
+}
+
+// WriteText writes all metrics in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	slices.Sort(names)
+
+	for _, name := range names {
+		r.mu.Lock()
+		f := r.families[name]
+		r.mu.Unlock()
+		if err := f.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vec holds the children of a metric family by their label values
+type vec[T any] struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+	newChild   func() *T
+	mu         sync.RWMutex
+	children   map[string]*T
+}
+
+func newVec[T any](name, help, typ string, labelNames []string, newChild func() *T) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		newChild:   newChild,
+		children:   make(map[string]*T),
+	}
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	child, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return child
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if child, ok = v.children[key]; !ok {
+		child = v.newChild()
+		v.children[key] = child
+	}
+	return child
+}
+
+func (v *vec[T]) delete(values []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.children, strings.Join(values, "\xff"))
+}
+
+// write writes the header and calls writeChild for every child in the order
+// of its label values
+func (v *vec[T]) write(w io.Writer, writeChild func(labels string, child *T) error) error {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	v.mu.RUnlock()
+	slices.Sort(keys)
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.typ); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		v.mu.RLock()
+		child := v.children[key]
+		v.mu.RUnlock()
+		if child == nil {
+			continue
+		}
+
+		var values []string
+		if len(v.labelNames) > 0 {
+			values = strings.Split(key, "\xff")
+		}
+		if err := writeChild(formatLabels(v.labelNames, values), child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(n int) {
+	if n > 0 {
+		c.value.Add(uint64(n))
+	}
+}
+
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec creates a counter family in the DefaultRegistry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labelNames, func() *Counter { return &Counter{} })}
+	r.register(name, c)
+	return c
+}
+
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	return c.with(values)
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	return c.vec.write(w, func(labels string, child *Counter) error {
+		_, err := fmt.Fprintf(w, "%s%s %d\n", c.name, labels, child.Value())
+		return err
+	})
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+}
+
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// NewGaugeVec creates a gauge family in the DefaultRegistry
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return DefaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labelNames, func() *Gauge { return &Gauge{} })}
+	r.register(name, g)
+	return g
+}
+
+func (g *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return g.with(values)
+}
+
+// DeleteLabelValues removes a gauge, e.g. for a file that doesn't exist anymore
+func (g *GaugeVec) DeleteLabelValues(values ...string) {
+	g.delete(values)
+}
+
+func (g *GaugeVec) write(w io.Writer) error {
+	return g.vec.write(w, func(labels string, child *Gauge) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", g.name, labels, formatFloat(child.Value()))
+		return err
+	})
+}
+
+// DefaultBuckets suit latencies in seconds from 1ms to 10s
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // Not cumulative, the last one is +Inf
+	count   atomic.Uint64
+	sumBits atomic.Uint64
+}
+
+func (h *Histogram) Observe(value float64) {
+	idx, _ := slices.BinarySearch(h.buckets, value)
+	h.counts[idx].Add(1)
+	h.count.Add(1)
+	for {
+		old := h.sumBits.Load()
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+type HistogramVec struct {
+	*vec[Histogram]
+}
+
+// NewHistogramVec creates a histogram family in the DefaultRegistry, buckets
+// are the sorted upper bounds
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{newVec(name, help, "histogram", labelNames, func() *Histogram {
+		return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets)+1)}
+	})}
+	r.register(name, h)
+	return h
+}
+
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	return h.vec.write(w, func(labels string, child *Histogram) error {
+		var cumulative uint64
+		for i := range len(child.buckets) + 1 {
+			cumulative += child.counts[i].Load()
+			le := "+Inf"
+			if i < len(child.buckets) {
+				le = formatFloat(child.buckets[i])
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, appendLabel(labels, "le", le), cumulative); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+			h.name, labels, formatFloat(math.Float64frombits(child.sumBits.Load())),
+			h.name, labels, cumulative)
+		return err
+	})
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		fmt.Fprintf(&builder, "%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	builder.WriteByte('}')
+	return builder.String()
+}
+
+func appendLabel(labels, name, value string) string {
+	label := fmt.Sprintf("%s=\"%s\"", name, escapeLabel(value))
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return labels[:len(labels)-1] + "," + label + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	events := r.NewCounterVec("test_events_total", "Events received.", "input", "tag")
+	depth := r.NewGaugeVec("test_queue_depth", "Queued events.", "output")
+
+	events.WithLabelValues("tail", "app").Add(3)
+	events.WithLabelValues("http", `say "hi"`).Inc()
+	depth.WithLabelValues("stdout").Set(2.5)
+	depth.WithLabelValues("splunk").Set(7)
+	depth.DeleteLabelValues("splunk")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+
+	expected := `# HELP test_events_total Events received.
+# TYPE test_events_total counter
+test_events_total{input="http",tag="say \"hi\""} 1
+test_events_total{input="tail",tag="app"} 3
+# HELP test_queue_depth Queued events.
+# TYPE test_queue_depth gauge
+test_queue_depth{output="stdout"} 2.5
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogramVec("test_duration_seconds", "Flush latency.", []float64{0.1, 1}, "output")
+
+	h := latency.WithLabelValues("stdout")
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(3)
+	assert.Equal(t, uint64(4), h.Count())
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+
+	expected := `# HELP test_duration_seconds Flush latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{output="stdout",le="0.1"} 2
+test_duration_seconds_bucket{output="stdout",le="1"} 3
+test_duration_seconds_bucket{output="stdout",le="+Inf"} 4
+test_duration_seconds_sum{output="stdout"} 3.65
+test_duration_seconds_count{output="stdout"} 4
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRegistry_RegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.")
+	assert.Panics(t, func() { r.NewGaugeVec("test_total", "Test.") })
+}
+
+func TestServer(t *testing.T) {
+	NewCounterVec("test_server_requests_total", "Test.").WithLabelValues().Inc()
+
+	s := NewServer("127.0.0.1:0")
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	resp, err := http.Get("http://" + s.Addr() + "/metrics")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "version=0.0.4")
+	assert.Contains(t, string(body), "test_server_requests_total 1\n")
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Handler serves the metrics of the registry in the Prometheus text format
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			logrus.WithError(err).Warn("could not write metrics")
+		}
+	})
+}
+
+// Server exposes the DefaultRegistry on /metrics
+type Server struct {
+	addr     string
+	server   *http.Server
+	listener net.Listener
+}
+
+func NewServer(addr string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler(DefaultRegistry))
+
+	return &Server{
+		addr: addr,
+		server: &http.Server{
+			Handler:     mux,
+			ReadTimeout: time.Second * 30,
+		},
+	}
+}
+
+// Start binds the listen address and serves requests in the background
+func (s *Server) Start() error {
+	var err error
+	s.listener, err = net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("couldn't start metrics server: %w", err)
+	}
+
+	logrus.WithField("Addr", s.listener.Addr().String()).Info("Starting metrics server")
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("error during metrics server")
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Stop() error {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer shutdownCancel()
+	return s.server.Shutdown(shutdownCtx)
+}
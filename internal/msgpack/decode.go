@@ -0,0 +1,289 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// timestampType is the extension type of the timestamps of MessagePack
+const timestampType = -1
+
+// maxDepth limits the nesting of arrays and maps
+const maxDepth = 100
+
+// ErrTooLarge is returned for values larger than the limit of a Decoder
+var ErrTooLarge = errors.New("msgpack: value too large")
+
+// Decoder reads MessagePack values from a stream
+type Decoder struct {
+	r         *bufio.Reader
+	maxSize   int
+	remaining int
+}
+
+// NewDecoder returns a decoder of the values of r. A value may not be
+// larger than maxSize bytes, 0 doesn't limit the size.
+func NewDecoder(r io.Reader, maxSize int) *Decoder {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+	return &Decoder{r: reader, maxSize: maxSize}
+}
+
+// Unmarshal decodes the single value of data
+func Unmarshal(data []byte) (any, error) {
+	d := NewDecoder(bytes.NewReader(data), 0)
+	v, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.r.ReadByte(); err != io.EOF {
+		return nil, errors.New("msgpack: trailing data after value")
+	}
+	return v, nil
+}
+
+// Decode reads the next value. It returns io.EOF if the stream ended
+// before the value and io.ErrUnexpectedEOF if it ended within it.
+func (d *Decoder) Decode() (any, error) {
+	d.remaining = d.maxSize
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+	v, err := d.decode(0)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// consume accounts n bytes to the size of the current value
+func (d *Decoder) consume(n int) error {
+	if d.maxSize == 0 {
+		return nil
+	}
+	if n > d.remaining {
+		return ErrTooLarge
+	}
+	d.remaining -= n
+	return nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	if err := d.consume(1); err != nil {
+		return 0, err
+	}
+	return d.r.ReadByte()
+}
+
+func (d *Decoder) readN(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrTooLarge
+	}
+	if err := d.consume(n); err != nil {
+		return nil, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	data, err := d.readN(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(data[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(data)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(data)), nil
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// readLength reads a length of size bytes, which can't be more than the
+// bytes left of the value
+func (d *Decoder) readLength(size int) (int, error) {
+	n, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 || (d.maxSize > 0 && int(n) > d.remaining) {
+		return 0, ErrTooLarge
+	}
+	return int(n), nil
+}
+
+func (d *Decoder) decode(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: values nested too deep")
+	}
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f:
+		return d.decodeMap(int(b&0x0f), depth)
+	case b >= 0x90 && b <= 0x9f:
+		return d.decodeArray(int(b&0x0f), depth)
+	case b >= 0xa0 && b <= 0xbf:
+		data, err := d.readN(int(b & 0x1f))
+		return string(data), err
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.readN(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xca:
+		bits, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := d.readUint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.readN(n)
+		return string(data), err
+	case 0xdc, 0xdd:
+		n, err := d.readLength(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.readLength(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: invalid format byte 0x%02x", b)
+}
+
+func (d *Decoder) decodeArray(n, depth int) ([]any, error) {
+	array := make([]any, 0, min(n, 1024))
+	for range n {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+	return array, nil
+}
+
+// decodeMap decodes a map, keys that are no strings are formatted with
+// fmt.Sprint
+func (d *Decoder) decodeMap(n, depth int) (map[string]any, error) {
+	m := make(map[string]any, min(n, 1024))
+	for range n {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch key := k.(type) {
+		case string:
+			m[key] = v
+		case []byte:
+			m[string(key)] = v
+		default:
+			m[fmt.Sprint(key)] = v
+		}
+	}
+	return m, nil
+}
+
+// decodeExt decodes an extension value with n bytes of data. EventTime
+// and timestamps become a time.Time.
+func (d *Decoder) decodeExt(n int) (any, error) {
+	typ, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.readN(n)
+	if err != nil {
+		return nil, err
+	}
+
+	switch int8(typ) {
+	case EventTimeType:
+		if n == 8 {
+			return time.Unix(int64(binary.BigEndian.Uint32(data)), int64(binary.BigEndian.Uint32(data[4:]))), nil
+		}
+	case timestampType:
+		switch n {
+		case 4:
+			return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+		case 8:
+			v := binary.BigEndian.Uint64(data)
+			return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+		case 12:
+			return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))), nil
+		}
+	}
+	return Ext{Type: int8(typ), Data: data}, nil
+}
@@ -0,0 +1,261 @@
+// Package msgpack encodes and decodes MessagePack, as far as the Fluent
+// Forward protocol needs it. Decoded maps have string keys, integers are
+// int64 or uint64 if they don't fit and the EventTime extension of Fluent
+// becomes a time.Time.
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// EventTimeType is the extension type of the EventTime of Fluent
+const EventTimeType = 0
+
+// Ext is an extension value of a type the package doesn't know
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Marshal returns the encoding of v
+func Marshal(v any) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append appends the encoding of v to b. A time.Time is encoded as
+// EventTime.
+func Append(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return AppendInt(b, int64(v)), nil
+	case int8:
+		return AppendInt(b, int64(v)), nil
+	case int16:
+		return AppendInt(b, int64(v)), nil
+	case int32:
+		return AppendInt(b, int64(v)), nil
+	case int64:
+		return AppendInt(b, v), nil
+	case uint:
+		return AppendUint(b, uint64(v)), nil
+	case uint8:
+		return AppendUint(b, uint64(v)), nil
+	case uint16:
+		return AppendUint(b, uint64(v)), nil
+	case uint32:
+		return AppendUint(b, uint64(v)), nil
+	case uint64:
+		return AppendUint(b, v), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return AppendInt(b, i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("msgpack: invalid number '%s'", v)
+		}
+		return Append(b, f)
+	case string:
+		return AppendString(b, v), nil
+	case []byte:
+		return AppendBytes(b, v), nil
+	case time.Time:
+		return AppendEventTime(b, v), nil
+	case Ext:
+		return AppendExt(b, v.Type, v.Data), nil
+	case []any:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			var err error
+			if b, err = Append(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]any:
+		b = AppendMapHeader(b, len(v))
+		for key, value := range v {
+			var err error
+			b = AppendString(b, key)
+			if b, err = Append(b, value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]string:
+		b = AppendMapHeader(b, len(v))
+		for key, value := range v {
+			b = AppendString(AppendString(b, key), value)
+		}
+		return b, nil
+	}
+	return appendReflect(b, reflect.ValueOf(v))
+}
+
+// appendReflect encodes the slices and maps of other types than the ones
+// Append handles
+func appendReflect(b []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		b = AppendArrayHeader(b, v.Len())
+		for i := range v.Len() {
+			var err error
+			if b, err = Append(b, v.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		b = AppendMapHeader(b, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var err error
+			b = AppendString(b, iter.Key().String())
+			if b, err = Append(b, iter.Value().Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return Append(b, v.Elem().Interface())
+	case reflect.String:
+		return AppendString(b, v.String()), nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type %s", v.Type())
+}
+
+// AppendInt appends an integer in the shortest format
+func AppendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return AppendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+// AppendUint appends an unsigned integer in the shortest format
+func AppendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+}
+
+func AppendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func AppendBytes(b []byte, data []byte) []byte {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, data...)
+}
+
+func AppendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+}
+
+func AppendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+}
+
+func AppendExt(b []byte, typ int8, data []byte) []byte {
+	n := len(data)
+	switch n {
+	case 1:
+		b = append(b, 0xd4)
+	case 2:
+		b = append(b, 0xd5)
+	case 4:
+		b = append(b, 0xd6)
+	case 8:
+		b = append(b, 0xd7)
+	case 16:
+		b = append(b, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xc8), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xc9), uint32(n))
+		}
+	}
+	return append(append(b, byte(typ)), data...)
+}
+
+// AppendEventTime appends t as EventTime, the seconds and nanoseconds since
+// the epoch as two 32 bit integers
+func AppendEventTime(b []byte, t time.Time) []byte {
+	data := binary.BigEndian.AppendUint32(make([]byte, 0, 8), uint32(t.Unix()))
+	data = binary.BigEndian.AppendUint32(data, uint32(t.Nanosecond()))
+	return AppendExt(b, EventTimeType, data)
+}
@@ -0,0 +1,123 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"nil", nil, "c0"},
+		{"bool", true, "c3"},
+		{"fixint", 5, "05"},
+		{"negative fixint", -3, "fd"},
+		{"uint8", 200, "ccc8"},
+		{"int16", -200, "d1ff38"},
+		{"uint32", 70000, "ce00011170"},
+		{"float64", 1.5, "cb3ff8000000000000"},
+		{"fixstr", "abc", "a3616263"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"bin", []byte{1, 2}, "c4020102"},
+		{"array", []any{1, "a"}, "9201a161"},
+		{"string slice", []string{"a"}, "91a161"},
+		{"map", map[string]any{"a": 1}, "81a16101"},
+		{"event time", time.Unix(1700000000, 5), "d7006553f10000000005"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, hex.EncodeToString(data))
+		})
+	}
+
+	_, err := Marshal(struct{}{})
+	assert.Error(t, err)
+}
+
+func TestRoundTrip(t *testing.T) {
+	value := map[string]any{
+		"nil":    nil,
+		"bool":   false,
+		"ints":   []any{int64(0), int64(-1), int64(-33), int64(math.MinInt64), int64(math.MaxInt64), uint64(math.MaxUint64)},
+		"float":  0.25,
+		"string": strings.Repeat("x", 70000),
+		"bytes":  bytes.Repeat([]byte{7}, 300),
+		"nested": map[string]any{"list": []any{map[string]any{}}},
+		"time":   time.Unix(1700000000, 123456789),
+		"ext":    Ext{Type: 5, Data: []byte{1, 2, 3}},
+	}
+
+	data, err := Marshal(value)
+	require.NoError(t, err)
+	decoded, err := Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, value, decoded)
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("stream", func(t *testing.T) {
+		var stream []byte
+		stream = AppendString(stream, "a")
+		stream = AppendInt(stream, 2)
+		d := NewDecoder(bytes.NewReader(stream), 0)
+
+		v, err := d.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, "a", v)
+		v, err = d.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), v)
+		_, err = d.Decode()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := NewDecoder(bytes.NewReader([]byte{0x92, 0x01}), 0).Decode()
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		data := AppendString(nil, strings.Repeat("a", 100))
+		_, err := NewDecoder(bytes.NewReader(data), 50).Decode()
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		// A length beyond the data isn't allocated
+		_, err = NewDecoder(bytes.NewReader([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}), 1024).Decode()
+		assert.ErrorIs(t, err, ErrTooLarge)
+	})
+
+	t.Run("map keys", func(t *testing.T) {
+		v, err := Unmarshal([]byte{0x82, 0x01, 0xa1, 'a', 0xc4, 0x01, 'b', 0x02})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"1": "a", "b": int64(2)}, v)
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		v, err := Unmarshal([]byte{0xd6, 0xff, 0x65, 0x53, 0xf1, 0x00})
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(1700000000, 0), v)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Unmarshal([]byte{0xc1})
+		assert.Error(t, err)
+		_, err = Unmarshal([]byte{0x01, 0x02})
+		assert.Error(t, err)
+		_, err = Unmarshal(bytes.Repeat([]byte{0x91}, maxDepth+2))
+		assert.Error(t, err)
+	})
+}
@@ -6,9 +6,17 @@ import (
 	"sync"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 )
 
+// Config is the configuration of the counter output
+type Config struct {
+	Name  string `config:"Name" default:"counter" desc:"The name of the output instance."`
+	Match string `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+}
+
 type Counter struct {
 	match string
 	name  string
@@ -21,25 +29,26 @@ func (c *Counter) Name() string {
 }
 
 func (c *Counter) Init(config map[string]any) error {
-	c.name = util.MustString(config["Name"])
-	if c.name == "" {
-		c.name = "counter"
-	}
-
-	c.match = util.MustString(config["Match"])
-	if c.match == "" {
-		c.match = "*"
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
+	c.name = cfg.Name
+	c.match = cfg.Match
 	c.mu = sync.Mutex{}
 
 	return nil
 }
 
+func (c *Counter) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, c.match)
+}
+
 func (c *Counter) IncrementCounter() uint64 {
 	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.count++
-	c.mu.Unlock()
 	return c.count
 }
 
@@ -53,10 +62,11 @@ func (c *Counter) Write(events []internal.Event) error {
 			"count": count,
 		}
 		jsonData, _ := json.Marshal(data)
-		_, err := fmt.Println(string(jsonData))
+		n, err := fmt.Println(string(jsonData))
 		if err != nil {
 			return err
 		}
+		output.BytesWritten.WithLabelValues(c.name).Add(n)
 	}
 	return nil
 }
@@ -0,0 +1,352 @@
+package outputforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/msgpack"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
+	"github.com/MuchTitan/go-log-forwarder/internal/util"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the forward output
+type Config struct {
+	Name         string        `config:"Name" default:"forward" desc:"The name of the output instance."`
+	Match        string        `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	Host         string        `config:"Host" default:"127.0.0.1" desc:"The ip address or hostname of the target Fluent Bit or fluentd."`
+	Port         int           `config:"Port" default:"24224" min:"1" max:"65535" desc:"The port of the target Fluent Bit or fluentd."`
+	Tag          string        `config:"Tag" desc:"The Fluent tag of the events. Empty sends every event with its own tag."`
+	LogKey       string        `config:"LogKey" default:"log" desc:"The key of the raw data in the record of events that weren't parsed."`
+	Compress     bool          `config:"Compress" desc:"Whether or not the entries should be compressed using gzip, which sends them in the CompressedPackedForward mode."`
+	RequireAck   bool          `config:"RequireAck" desc:"Whether or not every message has to be acknowledged by the server. Messages without an ack are sent again."`
+	AckTimeout   time.Duration `config:"AckTimeout" default:"30s" desc:"How long an ack is waited for."`
+	Timeout      time.Duration `config:"Timeout" default:"10s" desc:"The timeout of connecting, the handshake and sending a message."`
+	SharedKey    string        `config:"SharedKey" desc:"The key of the server. Enables the handshake when set."`
+	SelfHostname string        `config:"SelfHostname" desc:"The hostname the output tells the server in the handshake, the hostname of the machine by default."`
+	TLS          bool          `config:"TLS" desc:"Whether or not the connection uses tls."`
+	VerifyTLS    bool          `config:"VerifyTLS" desc:"Whether or not the log forwarder should verify tls."`
+	CAFile       string        `config:"CAFile" desc:"The CA certificates the certificate of the server is verified with in PEM format. Empty uses the ones of the system."`
+}
+
+type Forward struct {
+	name         string
+	match        string
+	addr         string
+	tag          string
+	logKey       string
+	compress     bool
+	requireAck   bool
+	ackTimeout   time.Duration
+	timeout      time.Duration
+	sharedKey    string
+	selfHostname string
+	tlsConfig    *tls.Config
+	// mu guards the connection, the workers of the engine send their
+	// messages one after the other
+	mu      sync.Mutex
+	conn    net.Conn
+	decoder *msgpack.Decoder
+}
+
+func (f *Forward) Name() string {
+	return f.name
+}
+
+func (f *Forward) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, f.match)
+}
+
+func (f *Forward) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
+	}
+
+	f.name = cfg.Name
+	f.match = cfg.Match
+	f.addr = net.JoinHostPort(cfg.Host, fmt.Sprint(cfg.Port))
+	f.tag = cfg.Tag
+	f.logKey = cfg.LogKey
+	f.compress = cfg.Compress
+	f.requireAck = cfg.RequireAck
+	f.ackTimeout = cfg.AckTimeout
+	f.timeout = cfg.Timeout
+	f.sharedKey = cfg.SharedKey
+	f.selfHostname = cfg.SelfHostname
+	if f.selfHostname == "" {
+		f.selfHostname, _ = os.Hostname()
+	}
+
+	f.tlsConfig = nil
+	if cfg.TLS {
+		f.tlsConfig = &tls.Config{
+			ServerName:         cfg.Host,
+			InsecureSkipVerify: !cfg.VerifyTLS,
+		}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return fmt.Errorf("cant read tls CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in %s", cfg.CAFile)
+			}
+			f.tlsConfig.RootCAs = pool
+		}
+	}
+
+	return nil
+}
+
+// Write sends the events with one message per tag. A connection that
+// failed is closed and opened again by the next call.
+func (f *Forward) Write(events []internal.Event) error {
+	var tags []string
+	entries := map[string][]internal.Event{}
+	for _, event := range events {
+		if !util.TagMatch(event.Metadata.Tag, f.match) {
+			continue
+		}
+		tag := f.tag
+		if tag == "" {
+			tag = event.Metadata.Tag
+		}
+		if _, exists := entries[tag]; !exists {
+			tags = append(tags, tag)
+		}
+		entries[tag] = append(entries[tag], event)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tag := range tags {
+		if err := f.send(tag, entries[tag]); err != nil {
+			f.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends the events of a tag and waits for the ack if required
+func (f *Forward) send(tag string, events []internal.Event) error {
+	if f.conn == nil {
+		if err := f.connect(); err != nil {
+			return err
+		}
+	}
+
+	chunk := ""
+	if f.requireAck {
+		id := make([]byte, 16)
+		rand.Read(id)
+		chunk = base64.StdEncoding.EncodeToString(id)
+	}
+	message, err := f.encode(tag, events, chunk)
+	if err != nil {
+		return err
+	}
+
+	if err := f.conn.SetWriteDeadline(time.Now().Add(f.timeout)); err != nil {
+		return err
+	}
+	if _, err := f.conn.Write(message); err != nil {
+		return fmt.Errorf("cant send forward message: %w", err)
+	}
+
+	if chunk != "" {
+		f.conn.SetReadDeadline(time.Now().Add(f.ackTimeout))
+		response, err := f.decoder.Decode()
+		f.conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			return fmt.Errorf("cant read forward ack: %w", err)
+		}
+		ack, _ := response.(map[string]any)
+		if id, _ := asString(ack["ack"]); id != chunk {
+			return fmt.Errorf("forward ack for chunk '%s' expected, got %v", chunk, response)
+		}
+	}
+
+	output.BytesWritten.WithLabelValues(f.name).Add(len(message))
+	return nil
+}
+
+// encode encodes the events in the Forward mode, or the
+// CompressedPackedForward mode if they are compressed
+func (f *Forward) encode(tag string, events []internal.Event, chunk string) ([]byte, error) {
+	var packed []byte
+	for _, event := range events {
+		var record any = event.ParsedData
+		if event.ParsedData == nil {
+			record = map[string]any{f.logKey: event.RawData}
+		}
+		var err error
+		packed = msgpack.AppendArrayHeader(packed, 2)
+		packed = msgpack.AppendEventTime(packed, event.Timestamp)
+		if packed, err = msgpack.Append(packed, record); err != nil {
+			return nil, fmt.Errorf("cant encode event: %w", err)
+		}
+	}
+
+	option := map[string]any{"size": len(events)}
+	if chunk != "" {
+		option["chunk"] = chunk
+	}
+
+	message := msgpack.AppendArrayHeader(nil, 3)
+	message = msgpack.AppendString(message, tag)
+	if f.compress {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(packed); err != nil {
+			return nil, fmt.Errorf("error during gzip compress: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		option["compressed"] = "gzip"
+		message = msgpack.AppendBytes(message, compressed.Bytes())
+	} else {
+		message = append(msgpack.AppendArrayHeader(message, len(events)), packed...)
+	}
+	return msgpack.Append(message, option)
+}
+
+// connect opens the connection and runs the handshake if a shared key is set
+func (f *Forward) connect() error {
+	dialer := &net.Dialer{Timeout: f.timeout}
+	var conn net.Conn
+	var err error
+	if f.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", f.addr, f.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", f.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("cant connect to %s: %w", f.addr, err)
+	}
+
+	f.conn = conn
+	f.decoder = msgpack.NewDecoder(conn, 1<<20)
+	if f.sharedKey != "" {
+		if err := f.handshake(); err != nil {
+			f.closeConn()
+			return fmt.Errorf("forward handshake with %s failed: %w", f.addr, err)
+		}
+	}
+	logrus.WithFields(logrus.Fields{"name": f.name, "addr": f.addr}).Debug("Connected forward output")
+	return nil
+}
+
+// handshake proves the knowledge of the shared key to the server. The
+// server sends a HELO with a nonce, the output answers with a PING holding
+// the digest of the key and the server confirms with a PONG holding its own
+// digest.
+func (f *Forward) handshake() error {
+	f.conn.SetDeadline(time.Now().Add(f.timeout))
+	defer f.conn.SetDeadline(time.Time{})
+
+	value, err := f.decoder.Decode()
+	if err != nil {
+		return fmt.Errorf("cant read HELO: %w", err)
+	}
+	helo, _ := value.([]any)
+	if len(helo) < 2 {
+		return errors.New("invalid HELO")
+	}
+	if kind, _ := asString(helo[0]); kind != "HELO" {
+		return fmt.Errorf("expected HELO, got '%s'", kind)
+	}
+	options, _ := helo[1].(map[string]any)
+	nonce, _ := asString(options["nonce"])
+	if auth, _ := asString(options["auth"]); auth != "" {
+		return errors.New("the server requires user authentication, which is not supported")
+	}
+
+	saltBytes := make([]byte, 16)
+	rand.Read(saltBytes)
+	salt := string(saltBytes)
+	ping, err := msgpack.Marshal([]any{"PING", f.selfHostname, saltBytes, digest(salt, f.selfHostname, nonce, f.sharedKey), "", ""})
+	if err != nil {
+		return err
+	}
+	if _, err := f.conn.Write(ping); err != nil {
+		return err
+	}
+
+	value, err = f.decoder.Decode()
+	if err != nil {
+		return fmt.Errorf("cant read PONG: %w", err)
+	}
+	pong, _ := value.([]any)
+	if len(pong) < 5 {
+		return errors.New("invalid PONG")
+	}
+	if ok, _ := pong[1].(bool); !ok {
+		reason, _ := asString(pong[2])
+		return fmt.Errorf("rejected by the server: %s", reason)
+	}
+	serverHostname, _ := asString(pong[3])
+	if serverDigest, _ := asString(pong[4]); serverDigest != digest(salt, serverHostname, nonce, f.sharedKey) {
+		return errors.New("the server doesn't know the shared key")
+	}
+	return nil
+}
+
+func (f *Forward) closeConn() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+		f.decoder = nil
+	}
+}
+
+// digest returns the hex encoded SHA-512 of the concatenated parts
+func digest(parts ...string) string {
+	h := sha512.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func asString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+func (f *Forward) Flush() error {
+	// Messages are sent in Write, so there is nothing buffered
+	return nil
+}
+
+func (f *Forward) Exit() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closeConn()
+	return nil
+}
@@ -0,0 +1,192 @@
+package outputforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/msgpack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve accepts one connection and hands it to handle, it returns the port
+// the server listens on
+func serve(t *testing.T, handle func(conn net.Conn, decoder *msgpack.Decoder)) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn, msgpack.NewDecoder(conn, 1<<20))
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func write(t *testing.T, conn net.Conn, value any) {
+	t.Helper()
+	data, err := msgpack.Marshal(value)
+	require.NoError(t, err)
+	_, err = conn.Write(data)
+	require.NoError(t, err)
+}
+
+func TestForward_Encode(t *testing.T) {
+	timestamp := time.Unix(1700000000, 500)
+	events := []internal.Event{
+		{Timestamp: timestamp, ParsedData: map[string]any{"msg": "parsed"}},
+		{Timestamp: timestamp, RawData: "raw line"},
+	}
+	want := []any{
+		[]any{timestamp, map[string]any{"msg": "parsed"}},
+		[]any{timestamp, map[string]any{"message": "raw line"}},
+	}
+
+	for _, compress := range []bool{false, true} {
+		f := &Forward{}
+		require.NoError(t, f.Init(map[string]any{"LogKey": "message", "Compress": compress}))
+
+		data, err := f.encode("app", events, "chunk-id")
+		require.NoError(t, err)
+		value, err := msgpack.Unmarshal(data)
+		require.NoError(t, err)
+		message := value.([]any)
+		require.Len(t, message, 3)
+		assert.Equal(t, "app", message[0])
+		option := message[2].(map[string]any)
+		assert.EqualValues(t, 2, option["size"])
+		assert.Equal(t, "chunk-id", option["chunk"])
+
+		if !compress {
+			assert.Nil(t, option["compressed"])
+			assert.Equal(t, want, message[1])
+			continue
+		}
+
+		// The CompressedPackedForward mode sends the entries as gzip
+		// compressed stream of MessagePack values
+		assert.Equal(t, "gzip", option["compressed"])
+		gz, err := gzip.NewReader(bytes.NewReader(message[1].([]byte)))
+		require.NoError(t, err)
+		decoder := msgpack.NewDecoder(gz, 1<<20)
+		var entries []any
+		for {
+			entry, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			entries = append(entries, entry)
+		}
+		assert.Equal(t, want, entries)
+	}
+}
+
+func TestForward_RequireAck(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(conn net.Conn, chunk string)
+		wantErr bool
+	}{
+		{"ack", func(conn net.Conn, chunk string) { write(t, conn, map[string]any{"ack": chunk}) }, false},
+		{"ack mismatch", func(conn net.Conn, chunk string) { write(t, conn, map[string]any{"ack": "other"}) }, true},
+		{"ack timeout", func(conn net.Conn, chunk string) {}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closed := make(chan struct{})
+			port := serve(t, func(conn net.Conn, decoder *msgpack.Decoder) {
+				value, err := decoder.Decode()
+				if err != nil {
+					return
+				}
+				option, _ := value.([]any)[2].(map[string]any)
+				chunk, _ := option["chunk"].(string)
+				tt.respond(conn, chunk)
+				// A connection without a valid ack is closed by the output
+				decoder.Decode()
+				close(closed)
+			})
+
+			f := &Forward{}
+			require.NoError(t, f.Init(map[string]any{"Port": port, "RequireAck": true, "AckTimeout": "100ms"}))
+			defer f.Exit()
+
+			err := f.Write([]internal.Event{{Timestamp: time.Now(), RawData: "line", Metadata: internal.Metadata{Tag: "app"}}})
+			if !tt.wantErr {
+				require.NoError(t, err)
+				assert.NotNil(t, f.conn)
+				return
+			}
+			require.Error(t, err)
+			assert.Nil(t, f.conn)
+			select {
+			case <-closed:
+			case <-time.After(2 * time.Second):
+				t.Fatal("connection not closed")
+			}
+		})
+	}
+}
+
+func TestForward_Handshake(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverKey string
+		wantErr   string
+	}{
+		{"shared key", "secret", ""},
+		{"wrong PONG digest", "other", "the server doesn't know the shared key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan any, 1)
+			port := serve(t, func(conn net.Conn, decoder *msgpack.Decoder) {
+				write(t, conn, []any{"HELO", map[string]any{"nonce": "nonce", "auth": ""}})
+				value, err := decoder.Decode()
+				if err != nil {
+					return
+				}
+				ping := value.([]any)
+				salt := string(ping[2].([]byte))
+				write(t, conn, []any{"PONG", true, "", "server", digest(salt, "server", "nonce", tt.serverKey)})
+
+				value, err = decoder.Decode()
+				if err == nil {
+					received <- value
+				}
+			})
+
+			f := &Forward{}
+			require.NoError(t, f.Init(map[string]any{"Port": port, "SharedKey": "secret", "SelfHostname": "client"}))
+			defer f.Exit()
+
+			err := f.Write([]internal.Event{{Timestamp: time.Now(), RawData: "line", Metadata: internal.Metadata{Tag: "app"}}})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				assert.Nil(t, f.conn)
+				return
+			}
+			require.NoError(t, err)
+			select {
+			case value := <-received:
+				assert.Equal(t, "app", value.([]any)[0])
+			case <-time.After(2 * time.Second):
+				t.Fatal("no message received")
+			}
+		})
+	}
+}
@@ -2,17 +2,28 @@ package outputgelf
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 	"github.com/sirupsen/logrus"
 
 	"gopkg.in/Graylog2/go-gelf.v2/gelf"
 )
 
+// Config is the configuration of the gelf output
+type Config struct {
+	Name    string `config:"Name" default:"gelf" desc:"The name of the output instance."`
+	Match   string `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	Mode    string `config:"Mode" default:"udp" enum:"udp,tcp" desc:"In which mode the gelf output should send."`
+	HostKey string `config:"HostKey,required" desc:"Key whose value is used as the name of the host, source or application that sent this message."`
+	Host    string `config:"Host" default:"127.0.0.1" desc:"The ip address or hostname of the target gelf service."`
+	Port    int    `config:"Port" default:"12201" min:"1" max:"65535" desc:"The port of the target gelf service."`
+}
+
 type GELF struct {
 	name    string
 	match   string
@@ -20,7 +31,6 @@ type GELF struct {
 	hostKey string
 	port    int
 	mode    string
-	buffer  []*gelf.Message
 	writer  gelf.Writer
 }
 
@@ -28,45 +38,22 @@ func (g *GELF) Name() string {
 	return g.name
 }
 
-func (g *GELF) Init(config map[string]any) error {
-	g.name = util.MustString(config["Name"])
-	if g.name == "" {
-		g.name = "gelf"
-	}
-
-	g.match = util.MustString(config["Match"])
-	if g.match == "" {
-		g.match = "*"
-	}
-
-	g.host = util.MustString(config["Host"])
-	if g.host == "" {
-		g.host = "127.0.0.1"
-	}
-
-	g.hostKey = util.MustString(config["HostKey"])
-	if g.hostKey == "" {
-		return errors.New("please provided a valid HostKey for the geld output")
-	}
-
-	g.mode = util.MustString(config["Mode"])
-	if g.mode == "" {
-		g.mode = "udp"
-	}
-	if g.mode != "udp" && g.mode != "tcp" {
-		return fmt.Errorf("mode: '%v' is not supported", g.mode)
-	}
+func (g *GELF) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, g.match)
+}
 
-	if portStr := config["Port"]; portStr != "" {
-		var ok bool
-		if g.port, ok = portStr.(int); !ok {
-			return errors.New("cant convert port to int")
-		}
-	} else {
-		g.port = 12201
+func (g *GELF) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	g.buffer = make([]*gelf.Message, 0, 100)
+	g.name = cfg.Name
+	g.match = cfg.Match
+	g.host = cfg.Host
+	g.hostKey = cfg.HostKey
+	g.mode = cfg.Mode
+	g.port = cfg.Port
 
 	return g.setupWriter()
 }
@@ -116,25 +103,17 @@ func (g *GELF) Write(events []internal.Event) error {
 			Extra:    make(map[string]any),
 		}
 
-		g.buffer = append(g.buffer, &msg)
-
-		if len(g.buffer) > 100 {
-			if err := g.Flush(); err != nil {
-				logrus.WithError(err).Error("could not flush gelf output")
-			}
+		if err := g.writer.WriteMessage(&msg); err != nil {
+			logrus.WithError(err).Error("could not write gelf message")
+			return err
 		}
+		output.BytesWritten.WithLabelValues(g.name).Add(len(jsonData))
 	}
 	return nil
 }
 
 func (g *GELF) Flush() error {
-	for _, data := range g.buffer {
-		err := g.writer.WriteMessage(data)
-		if err != nil {
-			return err
-		}
-	}
-	g.buffer = g.buffer[:0]
+	// Messages are sent in Write, so there is nothing buffered
 	return nil
 }
 
@@ -1,9 +1,17 @@
 package output
 
-import "github.com/MuchTitan/go-log-forwarder/internal"
+import (
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/metrics"
+)
 
 type Plugin interface {
 	internal.Plugin
 	Write(records []internal.Event) error
 	Flush() error
+	MatchTag(inputTag string) bool
 }
+
+// BytesWritten is incremented by the outputs with the bytes they sent
+var BytesWritten = metrics.NewCounterVec("forwarder_output_bytes_total",
+	"Bytes an output sent to its destination.", "output")
@@ -5,17 +5,35 @@ import (
 	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 	"github.com/sirupsen/logrus"
 )
 
+// Config is the configuration of the splunk output
+type Config struct {
+	Name            string         `config:"Name" default:"splunk" desc:"The name of the output instance."`
+	Match           string         `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	Token           string         `config:"Token,required" desc:"The token for the Splunk HTTP Event Collector interface."`
+	EventIndex      string         `config:"EventIndex,required" desc:"The name of the index on what the data should be indexed."`
+	Host            string         `config:"Host" default:"127.0.0.1" desc:"The ip address or hostname of the target Splunk service."`
+	Port            int            `config:"Port" default:"8088" min:"1" max:"65535" desc:"The port of the target Splunk service."`
+	Compress        bool           `config:"Compress" desc:"Whether or not the data should be compressed using gzip before sending."`
+	VerifyTLS       bool           `config:"VerifyTLS" desc:"Whether or not the log forwarder should verify tls."`
+	SendRaw         bool           `config:"SendRaw" desc:"Whether or not the data should be sent without parsing."`
+	EventHost       string         `config:"EventHost" desc:"The source field of a splunk event, the hostname by default."`
+	EventSourcetype string         `config:"EventSourcetype" default:"JSON" desc:"The sourcetype field of a splunk event."`
+	EventFields     map[string]any `config:"EventFields" desc:"Key value pairs that are appended to every splunk event. This is not supported when SendRaw is enabled."`
+}
+
 type Splunk struct {
 	name        string
 	token       string
@@ -30,7 +48,6 @@ type Splunk struct {
 	sendRaw     bool
 	httpClient  *http.Client
 	eventFields map[string]any
-	buffer      bytes.Buffer
 }
 
 func (s *Splunk) MatchTag(inputTag string) bool {
@@ -51,65 +68,29 @@ func (s *Splunk) Name() string {
 }
 
 func (s *Splunk) Init(config map[string]any) error {
-	// Required fields
-	s.token = util.MustString(config["Token"])
-	if s.token == "" {
-		return errors.New("splunk token is required")
-	}
-
-	s.index = util.MustString(config["EventIndex"])
-	if s.index == "" {
-		return errors.New("splunk index is required")
-	}
-
-	// Optional fields with defaults
-	s.name = util.MustString(config["Name"])
-	if s.name == "" {
-		s.name = "splunk"
-	}
-
-	s.match = util.MustString(config["Match"])
-	if s.match == "" {
-		s.match = "*"
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	s.host = util.MustString(config["Host"])
-	if s.host == "" {
-		s.host = "127.0.0.1"
-	}
+	s.token = cfg.Token
+	s.index = cfg.EventIndex
+	s.name = cfg.Name
+	s.match = cfg.Match
+	s.host = cfg.Host
+	s.port = cfg.Port
+	s.sourceType = cfg.EventSourcetype
+	s.eventFields = cfg.EventFields
+	s.compress = cfg.Compress
+	s.verifyTLS = cfg.VerifyTLS
+	s.sendRaw = cfg.SendRaw
 
-	s.eventHost = util.MustString(config["EventHost"])
+	s.eventHost = cfg.EventHost
 	if s.eventHost == "" {
 		hostname, _ := os.Hostname()
 		s.eventHost = hostname
 	}
 
-	s.sourceType = util.MustString(config["EventSourcetype"])
-	if s.sourceType == "" {
-		s.sourceType = "JSON"
-	}
-
-	if port, exists := config["Port"]; exists {
-		var ok bool
-		if s.port, ok = port.(int); !ok {
-			return errors.New("cant convert port to int")
-		}
-	} else {
-		s.port = 8088
-	}
-
-	if eventFieldsTmp, exists := config["EventFields"]; exists {
-		if eventFields, ok := eventFieldsTmp.(map[string]any); ok {
-			s.eventFields = eventFields
-		} else {
-			return errors.New("could not convert eventFields to map")
-		}
-	}
-
-	s.compress = config["Compress"] == true
-	s.verifyTLS = config["VerifyTLS"] == true
-	s.sendRaw = config["SendRaw"] == true
-
 	// Setup TLS
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
@@ -122,7 +103,6 @@ func (s *Splunk) Init(config map[string]any) error {
 		Timeout:   time.Second * 30,
 	}
 
-	s.buffer = bytes.Buffer{}
 	return nil
 }
 
@@ -148,16 +128,20 @@ func (s *Splunk) newSplunkEvent(event internal.Event) splunkEvent {
 	}
 
 	if len(event.ParsedData) != 0 {
-		splunkevent.Event = util.MergeMaps(event.ParsedData, s.eventFields)
+		// The parsed data is shared with the other outputs, so it is copied
+		// before the event fields are added
+		splunkevent.Event = util.MergeMaps(maps.Clone(event.ParsedData), s.eventFields)
 		AppendMetadata(&splunkevent, &event)
 	}
 
 	return splunkevent
 }
 
+// Write sends the events in a single request. Workers of the engine call
+// it concurrently, so it does not keep any state between calls.
 func (s *Splunk) Write(events []internal.Event) error {
-	// Convert all events to splunkEvents first
-	splunkEvents := make([]splunkEvent, 0, len(events))
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
 	for _, event := range events {
 		if !util.TagMatch(event.Metadata.Tag, s.match) {
 			continue
@@ -166,30 +150,20 @@ func (s *Splunk) Write(events []internal.Event) error {
 		if splunkevent.Event == nil {
 			continue
 		}
-		splunkEvents = append(splunkEvents, splunkevent)
-	}
-
-	data, err := json.Marshal(splunkEvents)
-	if err != nil {
-		return fmt.Errorf("failed to marshal events: %w", err)
-	}
-
-	s.buffer.Write(data)
-
-	if s.buffer.Len() > 100 {
-		if err := s.Flush(); err != nil {
-			return err
+		// HEC expects the events concatenated, not as a JSON array
+		if err := encoder.Encode(splunkevent); err != nil {
+			return fmt.Errorf("failed to marshal events: %w", err)
 		}
 	}
 
-	return nil
-}
-
-func (s *Splunk) Flush() error {
-	if s.buffer.Len() == 0 {
+	if buffer.Len() == 0 {
 		return nil
 	}
 
+	return s.send(&buffer)
+}
+
+func (s *Splunk) send(data *bytes.Buffer) error {
 	url := fmt.Sprintf("https://%s:%d/services/collector", s.host, s.port)
 	if s.sendRaw {
 		url += "/raw"
@@ -198,20 +172,19 @@ func (s *Splunk) Flush() error {
 	var requestBody bytes.Buffer
 	if s.compress {
 		gz := gzip.NewWriter(&requestBody)
-		if _, err := gz.Write(s.buffer.Bytes()); err != nil {
+		if _, err := gz.Write(data.Bytes()); err != nil {
 			return fmt.Errorf("error during gzip compress: %w", err)
 		}
 		if err := gz.Close(); err != nil {
 			return err
 		}
 	} else {
-		requestBody = s.buffer
+		requestBody = *data
 	}
 	var tmpDataString string
 	if logrus.IsLevelEnabled(logrus.TraceLevel) {
-		tmpDataString = s.buffer.String()
+		tmpDataString = data.String()
 	}
-	s.buffer.Reset()
 
 	req, _ := http.NewRequest("POST", url, &requestBody)
 	req.Header.Set("Authorization", "Splunk "+s.token)
@@ -220,6 +193,7 @@ func (s *Splunk) Flush() error {
 		req.Header.Set("Content-Encoding", "gzip")
 	}
 
+	bodySize := requestBody.Len()
 	res, err := s.httpClient.Do(req)
 	if err != nil {
 		return err
@@ -236,6 +210,12 @@ func (s *Splunk) Flush() error {
 		return fmt.Errorf("splunk returned status: %s", res.Status)
 	}
 
+	output.BytesWritten.WithLabelValues(s.name).Add(bodySize)
+	return nil
+}
+
+func (s *Splunk) Flush() error {
+	// Events are sent in Write, so there is nothing buffered
 	return nil
 }
 
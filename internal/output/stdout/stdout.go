@@ -2,20 +2,28 @@ package outputstdout
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
-	"slices"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/MuchTitan/go-log-forwarder/internal/output"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 )
 
-var ValidFormats = []string{"json", "plain", "template"}
+// Config is the configuration of the stdout output
+type Config struct {
+	Name       string `config:"Name" default:"stdout" desc:"The name of the output instance."`
+	Match      string `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	Format     string `config:"Format" default:"json" enum:"json,plain,template" desc:"The format that should be used."`
+	JsonIndent bool   `config:"JsonIndent" desc:"Whether or not the output in json format should be indented."`
+	Template   string `config:"Template" desc:"The text/template that should be used, selects the template format."`
+	Colors     bool   `config:"Colors" desc:"Whether or not the output should be colorized."`
+}
 
 type Stdout struct {
 	name       string
@@ -32,46 +40,20 @@ func (s *Stdout) Name() string {
 }
 
 func (s *Stdout) Init(config map[string]any) error {
-	// Set default format
-	s.name = util.MustString(config["Name"])
-	if s.name == "" {
-		s.name = "stdout"
-	}
-
-	s.match = util.MustString(config["Match"])
-	if s.match == "" {
-		s.match = "*"
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	s.format = util.MustString(config["Format"])
-	if s.format == "" {
-		s.format = "json"
-	}
-
-	if !slices.Contains(ValidFormats, s.format) {
-		return fmt.Errorf("not a valid format for stdout provided: %s", s.format)
-	}
-
-	// Configure JSON indentation
-	if indent, exists := config["JsonIndent"]; exists && s.format == "json" {
-		var ok bool
-		if s.jsonIndent, ok = indent.(bool); !ok {
-			return errors.New("cant convert json indent parameter to bool")
-		}
-	}
-
-	// Configure colors
-	if colors, exists := config["Colors"]; exists {
-		var ok bool
-		if s.colors, ok = colors.(bool); !ok {
-			return errors.New("cant convert colors parameter to bool")
-		}
-	}
+	s.name = cfg.Name
+	s.match = cfg.Match
+	s.format = cfg.Format
+	s.jsonIndent = cfg.JsonIndent && s.format == "json"
+	s.colors = cfg.Colors
 
 	// Parse custom template if provided
-	if templateTmp, exists := config["Template"]; exists && templateTmp != "" {
-		templateStr := util.MustString(templateTmp)
-		tmpl, err := template.New("output").Parse(templateStr)
+	if cfg.Template != "" {
+		tmpl, err := template.New("output").Parse(cfg.Template)
 		if err != nil {
 			return fmt.Errorf("failed to parse template: %v", err)
 		}
@@ -86,6 +68,9 @@ func (s *Stdout) Write(events []internal.Event) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	written := 0
+	defer func() { output.BytesWritten.WithLabelValues(s.name).Add(written) }()
+
 	for _, event := range events {
 		if !util.TagMatch(event.Metadata.Tag, s.match) {
 			return nil
@@ -112,7 +97,8 @@ func (s *Stdout) Write(events []internal.Event) error {
 			output = s.colorize(output)
 		}
 
-		fmt.Fprintln(os.Stdout, output)
+		n, _ := fmt.Fprintln(os.Stdout, output)
+		written += n
 	}
 
 	return nil
@@ -2,52 +2,69 @@ package parserjson
 
 import (
 	"encoding/json"
-	"fmt"
-	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 )
 
+// Config is the configuration of the json parser
+type Config struct {
+	Name       string `config:"Name" default:"json" desc:"The name of the parser instance."`
+	Match      string `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	TimeFormat string `config:"TimeFormat" format:"timeformat" default:"2006-01-02T15:04:05Z07:00" desc:"A time format to parse the timestamp into a valid internal representation."`
+	TimeKey    string `config:"TimeKey" desc:"The key under which the timestamp is found."`
+	parser.FieldOptions
+}
+
 type Json struct {
 	name       string
+	match      string
 	timeKey    string
 	timeFormat string
+	fields     parser.FieldOptions
 }
 
 func (j *Json) Name() string {
 	return j.name
 }
 
-func (j *Json) Init(config map[string]any) error {
-	j.name = util.MustString(config["Name"])
-	if j.name == "" {
-		j.name = "json"
-	}
+func (j *Json) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, j.match)
+}
 
-	j.timeKey = util.MustString(config["TimeKey"])
+func (j *Json) KeyName() string {
+	return j.fields.KeyName
+}
 
-	j.timeFormat = util.MustString(config["TimeFormat"])
-	if j.timeFormat != "" {
-		timeStr := time.Now().Format(j.timeFormat)
-		if timeStr == "invalid" {
-			return fmt.Errorf("not a valid time format in json Parser")
-		}
-	} else {
-		j.timeFormat = time.RFC3339
+func (j *Json) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
+	j.name = cfg.Name
+	j.match = cfg.Match
+	j.fields = cfg.FieldOptions
+	j.timeKey = cfg.TimeKey
+	j.timeFormat = cfg.TimeFormat
+
 	return nil
 }
 
 func (j *Json) Process(event *internal.Event) bool {
+	data, ok := j.fields.Source(event)
+	if !ok {
+		return false
+	}
+
 	var parsedData map[string]any
-	err := json.Unmarshal([]byte(event.RawData), &parsedData)
+	err := json.Unmarshal([]byte(data), &parsedData)
 	if err != nil {
 		return false
 	}
-	event.ParsedData = parsedData
+	j.fields.Apply(event, parsedData)
 
 	if j.timeFormat != "" && j.timeKey != "" {
 		parser.ExtractTime(event, j.timeKey, j.timeFormat)
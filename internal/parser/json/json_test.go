@@ -76,3 +76,104 @@ func TestJsonParser_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestJsonParser_MatchTag(t *testing.T) {
+	parser := &Json{}
+	assert.NoError(t, parser.Init(map[string]any{}))
+	assert.True(t, parser.MatchTag("any-tag"))
+
+	assert.NoError(t, parser.Init(map[string]any{"Match": "http*"}))
+	assert.True(t, parser.MatchTag("http-json"))
+	assert.False(t, parser.MatchTag("nginx"))
+}
+
+func TestJsonParser_KeyName(t *testing.T) {
+	dockerLine := func() *internal.Event {
+		return &internal.Event{
+			RawData: `{"log":"{\"level\":\"info\",\"msg\":\"started\"}","stream":"stdout"}`,
+			ParsedData: map[string]any{
+				"log":    `{"level":"info","msg":"started"}`,
+				"stream": "stdout",
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]any
+		inputEvent  *internal.Event
+		wantSuccess bool
+		wantParsed  map[string]any
+	}{
+		{
+			name:        "replace parsed data",
+			config:      map[string]any{"KeyName": "log"},
+			inputEvent:  dockerLine(),
+			wantSuccess: true,
+			wantParsed:  map[string]any{"level": "info", "msg": "started"},
+		},
+		{
+			name:        "reserve data",
+			config:      map[string]any{"KeyName": "log", "ReserveData": true},
+			inputEvent:  dockerLine(),
+			wantSuccess: true,
+			wantParsed:  map[string]any{"level": "info", "msg": "started", "stream": "stdout"},
+		},
+		{
+			name:        "reserve data and preserve key",
+			config:      map[string]any{"KeyName": "log", "ReserveData": true, "PreserveKey": true},
+			inputEvent:  dockerLine(),
+			wantSuccess: true,
+			wantParsed: map[string]any{
+				"level":  "info",
+				"msg":    "started",
+				"stream": "stdout",
+				"log":    `{"level":"info","msg":"started"}`,
+			},
+		},
+		{
+			name:        "preserve key",
+			config:      map[string]any{"KeyName": "log", "PreserveKey": true},
+			inputEvent:  dockerLine(),
+			wantSuccess: true,
+			wantParsed: map[string]any{
+				"level": "info",
+				"msg":   "started",
+				"log":   `{"level":"info","msg":"started"}`,
+			},
+		},
+		{
+			name:        "missing key",
+			config:      map[string]any{"KeyName": "message"},
+			inputEvent:  dockerLine(),
+			wantSuccess: false,
+			wantParsed:  dockerLine().ParsedData,
+		},
+		{
+			name:   "key is not a string",
+			config: map[string]any{"KeyName": "log"},
+			inputEvent: &internal.Event{
+				ParsedData: map[string]any{"log": map[string]any{"msg": "started"}},
+			},
+			wantSuccess: false,
+			wantParsed:  map[string]any{"log": map[string]any{"msg": "started"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &Json{}
+			assert.NoError(t, parser.Init(tt.config))
+
+			success := parser.Process(tt.inputEvent)
+			assert.Equal(t, tt.wantSuccess, success)
+			assert.Equal(t, tt.wantParsed, tt.inputEvent.ParsedData)
+		})
+	}
+}
+
+func TestJsonParser_InitFieldOptions(t *testing.T) {
+	parser := &Json{}
+	assert.Error(t, parser.Init(map[string]any{"ReserveData": "yes"}))
+	assert.Error(t, parser.Init(map[string]any{"PreserveKey": 1}))
+}
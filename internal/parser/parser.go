@@ -9,6 +9,61 @@ import (
 type Plugin interface {
 	internal.Plugin
 	Process(record *internal.Event) bool
+	MatchTag(inputTag string) bool
+}
+
+// KeyNamer is implemented by parsers that can decode a field of the parsed
+// data instead of the raw data
+type KeyNamer interface {
+	KeyName() string
+}
+
+// DecodesField reports whether the parser decodes a field of the parsed data.
+// Such parsers are applied on top of the parser that decoded the raw data.
+func DecodesField(p Plugin) bool {
+	k, ok := p.(KeyNamer)
+	return ok && k.KeyName() != ""
+}
+
+// FieldOptions decide which data of an event a parser decodes and how the
+// result is stored in the event. Parsers embed them in their Config.
+type FieldOptions struct {
+	KeyName     string `config:"KeyName" desc:"Decode this field of the already parsed data instead of the raw log line."`
+	ReserveData bool   `config:"ReserveData" desc:"Merge the decoded fields into the already parsed data instead of replacing it."`
+	PreserveKey bool   `config:"PreserveKey" desc:"Keep the KeyName field in the result."`
+}
+
+// Source returns the data the parser decodes. It returns false if the
+// KeyName field doesn't exist or isn't a string.
+func (o FieldOptions) Source(event *internal.Event) (string, bool) {
+	if o.KeyName == "" {
+		return event.RawData, true
+	}
+	value, ok := event.ParsedData[o.KeyName].(string)
+	return value, ok
+}
+
+// Apply stores the decoded data in the event
+func (o FieldOptions) Apply(event *internal.Event, decoded map[string]any) {
+	original, hasKey := event.ParsedData[o.KeyName]
+
+	if o.ReserveData && event.ParsedData != nil {
+		if o.KeyName != "" {
+			delete(event.ParsedData, o.KeyName)
+		}
+		for key, value := range decoded {
+			event.ParsedData[key] = value
+		}
+	} else {
+		event.ParsedData = decoded
+	}
+
+	if o.PreserveKey && o.KeyName != "" && hasKey {
+		if event.ParsedData == nil {
+			event.ParsedData = make(map[string]any)
+		}
+		event.ParsedData[o.KeyName] = original
+	}
 }
 
 func ExtractTime(event *internal.Event, timeKey, timeFormat string) {
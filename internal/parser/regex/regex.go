@@ -1,20 +1,32 @@
 package parserregex
 
 import (
-	"fmt"
 	"regexp"
-	"time"
 
 	"github.com/MuchTitan/go-log-forwarder/internal"
 	"github.com/MuchTitan/go-log-forwarder/internal/parser"
+	"github.com/MuchTitan/go-log-forwarder/internal/schema"
 	"github.com/MuchTitan/go-log-forwarder/internal/util"
 )
 
+// Config is the configuration of the regex parser
+type Config struct {
+	Name       string `config:"Name" default:"regex" desc:"The name of the parser instance."`
+	Match      string `config:"Match" default:"*" desc:"A string that matches one or more tags defined on an input. It supports * as a wildcard."`
+	Pattern    string `config:"Pattern,required" format:"regex" desc:"The regex pattern that should be applied to the log line."`
+	AllowEmpty bool   `config:"AllowEmpty" default:"true" desc:"Whether or not the parser should keep empty fields."`
+	TimeFormat string `config:"TimeFormat" format:"timeformat" default:"2006-01-02T15:04:05Z07:00" desc:"A time format to parse the timestamp into a valid internal representation."`
+	TimeKey    string `config:"TimeKey" desc:"The key under which the timestamp is found."`
+	parser.FieldOptions
+}
+
 type Regex struct {
 	name       string
+	match      string
 	re         *regexp.Regexp
 	timeKey    string
 	timeFormat string
+	fields     parser.FieldOptions
 	allowEmpty bool
 }
 
@@ -22,41 +34,38 @@ func (r *Regex) Name() string {
 	return r.name
 }
 
-func (r *Regex) Init(config map[string]any) error {
-	r.name = util.MustString(config["Name"])
-	if r.name == "" {
-		r.name = "regex"
-	}
+func (r *Regex) MatchTag(inputTag string) bool {
+	return util.TagMatch(inputTag, r.match)
+}
 
-	var err error
-	r.re, err = regexp.Compile(util.MustString(config["Pattern"]))
-	if err != nil {
-		return err
-	}
+func (r *Regex) KeyName() string {
+	return r.fields.KeyName
+}
 
-	if allowEmpty, ok := config["AllowEmpty"].(bool); ok {
-		r.allowEmpty = allowEmpty
-	} else {
-		r.allowEmpty = true
+func (r *Regex) Init(config map[string]any) error {
+	var cfg Config
+	if err := schema.Decode(config, &cfg); err != nil {
+		return err
 	}
 
-	r.timeKey = util.MustString(config["TimeKey"])
-
-	r.timeFormat = util.MustString(config["TimeFormat"])
-	if r.timeFormat != "" {
-		timeStr := time.Now().Format(r.timeFormat)
-		if timeStr == "invalid" {
-			return fmt.Errorf("not a valid time format in Regex Parser")
-		}
-	} else {
-		r.timeFormat = time.RFC3339
-	}
+	r.name = cfg.Name
+	r.match = cfg.Match
+	r.re = regexp.MustCompile(cfg.Pattern) // Checked while decoding
+	r.allowEmpty = cfg.AllowEmpty
+	r.fields = cfg.FieldOptions
+	r.timeKey = cfg.TimeKey
+	r.timeFormat = cfg.TimeFormat
 
 	return nil
 }
 
 func (r *Regex) Process(event *internal.Event) bool {
-	matches := r.re.FindStringSubmatch(event.RawData)
+	data, ok := r.fields.Source(event)
+	if !ok {
+		return false
+	}
+
+	matches := r.re.FindStringSubmatch(data)
 	if matches == nil {
 		return false
 	}
@@ -76,7 +85,7 @@ func (r *Regex) Process(event *internal.Event) bool {
 		}
 	}
 
-	event.ParsedData = decodedData
+	r.fields.Apply(event, decodedData)
 
 	if r.timeFormat != "" && r.timeKey != "" {
 		parser.ExtractTime(event, r.timeKey, r.timeFormat)
@@ -20,6 +20,7 @@ func TestRegexParser_Init(t *testing.T) {
 			name: "valid config with all fields",
 			config: map[string]any{
 				"Name":       "custom_regex",
+				"Match":      "nginx*",
 				"Pattern":    `(?P<level>\w+)\s+(?P<message>.+)`,
 				"TimeKey":    "timestamp",
 				"TimeFormat": time.RFC3339,
@@ -28,6 +29,7 @@ func TestRegexParser_Init(t *testing.T) {
 			wantError: false,
 			wantParser: Regex{
 				name:       "custom_regex",
+				match:      "nginx*",
 				timeKey:    "timestamp",
 				timeFormat: time.RFC3339,
 				allowEmpty: true,
@@ -42,6 +44,7 @@ func TestRegexParser_Init(t *testing.T) {
 			wantError: false,
 			wantParser: Regex{
 				name:       "regex",
+				match:      "*",
 				allowEmpty: true,
 				timeFormat: time.RFC3339,
 			},
@@ -75,6 +78,7 @@ func TestRegexParser_Init(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.wantParser.name, parser.name)
+				assert.Equal(t, tt.wantParser.match, parser.match)
 				assert.Equal(t, tt.wantParser.timeKey, parser.timeKey)
 				assert.Equal(t, tt.wantParser.timeFormat, parser.timeFormat)
 				assert.Equal(t, tt.wantParser.allowEmpty, parser.allowEmpty)
@@ -183,7 +187,33 @@ func TestRegexParser_Name(t *testing.T) {
 	assert.Equal(t, "test_parser", parser.Name())
 }
 
+func TestRegexParser_MatchTag(t *testing.T) {
+	parser := &Regex{match: "nginx*"}
+	assert.True(t, parser.MatchTag("nginx-access"))
+	assert.False(t, parser.MatchTag("http"))
+}
+
 func TestRegexParser_Exit(t *testing.T) {
 	parser := &Regex{}
 	assert.NoError(t, parser.Exit())
 }
+
+func TestRegexParser_KeyName(t *testing.T) {
+	parser := &Regex{}
+	assert.NoError(t, parser.Init(map[string]any{
+		"Pattern":     `^(?P<method>\S+) (?P<path>\S+)$`,
+		"KeyName":     "message",
+		"ReserveData": true,
+	}))
+
+	event := &internal.Event{
+		ParsedData: map[string]any{"message": "GET /health", "level": "info"},
+	}
+	assert.True(t, parser.Process(event))
+	assert.Equal(t, map[string]any{"method": "GET", "path": "/health", "level": "info"}, event.ParsedData)
+
+	// RawData is ignored when a KeyName is set
+	event = &internal.Event{RawData: "GET /health"}
+	assert.False(t, parser.Process(event))
+	assert.Nil(t, event.ParsedData)
+}
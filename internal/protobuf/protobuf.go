@@ -0,0 +1,140 @@
+// Package protobuf reads and writes the wire format of Protocol Buffers.
+// Messages are decoded field by field with a Reader, the schema is known to
+// the caller.
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The wire types of a field
+const (
+	Varint  = 0
+	Fixed64 = 1
+	Bytes   = 2
+	Fixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf: message truncated")
+
+// Reader reads the fields of an encoded message
+type Reader struct {
+	data []byte
+}
+
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Next reads the key of the next field. It returns false at the end of the
+// message. The value has to be read with the method of the wire type or
+// skipped.
+func (r *Reader) Next() (field int, wireType int, ok bool, err error) {
+	if len(r.data) == 0 {
+		return 0, 0, false, nil
+	}
+	key, err := r.Varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	field, wireType = int(key>>3), int(key&7)
+	if field <= 0 || key>>3 > math.MaxInt32 {
+		return 0, 0, false, fmt.Errorf("protobuf: invalid field number %d", key>>3)
+	}
+	return field, wireType, true, nil
+}
+
+func (r *Reader) Varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *Reader) Fixed64() (uint64, error) {
+	if len(r.data) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v, nil
+}
+
+func (r *Reader) Fixed32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+// Bytes reads a length delimited value, a string, bytes or an embedded
+// message. The returned slice shares the data of the message.
+func (r *Reader) Bytes() ([]byte, error) {
+	n, err := r.Varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.data)) {
+		return nil, errTruncated
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v, nil
+}
+
+// Skip skips the value of a field of the given wire type
+func (r *Reader) Skip(wireType int) error {
+	var err error
+	switch wireType {
+	case Varint:
+		_, err = r.Varint()
+	case Fixed64:
+		_, err = r.Fixed64()
+	case Bytes:
+		_, err = r.Bytes()
+	case Fixed32:
+		_, err = r.Fixed32()
+	default:
+		err = fmt.Errorf("protobuf: unsupported wire type %d", wireType)
+	}
+	return err
+}
+
+// AppendTag appends the key of a field
+func AppendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// AppendVarint appends a varint field
+func AppendVarint(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(AppendTag(b, field, Varint), v)
+}
+
+// AppendFixed64 appends a fixed64 field
+func AppendFixed64(b []byte, field int, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(AppendTag(b, field, Fixed64), v)
+}
+
+// AppendFixed32 appends a fixed32 field
+func AppendFixed32(b []byte, field int, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(AppendTag(b, field, Fixed32), v)
+}
+
+// AppendBytes appends a length delimited field
+func AppendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(AppendTag(b, field, Bytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendString appends a string field
+func AppendString(b []byte, field int, v string) []byte {
+	b = binary.AppendUvarint(AppendTag(b, field, Bytes), uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,87 @@
+package protobuf
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppend(t *testing.T) {
+	var b []byte
+	b = AppendVarint(b, 1, 150)
+	b = AppendString(b, 2, "testing")
+	b = AppendFixed64(b, 3, 1)
+	b = AppendFixed32(b, 4, 2)
+	assert.Equal(t, "089601120774657374696e6719010000000000000025"+"02000000", hex.EncodeToString(b))
+}
+
+func TestReader(t *testing.T) {
+	var nested []byte
+	nested = AppendString(nested, 1, "inner")
+
+	var b []byte
+	b = AppendVarint(b, 1, 150)
+	b = AppendBytes(b, 2, nested)
+	b = AppendFixed64(b, 3, 7)
+	b = AppendFixed32(b, 15, 9)
+	b = AppendVarint(b, 1000, 1)
+
+	r := NewReader(b)
+	field, wireType, ok, err := r.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 1, field)
+	assert.Equal(t, Varint, wireType)
+	v, err := r.Varint()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(150), v)
+
+	field, wireType, _, _ = r.Next()
+	assert.Equal(t, 2, field)
+	assert.Equal(t, Bytes, wireType)
+	data, err := r.Bytes()
+	require.NoError(t, err)
+	inner := NewReader(data)
+	inner.Next()
+	s, _ := inner.Bytes()
+	assert.Equal(t, "inner", string(s))
+
+	field, _, _, _ = r.Next()
+	assert.Equal(t, 3, field)
+	f64, _ := r.Fixed64()
+	assert.Equal(t, uint64(7), f64)
+
+	field, wireType, _, _ = r.Next()
+	assert.Equal(t, 15, field)
+	require.NoError(t, r.Skip(wireType))
+
+	field, wireType, _, _ = r.Next()
+	assert.Equal(t, 1000, field)
+	require.NoError(t, r.Skip(wireType))
+
+	_, _, ok, err = r.Next()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestReader_Invalid(t *testing.T) {
+	// A length beyond the message
+	r := NewReader([]byte{0x12, 0x05, 'a'})
+	r.Next()
+	_, err := r.Bytes()
+	assert.Error(t, err)
+
+	// Field number 0
+	_, _, _, err = NewReader([]byte{0x00}).Next()
+	assert.Error(t, err)
+
+	// Truncated varint
+	r = NewReader([]byte{0x08, 0x80})
+	r.Next()
+	_, err = r.Varint()
+	assert.Error(t, err)
+
+	assert.Error(t, NewReader(nil).Skip(3))
+}
@@ -0,0 +1,346 @@
+package queue
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSegmentSize int64 = 8 << 20 // 8MB
+	segmentExt               = ".seg"
+)
+
+// Queue is an append-only on-disk log of events split into segment files.
+// A segment file is removed as soon as every record in it was acknowledged.
+type Queue struct {
+	dir         string
+	segmentSize int64
+	mu          sync.Mutex
+	active      *segment
+	segments    map[uint64]*segment
+	nextID      uint64
+}
+
+type segment struct {
+	id    uint64
+	path  string
+	file  *os.File
+	size  int64
+	acked []bool
+	left  int
+}
+
+// Record references a single event persisted in the queue
+type Record struct {
+	queue *Queue
+	seg   *segment
+	index int
+}
+
+// Open opens or creates a queue in dir. Segments left over from a previous
+// run are kept and can be read back with Replay.
+func Open(dir string, segmentSize int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create queue directory: %w", err)
+	}
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+
+	q := &Queue{
+		dir:         dir,
+		segmentSize: segmentSize,
+		segments:    make(map[uint64]*segment),
+		nextID:      1,
+	}
+
+	ids, err := q.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		q.segments[id] = &segment{id: id, path: q.segmentPath(id)}
+		if id >= q.nextID {
+			q.nextID = id + 1
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"dir":      dir,
+		"segments": len(ids),
+	}).Debug("Opened disk queue")
+
+	return q, nil
+}
+
+func (q *Queue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+func (q *Queue) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read queue directory: %w", err)
+	}
+
+	var ids []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			logrus.WithField("file", name).Warn("ignoring unknown file in queue directory")
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Replay reads all segments that existed when the queue was opened and
+// calls fn for every record in them in the order they were written.
+// Each record has to be acknowledged like a freshly appended one.
+func (q *Queue) Replay(fn func(event internal.Event, record *Record)) error {
+	q.mu.Lock()
+	var replay []*segment
+	for _, seg := range q.segments {
+		if seg.file == nil && seg.acked == nil {
+			replay = append(replay, seg)
+		}
+	}
+	q.mu.Unlock()
+
+	slices.SortFunc(replay, func(a, b *segment) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	for _, seg := range replay {
+		events, err := readSegment(seg.path)
+		if err != nil {
+			return err
+		}
+
+		q.mu.Lock()
+		seg.acked = make([]bool, len(events))
+		seg.left = len(events)
+		q.mu.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"segment": seg.path,
+			"records": len(events),
+		}).Info("Replaying unacknowledged events from disk queue")
+
+		if len(events) == 0 {
+			q.mu.Lock()
+			q.removeSegment(seg)
+			q.mu.Unlock()
+			continue
+		}
+
+		for i, event := range events {
+			fn(event, &Record{queue: q, seg: seg, index: i})
+		}
+	}
+	return nil
+}
+
+func readSegment(path string) ([]internal.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open queue segment: %w", err)
+	}
+	defer file.Close()
+
+	var events []internal.Event
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var event internal.Event
+			if jsonErr := json.Unmarshal(line, &event); jsonErr != nil {
+				logrus.WithField("segment", path).WithError(jsonErr).Warn("skipping corrupt record in queue segment")
+			} else {
+				events = append(events, event)
+			}
+		} else if len(line) > 0 {
+			// A partial record is left behind when the process died mid write
+			logrus.WithField("segment", path).Warn("skipping truncated record in queue segment")
+		}
+		if err != nil {
+			break
+		}
+	}
+	return events, nil
+}
+
+// Append persists an event and returns the record that has to be
+// acknowledged once the event was delivered.
+func (q *Queue) Append(event internal.Event) (*Record, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode event: %w", err)
+	}
+	data = append(data, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active != nil && q.active.size+int64(len(data)) > q.segmentSize {
+		q.sealActive()
+	}
+
+	if q.active == nil {
+		if err := q.openSegment(); err != nil {
+			return nil, err
+		}
+	}
+
+	seg := q.active
+	if _, err := seg.file.Write(data); err != nil {
+		return nil, fmt.Errorf("could not write to queue segment: %w", err)
+	}
+	seg.size += int64(len(data))
+	seg.acked = append(seg.acked, false)
+	seg.left++
+
+	return &Record{queue: q, seg: seg, index: len(seg.acked) - 1}, nil
+}
+
+func (q *Queue) openSegment() error {
+	id := q.nextID
+	path := q.segmentPath(id)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("could not create queue segment: %w", err)
+	}
+	q.nextID++
+	q.active = &segment{id: id, path: path, file: file}
+	q.segments[id] = q.active
+	return nil
+}
+
+// sealActive closes the active segment, so a new one is started on the
+// next append. Fully acknowledged segments are removed right away.
+func (q *Queue) sealActive() {
+	seg := q.active
+	q.active = nil
+	if err := seg.file.Close(); err != nil {
+		logrus.WithField("segment", seg.path).WithError(err).Warn("could not close queue segment")
+	}
+	seg.file = nil
+	if seg.left == 0 {
+		q.removeSegment(seg)
+	}
+}
+
+func (q *Queue) removeSegment(seg *segment) {
+	delete(q.segments, seg.id)
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		logrus.WithField("segment", seg.path).WithError(err).Warn("could not remove queue segment")
+	}
+}
+
+// Ack marks the record as delivered. Acknowledging a record twice is a no-op.
+func (r *Record) Ack() {
+	if r == nil {
+		return
+	}
+	q := r.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seg := r.seg
+	if seg.acked == nil || seg.acked[r.index] {
+		return
+	}
+	seg.acked[r.index] = true
+	seg.left--
+
+	if seg.left == 0 && seg != q.active {
+		q.removeSegment(seg)
+	}
+}
+
+// Pending returns the number of records that are not acknowledged yet
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := 0
+	for _, seg := range q.segments {
+		pending += seg.left
+	}
+	return pending
+}
+
+// Close seals the active segment. Segments that still hold unacknowledged
+// records are rewritten to contain only those, so they are the only ones
+// replayed on the next start.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active != nil {
+		q.sealActive()
+	}
+
+	for _, seg := range q.segments {
+		if seg.acked == nil || seg.left == len(seg.acked) {
+			continue
+		}
+		if err := compactSegment(seg); err != nil {
+			logrus.WithField("segment", seg.path).WithError(err).Warn("could not compact queue segment")
+		}
+	}
+	return nil
+}
+
+func compactSegment(seg *segment) error {
+	events, err := readSegment(seg.path)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := seg.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i, event := range events {
+		if i < len(seg.acked) && seg.acked[i] {
+			continue
+		}
+		if err := encoder.Encode(event); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, seg.path)
+}
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MuchTitan/go-log-forwarder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(raw string) internal.Event {
+	return internal.Event{
+		Timestamp:  time.Date(2024, 2, 20, 15, 4, 5, 0, time.UTC),
+		RawData:    raw,
+		ParsedData: map[string]any{"message": raw},
+		Metadata:   internal.Metadata{Tag: "test", Source: "test.log", LineNum: 1},
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestQueue_AckRemovesSegment(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 0)
+	require.NoError(t, err)
+
+	r1, err := q.Append(newEvent("line1"))
+	require.NoError(t, err)
+	r2, err := q.Append(newEvent("line2"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, q.Pending())
+
+	r1.Ack()
+	r2.Ack()
+	r2.Ack()
+	assert.Equal(t, 0, q.Pending())
+
+	require.NoError(t, q.Close())
+	assert.Empty(t, segmentFiles(t, dir))
+}
+
+func TestQueue_RollsSegments(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 100)
+	require.NoError(t, err)
+
+	var records []*Record
+	for range 5 {
+		r, err := q.Append(newEvent("a line that is long enough to fill a segment"))
+		require.NoError(t, err)
+		records = append(records, r)
+	}
+	assert.Len(t, segmentFiles(t, dir), 5)
+
+	// Sealed segments are removed as soon as they are acknowledged
+	records[0].Ack()
+	records[1].Ack()
+	assert.Len(t, segmentFiles(t, dir), 3)
+
+	require.NoError(t, q.Close())
+}
+
+func TestQueue_ReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 0)
+	require.NoError(t, err)
+
+	r1, err := q.Append(newEvent("delivered"))
+	require.NoError(t, err)
+	_, err = q.Append(newEvent("pending1"))
+	require.NoError(t, err)
+	_, err = q.Append(newEvent("pending2"))
+	require.NoError(t, err)
+	r1.Ack()
+	require.NoError(t, q.Close())
+
+	q, err = Open(dir, 0)
+	require.NoError(t, err)
+
+	var replayed []internal.Event
+	var records []*Record
+	err = q.Replay(func(event internal.Event, record *Record) {
+		replayed = append(replayed, event)
+		records = append(records, record)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, replayed, 2)
+	assert.Equal(t, "pending1", replayed[0].RawData)
+	assert.Equal(t, "pending2", replayed[1].RawData)
+	assert.Equal(t, map[string]any{"message": "pending1"}, replayed[0].ParsedData)
+	assert.Equal(t, "test", replayed[0].Metadata.Tag)
+	assert.True(t, replayed[0].Timestamp.Equal(newEvent("").Timestamp))
+
+	// New events go to a new segment
+	_, err = q.Append(newEvent("new"))
+	require.NoError(t, err)
+	assert.Len(t, segmentFiles(t, dir), 2)
+
+	for _, record := range records {
+		record.Ack()
+	}
+	assert.Len(t, segmentFiles(t, dir), 1)
+	require.NoError(t, q.Close())
+}
+
+func TestQueue_ReplaySkipsTruncatedRecord(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 0)
+	require.NoError(t, err)
+	_, err = q.Append(newEvent("complete"))
+	require.NoError(t, err)
+
+	// Simulate a crash during a write
+	f, err := os.OpenFile(q.active.path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"RawData":"trunc`)
+	require.NoError(t, err)
+	f.Close()
+
+	q, err = Open(dir, 0)
+	require.NoError(t, err)
+
+	var replayed []string
+	err = q.Replay(func(event internal.Event, record *Record) {
+		replayed = append(replayed, event.RawData)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"complete"}, replayed)
+}
@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes, configured as a number of bytes or with a
+// unit like 64KiB or 5MB
+type ByteSize int64
+
+var byteUnits = map[string]int64{
+	"b":   1,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+}
+
+// ParseByteSize parses a size with a unit, e.g. 64KiB or 1.5MB. Units are
+// case-insensitive, KB is 1000 bytes and KiB 1024 bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if idx <= 0 {
+		return 0, errors.New("expected a number followed by a unit")
+	}
+
+	multiplier, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[idx:]))]
+	if !ok {
+		return 0, errors.New("unknown unit")
+	}
+	n, err := strconv.ParseFloat(s[:idx], 64)
+	if err != nil {
+		return 0, err
+	}
+	return ByteSize(n * float64(multiplier)), nil
+}
+
+func (b ByteSize) String() string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if int64(b) >= unit.size && int64(b)%unit.size == 0 {
+			return strconv.FormatInt(int64(b)/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
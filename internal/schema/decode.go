@@ -0,0 +1,373 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with a single field of a config
+type FieldError struct {
+	Field   string // Nested fields are joined with a dot, e.g. Retry.Jitter
+	Message string
+	Missing bool // A required field is missing
+	Unknown bool // The field isn't part of the schema
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Decode decodes config into the struct target points to. Missing fields
+// get their default, fields that aren't part of the struct are ignored. The
+// error holds a FieldError for every invalid field.
+func Decode(config map[string]any, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("schema: Decode needs a pointer to a struct, got %T", target))
+	}
+
+	errs := Of(target).decode(config, v.Elem(), "", false)
+	if len(errs) == 0 {
+		return nil
+	}
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return errors.Join(joined...)
+}
+
+// Check returns every problem of config, including fields that aren't
+// part of the schema
+func (s Schema) Check(config map[string]any) []*FieldError {
+	return s.decode(config, reflect.Value{}, "", true)
+}
+
+// decode checks config against the schema and sets the fields of target,
+// unless it is the zero Value. With strict unknown fields are reported.
+func (s Schema) decode(config map[string]any, target reflect.Value, prefix string, strict bool) []*FieldError {
+	var errs []*FieldError
+	for _, f := range s {
+		raw, exists := config[f.Name]
+		if !exists || raw == nil || raw == "" {
+			switch {
+			case f.Default != "":
+				raw = f.parseDefault()
+			case f.Required:
+				errs = append(errs, &FieldError{Field: prefix + f.Name, Message: "missing required field", Missing: true})
+				continue
+			case f.Kind == KindMap && f.Fields != nil:
+				// Nested fields may have defaults of their own
+				raw = map[string]any{}
+			default:
+				continue
+			}
+		}
+
+		if f.Kind == KindList {
+			errs = append(errs, f.decodeList(raw, target, prefix, strict)...)
+			continue
+		}
+
+		if f.Fields != nil {
+			nested, ok := raw.(map[string]any)
+			if !ok {
+				errs = append(errs, &FieldError{Field: prefix + f.Name, Message: mismatch(f.Kind, raw)})
+				continue
+			}
+			var nestedTarget reflect.Value
+			if target.IsValid() {
+				nestedTarget = fieldValue(target, f)
+			}
+			errs = append(errs, f.Fields.decode(nested, nestedTarget, prefix+f.Name+".", strict)...)
+			continue
+		}
+
+		value, err := f.convert(raw)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: prefix + f.Name, Message: err.Error()})
+			continue
+		}
+		if target.IsValid() && f.index != nil {
+			fieldValue(target, f).Set(value.Convert(f.typ))
+		}
+	}
+
+	if strict {
+		for _, key := range sortedKeys(config) {
+			if _, ok := s.Field(key); ok {
+				continue
+			}
+			msg := "unknown field"
+			if suggestion := s.suggest(key); suggestion != "" {
+				msg = fmt.Sprintf("unknown field, did you mean %s?", suggestion)
+			}
+			errs = append(errs, &FieldError{Field: prefix + key, Message: msg, Unknown: true})
+		}
+	}
+	return errs
+}
+
+// decodeList decodes a list of maps into a slice of structs. The items are
+// named by their index, e.g. Tokens[0].Tag.
+func (f Field) decodeList(raw any, target reflect.Value, prefix string, strict bool) []*FieldError {
+	items, ok := raw.([]any)
+	if !ok {
+		return []*FieldError{{Field: prefix + f.Name, Message: mismatch(f.Kind, raw)}}
+	}
+
+	var errs []*FieldError
+	var list reflect.Value
+	if target.IsValid() {
+		list = reflect.MakeSlice(f.typ, len(items), len(items))
+	}
+	for i, item := range items {
+		name := fmt.Sprintf("%s%s[%d]", prefix, f.Name, i)
+		nested, ok := item.(map[string]any)
+		if !ok {
+			errs = append(errs, &FieldError{Field: name, Message: mismatch(KindMap, item)})
+			continue
+		}
+		var itemTarget reflect.Value
+		if list.IsValid() {
+			itemTarget = list.Index(i)
+		}
+		errs = append(errs, f.Fields.decode(nested, itemTarget, name+".", strict)...)
+	}
+
+	if list.IsValid() {
+		fieldValue(target, f).Set(list)
+	}
+	return errs
+}
+
+// fieldValue returns the struct field of f in target, pointers on the way
+// are allocated
+func fieldValue(target reflect.Value, f Field) reflect.Value {
+	v := target.FieldByIndex(f.index)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// convert checks a value of a decoded config and converts it into the type of the field
+func (f Field) convert(raw any) (reflect.Value, error) {
+	switch f.Kind {
+	case KindInt:
+		n, ok := toInt(raw)
+		if !ok {
+			return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+		}
+		if err := f.checkBounds(float64(n), raw); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(n), nil
+
+	case KindFloat:
+		var n float64
+		switch v := raw.(type) {
+		case float64:
+			n = v
+		default:
+			i, ok := toInt(raw)
+			if !ok {
+				return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+			}
+			n = float64(i)
+		}
+		if err := f.checkBounds(n, raw); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(n), nil
+
+	case KindBool:
+		b, ok := raw.(bool)
+		if !ok {
+			return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+		}
+		return reflect.ValueOf(b), nil
+
+	case KindByteSize:
+		var size ByteSize
+		if str, ok := raw.(string); ok {
+			var err error
+			if size, err = ParseByteSize(str); err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid byte size '%s', expected a size like 64KiB", str)
+			}
+		} else if n, ok := toInt(raw); ok && n >= 0 {
+			size = ByteSize(n)
+		} else {
+			return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+		}
+		if err := f.checkBounds(float64(size), raw); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(size), nil
+
+	case KindStringList, KindRegexList:
+		list, ok := toStrings(raw)
+		if !ok {
+			return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+		}
+		if f.Kind == KindRegexList {
+			for _, item := range list {
+				if _, err := regexp.Compile(item); err != nil {
+					return reflect.Value{}, fmt.Errorf("invalid regular expression '%s': %v", item, err)
+				}
+			}
+		}
+		return reflect.ValueOf(list), nil
+
+	case KindMap:
+		m, ok := raw.(map[string]any)
+		if !ok {
+			return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+		}
+		return reflect.ValueOf(m), nil
+	}
+
+	// All other kinds are strings
+	str, ok := raw.(string)
+	if !ok {
+		return reflect.Value{}, errors.New(mismatch(f.Kind, raw))
+	}
+
+	switch f.Kind {
+	case KindDuration:
+		d, err := time.ParseDuration(str)
+		if err != nil || d <= 0 {
+			return reflect.Value{}, fmt.Errorf("invalid duration '%s', expected a positive duration like 30s", str)
+		}
+		if err := f.checkBounds(d.Seconds(), raw); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d), nil
+	case KindRegex:
+		if _, err := regexp.Compile(str); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid regular expression: %v", err)
+		}
+	case KindTimeFormat:
+		if !validTimeFormat(str) {
+			return reflect.Value{}, fmt.Errorf("invalid time format '%s', expected a Go layout like 2006-01-02T15:04:05Z07:00", str)
+		}
+	}
+
+	if len(f.Values) > 0 {
+		idx := slices.IndexFunc(f.Values, func(allowed string) bool { return strings.EqualFold(allowed, str) })
+		if idx == -1 {
+			return reflect.Value{}, fmt.Errorf("unsupported value '%s', expected one of %s", str, strings.Join(f.Values, ", "))
+		}
+		str = f.Values[idx]
+	}
+	return reflect.ValueOf(str), nil
+}
+
+func (f Field) checkBounds(n float64, raw any) error {
+	switch {
+	case f.Min != nil && f.Max != nil && (n < *f.Min || n > *f.Max):
+		return fmt.Errorf("has to be between %v and %v, got %v", *f.Min, *f.Max, raw)
+	case f.Min != nil && n < *f.Min:
+		return fmt.Errorf("has to be at least %v, got %v", *f.Min, raw)
+	case f.Max != nil && n > *f.Max:
+		return fmt.Errorf("has to be at most %v, got %v", *f.Max, raw)
+	}
+	return nil
+}
+
+func toInt(raw any) (int64, bool) {
+	switch v := raw.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toStrings(raw any) ([]string, bool) {
+	switch v := raw.(type) {
+	case []string:
+		return v, true
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, str)
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+func mismatch(kind Kind, raw any) string {
+	return fmt.Sprintf("expected %s, got %s", kind, Describe(raw))
+}
+
+// Describe names the type and value of a decoded config value for error messages
+func Describe(raw any) string {
+	switch v := raw.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return fmt.Sprintf("the string '%s'", v)
+	case bool:
+		return fmt.Sprintf("the bool %t", v)
+	case int, int32, int64, uint64:
+		return fmt.Sprintf("the int %d", v)
+	case float64:
+		return fmt.Sprintf("the number %v", v)
+	case map[string]any:
+		return "a map"
+	case []any, []string:
+		return "a list"
+	}
+	return fmt.Sprintf("%v", raw)
+}
+
+// validTimeFormat reports whether layout contains a time element and a
+// time formatted with it can be parsed back. The sample differs from the
+// reference time in every element, so a layout without any formats to itself.
+func validTimeFormat(layout string) bool {
+	sample := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	formatted := sample.Format(layout)
+	if formatted == layout {
+		return false
+	}
+	_, err := time.Parse(layout, formatted)
+	return err == nil
+}
+
+// suggest returns the field that only differs in case from name
+func (s Schema) suggest(name string) string {
+	for _, f := range s {
+		if strings.EqualFold(f.Name, name) {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
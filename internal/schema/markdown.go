@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders the schema as the parameter table of the docs. Nested
+// fields are listed with their full path, e.g. Retry.Jitter or Tokens[].Tag.
+func (s Schema) Markdown() string {
+	var builder strings.Builder
+	builder.WriteString("| Parameter          | Type     | Required | Default | Description |\n")
+	builder.WriteString("|-------------------|---------|----------|---------|-------------|\n")
+	s.writeRows(&builder, "")
+	return builder.String()
+}
+
+func (s Schema) writeRows(builder *strings.Builder, prefix string) {
+	for _, f := range s {
+		required := "No"
+		if f.Required {
+			required = "Yes"
+		}
+		defaultValue := "-"
+		switch {
+		case f.Default != "":
+			defaultValue = "`" + f.Default + "`"
+		case f.Kind == KindBool:
+			defaultValue = "`false`"
+		}
+
+		description := f.Description
+		if len(f.Values) > 0 {
+			description = strings.TrimSpace(fmt.Sprintf("%s Available options are `%s`.", description, strings.Join(f.Values, "`, `")))
+		}
+		fmt.Fprintf(builder, "| **%s%s** | %s | %s | %s | %s |\n", prefix, f.Name, f.Kind.Name(), required, defaultValue, description)
+
+		switch {
+		case f.Kind == KindList:
+			f.Fields.writeRows(builder, prefix+f.Name+"[].")
+		case f.Fields != nil:
+			f.Fields.writeRows(builder, prefix+f.Name+".")
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package schema decodes the config of a plugin into a typed struct. The
+// struct declares the fields of the config with tags:
+//
+//	type Config struct {
+//		Name    string        `config:"Name" default:"tcp" desc:"The name of the input instance."`
+//		Glob    string        `config:"Glob,required" desc:"The files to read."`
+//		Format  string        `config:"Format" default:"json" enum:"json,plain"`
+//		Timeout time.Duration `config:"Timeout" default:"10m" min:"1s"`
+//		Pattern string        `config:"Pattern" format:"regex"`
+//	}
+//
+// Durations are written like 30s, byte sizes like 64KiB, format marks
+// strings that hold a regular expression or a Go time layout. Embedded
+// structs add their fields, other structs are decoded from a nested map and
+// slices of structs from a list of maps.
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type a config field has to have
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindInt
+	KindFloat
+	KindBool
+	KindDuration   // A duration string like "30s"
+	KindByteSize   // A size like 64KiB or a number of bytes
+	KindRegex      // A regular expression
+	KindTimeFormat // A Go time layout like "2006-01-02"
+	KindStringList
+	KindRegexList // A list of regular expressions
+	KindMap
+	KindList // A list of maps decoded into structs
+)
+
+// String describes the kind for error messages, e.g. "an int"
+func (k Kind) String() string {
+	switch k {
+	case KindInt:
+		return "an int"
+	case KindFloat:
+		return "a number"
+	case KindBool:
+		return "a bool"
+	case KindDuration:
+		return "a duration like 30s"
+	case KindByteSize:
+		return "a byte size like 64KiB"
+	case KindRegex:
+		return "a regular expression"
+	case KindTimeFormat:
+		return "a time format like 2006-01-02T15:04:05Z07:00"
+	case KindStringList:
+		return "a list of strings"
+	case KindRegexList:
+		return "a list of regular expressions"
+	case KindMap:
+		return "a map"
+	case KindList:
+		return "a list of maps"
+	default:
+		return "a string"
+	}
+}
+
+// Name is the type of the kind as shown in the docs
+func (k Kind) Name() string {
+	switch k {
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "number"
+	case KindBool:
+		return "boolean"
+	case KindDuration:
+		return "duration"
+	case KindByteSize:
+		return "byte size"
+	case KindRegex:
+		return "regex"
+	case KindTimeFormat:
+		return "time format"
+	case KindStringList:
+		return "list of strings"
+	case KindRegexList:
+		return "list of regexes"
+	case KindMap:
+		return "map"
+	case KindList:
+		return "list of maps"
+	default:
+		return "string"
+	}
+}
+
+// Field describes a key of a config
+type Field struct {
+	Name        string
+	Kind        Kind
+	Required    bool
+	Default     string   // As written in the config, empty if there is none
+	Values      []string // Allowed values of an enum, compared case-insensitively
+	Min, Max    *float64 // Bounds of numbers, durations in seconds
+	Description string
+	Fields      Schema // Keys of a KindMap or the items of a KindList decoded into a struct, nil allows any key
+
+	index []int        // Index of the struct field
+	typ   reflect.Type // Type of the struct field, pointers are dereferenced
+}
+
+// Schema is the ordered list of the fields of a config
+type Schema []Field
+
+// Field returns the field called name
+func (s Schema) Field(name string) (Field, bool) {
+	idx := slices.IndexFunc(s, func(f Field) bool { return f.Name == name })
+	if idx == -1 {
+		return Field{}, false
+	}
+	return s[idx], true
+}
+
+// With returns the fields of s followed by the fields of other
+func (s Schema) With(other Schema) Schema {
+	return append(slices.Clip(s), other...)
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	byteSizeType = reflect.TypeFor[ByteSize]()
+	mapType      = reflect.TypeFor[map[string]any]()
+)
+
+// Of returns the schema of the config struct v. It panics if a field has a
+// type or tag that isn't supported, as that is a programming error.
+func Of(v any) Schema {
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("schema: %s is not a struct", typ))
+	}
+	return fieldsOf(typ, nil)
+}
+
+func fieldsOf(typ reflect.Type, index []int) Schema {
+	var s Schema
+	for i := range typ.NumField() {
+		sf := typ.Field(i)
+		fieldIndex := append(slices.Clip(index), i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			s = append(s, fieldsOf(sf.Type, fieldIndex)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		f, ok := fieldOf(sf)
+		if !ok {
+			continue
+		}
+		f.index = fieldIndex
+		s = append(s, f)
+	}
+	return s
+}
+
+func fieldOf(sf reflect.StructField) (Field, bool) {
+	name, opts := sf.Name, ""
+	if tag, ok := sf.Tag.Lookup("config"); ok {
+		name, opts, _ = strings.Cut(tag, ",")
+	} else if tag, ok := sf.Tag.Lookup("yaml"); ok {
+		name, _, _ = strings.Cut(tag, ",")
+	}
+	if name == "-" {
+		return Field{}, false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+
+	f := Field{
+		Name:        name,
+		Required:    opts == "required",
+		Default:     sf.Tag.Get("default"),
+		Description: sf.Tag.Get("desc"),
+		typ:         sf.Type,
+	}
+	for f.typ.Kind() == reflect.Pointer {
+		f.typ = f.typ.Elem()
+	}
+	if values := sf.Tag.Get("enum"); values != "" {
+		f.Values = strings.Split(values, ",")
+	}
+	f.Min = bound(sf, "min")
+	f.Max = bound(sf, "max")
+
+	format := sf.Tag.Get("format")
+	switch {
+	case f.typ == durationType:
+		f.Kind = KindDuration
+	case f.typ == byteSizeType:
+		f.Kind = KindByteSize
+	case f.typ.Kind() == reflect.String && format == "regex":
+		f.Kind = KindRegex
+	case f.typ.Kind() == reflect.String && format == "timeformat":
+		f.Kind = KindTimeFormat
+	case f.typ.Kind() == reflect.String:
+		f.Kind = KindString
+	case f.typ.Kind() >= reflect.Int && f.typ.Kind() <= reflect.Int64:
+		f.Kind = KindInt
+	case f.typ.Kind() == reflect.Float64:
+		f.Kind = KindFloat
+	case f.typ.Kind() == reflect.Bool:
+		f.Kind = KindBool
+	case f.typ.Kind() == reflect.Slice && f.typ.Elem().Kind() == reflect.String && format == "regex":
+		f.Kind = KindRegexList
+	case f.typ.Kind() == reflect.Slice && f.typ.Elem().Kind() == reflect.String:
+		f.Kind = KindStringList
+	case f.typ.Kind() == reflect.Slice && f.typ.Elem().Kind() == reflect.Struct:
+		f.Kind = KindList
+		f.Fields = fieldsOf(f.typ.Elem(), nil)
+	case f.typ == mapType:
+		f.Kind = KindMap
+	case f.typ.Kind() == reflect.Struct:
+		f.Kind = KindMap
+		f.Fields = fieldsOf(f.typ, nil)
+	default:
+		panic(fmt.Sprintf("schema: field %s has the unsupported type %s", sf.Name, sf.Type))
+	}
+
+	if f.Default != "" {
+		if _, err := f.convert(f.parseDefault()); err != nil {
+			panic(fmt.Sprintf("schema: invalid default of field %s: %v", sf.Name, err))
+		}
+	}
+	return f, true
+}
+
+// bound reads the min or max tag of a struct field
+func bound(sf reflect.StructField, tag string) *float64 {
+	value, ok := sf.Tag.Lookup(tag)
+	if !ok {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && sf.Type == durationType {
+		seconds := d.Seconds()
+		return &seconds
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(fmt.Sprintf("schema: invalid %s of field %s: %v", tag, sf.Name, err))
+	}
+	return &n
+}
+
+// parseDefault converts the default tag into the value it would have in a config
+func (f Field) parseDefault() any {
+	switch f.Kind {
+	case KindInt:
+		if n, err := strconv.Atoi(f.Default); err == nil {
+			return n
+		}
+	case KindFloat:
+		if n, err := strconv.ParseFloat(f.Default, 64); err == nil {
+			return n
+		}
+	case KindBool:
+		if b, err := strconv.ParseBool(f.Default); err == nil {
+			return b
+		}
+	case KindStringList, KindRegexList:
+		var list []any
+		for _, item := range strings.Split(f.Default, ",") {
+			list = append(list, item)
+		}
+		return list
+	}
+	return f.Default
+}
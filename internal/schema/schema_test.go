@@ -0,0 +1,182 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRetry struct {
+	MaxAttempts int      `config:"MaxAttempts" default:"3" min:"1"`
+	Jitter      *float64 `config:"Jitter" min:"0" max:"1"`
+}
+
+type testOptions struct {
+	Tag string `config:"Tag" default:"test"`
+}
+
+type testConfig struct {
+	testOptions
+	Name       string         `config:"Name,required"`
+	Port       int            `config:"Port" default:"8080" min:"1" max:"65535"`
+	Format     string         `config:"Format" default:"json" enum:"json,plain"`
+	Enabled    bool           `config:"Enabled"`
+	Timeout    time.Duration  `config:"Timeout" default:"10m"`
+	BufferSize ByteSize       `config:"BufferSize" default:"64KiB"`
+	Patterns   []string       `config:"Patterns" format:"regex"`
+	Layout     string         `config:"Layout" format:"timeformat"`
+	Fields     map[string]any `config:"Fields"`
+	Retry      testRetry      `config:"Retry"`
+	ignored    string
+}
+
+func TestDecode(t *testing.T) {
+	var cfg testConfig
+	require.NoError(t, Decode(map[string]any{
+		"Name":       "app",
+		"Format":     "PLAIN",
+		"Enabled":    true,
+		"Timeout":    "30s",
+		"BufferSize": "1MiB",
+		"Patterns":   []any{"^a", "b$"},
+		"Layout":     "2006-01-02",
+		"Fields":     map[string]any{"env": "prod"},
+		"Retry":      map[string]any{"Jitter": 0},
+		"Unknown":    "ignored",
+	}, &cfg))
+
+	jitter := 0.0
+	assert.Equal(t, testConfig{
+		testOptions: testOptions{Tag: "test"},
+		Name:        "app",
+		Port:        8080,
+		Format:      "plain",
+		Enabled:     true,
+		Timeout:     30 * time.Second,
+		BufferSize:  1 << 20,
+		Patterns:    []string{"^a", "b$"},
+		Layout:      "2006-01-02",
+		Fields:      map[string]any{"env": "prod"},
+		Retry:       testRetry{MaxAttempts: 3, Jitter: &jitter},
+	}, cfg)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	var cfg testConfig
+	err := Decode(map[string]any{
+		"Port":       "8080",
+		"Format":     "xml",
+		"Timeout":    "soon",
+		"BufferSize": "lots",
+		"Patterns":   []any{"[a-"},
+		"Layout":     "yesterday",
+		"Retry":      map[string]any{"MaxAttempts": 0, "Jitter": 2},
+	}, &cfg)
+
+	assert.EqualError(t, err, `Name: missing required field
+Port: expected an int, got the string '8080'
+Format: unsupported value 'xml', expected one of json, plain
+Timeout: invalid duration 'soon', expected a positive duration like 30s
+BufferSize: invalid byte size 'lots', expected a size like 64KiB
+Patterns: invalid regular expression '[a-': error parsing regexp: missing closing ]: `+"`[a-`"+`
+Layout: invalid time format 'yesterday', expected a Go layout like 2006-01-02T15:04:05Z07:00
+Retry.MaxAttempts: has to be at least 1, got 0
+Retry.Jitter: has to be between 0 and 1, got 2`)
+}
+
+func TestDecode_List(t *testing.T) {
+	type user struct {
+		Name string `config:"Name,required"`
+		Role string `config:"Role" default:"reader" enum:"reader,writer"`
+	}
+	var cfg struct {
+		Users []user `config:"Users"`
+	}
+
+	require.NoError(t, Decode(map[string]any{
+		"Users": []any{map[string]any{"Name": "anna"}, map[string]any{"Name": "ben", "Role": "Writer"}},
+	}, &cfg))
+	assert.Equal(t, []user{{"anna", "reader"}, {"ben", "writer"}}, cfg.Users)
+
+	err := Decode(map[string]any{"Users": []any{map[string]any{"Role": "admin"}, "carl"}}, &cfg)
+	assert.EqualError(t, err, `Users[0].Name: missing required field
+Users[0].Role: unsupported value 'admin', expected one of reader, writer
+Users[1]: expected a map, got the string 'carl'`)
+
+	errs := Of(cfg).Check(map[string]any{"Users": []any{map[string]any{"Name": "anna", "Group": "x"}}})
+	assert.Equal(t, []*FieldError{{Field: "Users[0].Group", Message: "unknown field", Unknown: true}}, errs)
+
+	assert.Contains(t, Of(cfg).Markdown(), "| **Users[].Role** | string | No | `reader` |")
+}
+
+func TestSchema_Check(t *testing.T) {
+	errs := Of(testConfig{}).Check(map[string]any{
+		"Name":   "app",
+		"name":   "app",
+		"Colour": true,
+		"Retry":  map[string]any{"Attempts": 1},
+	})
+
+	assert.Equal(t, []*FieldError{
+		{Field: "Retry.Attempts", Message: "unknown field", Unknown: true},
+		{Field: "Colour", Message: "unknown field", Unknown: true},
+		{Field: "name", Message: "unknown field, did you mean Name?", Unknown: true},
+	}, errs)
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ByteSize
+		wantErr bool
+	}{
+		{"64KiB", 64 << 10, false},
+		{"5MB", 5000000, false},
+		{"1.5 gib", 3 << 29, false},
+		{"10b", 10, false},
+		{"1024", 0, true},
+		{"KiB", 0, true},
+		{"5XB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseByteSize(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSchema_Markdown(t *testing.T) {
+	s := Of(struct {
+		Mode  string    `config:"Mode" default:"udp" enum:"udp,tcp" desc:"The protocol."`
+		Host  string    `config:"Host,required" desc:"The target host."`
+		Debug bool      `config:"Debug" desc:"Log every event."`
+		Retry testRetry `config:"Retry"`
+	}{})
+
+	assert.Equal(t, `| Parameter          | Type     | Required | Default | Description |
+|-------------------|---------|----------|---------|-------------|
+| **Mode** | string | No | `+"`udp`"+` | The protocol. Available options are `+"`udp`, `tcp`"+`. |
+| **Host** | string | Yes | - | The target host. |
+| **Debug** | boolean | No | `+"`false`"+` | Log every event. |
+| **Retry** | map | No | - |  |
+| **Retry.MaxAttempts** | int | No | `+"`3`"+` |  |
+| **Retry.Jitter** | number | No | - |  |
+`, s.Markdown())
+}
+
+func TestOf_InvalidDefault(t *testing.T) {
+	assert.Panics(t, func() {
+		Of(struct {
+			Port int `config:"Port" default:"http"`
+		}{})
+	})
+}